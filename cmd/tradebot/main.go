@@ -7,11 +7,13 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strings"
 	"sync"
 	"syscall"
 	"time"
 
+	"tradebot/internal/binance"
 	"tradebot/internal/cfg"
 	"tradebot/internal/core"
 	"tradebot/internal/data"
@@ -23,48 +25,6 @@ import (
 	"tradebot/internal/web"
 )
 
-func publishTrade(srv *web.Server, ev core.TradeEvent) {
-	if srv == nil {
-		return
-	}
-	payload := map[string]any{
-		"type": "trade",
-		"data": map[string]any{
-			"ts":     ev.TS.Format(time.RFC3339Nano),
-			"event":  ev.Event,
-			"side":   tradeSide(ev.Event, ev.Side),
-			"qty":    ev.Qty,
-			"price":  ev.Price,
-			"pnl":    ev.PnL,
-			"fee":    ev.Fee,
-			"note":   ev.Comment,
-			"symbol": ev.Symbol,
-			"tf":     ev.TF,
-		},
-	}
-	b, err := json.Marshal(payload)
-	if err != nil {
-		log.Printf("publish trade marshal: %v", err)
-		return
-	}
-	srv.PublishJSON(string(b))
-}
-
-func tradeSide(event string, side core.Action) string {
-	switch strings.ToUpper(event) {
-	case "CLOSE":
-		return "flat"
-	}
-	switch side {
-	case core.Buy:
-		return "long"
-	case core.Sell:
-		return "short"
-	default:
-		return "flat"
-	}
-}
-
 func main() {
 	c := cfg.Load()
 	logx.Setup(c.LogLevel)
@@ -85,14 +45,11 @@ func main() {
 	}
 
 	var strat core.Strategy = strategies.NewEmaAtr(9, 21, 14, 1.5)
-	switch st.Strategy.Type {
-	case "ema":
-		if len(st.Strategy.I) >= 3 && len(st.Strategy.F) >= 1 {
-			strat = strategies.NewEmaAtr(st.Strategy.I[0], st.Strategy.I[1], st.Strategy.I[2], st.Strategy.F[0])
-		}
-	case "rsi":
-		if len(st.Strategy.I) >= 1 && len(st.Strategy.F) >= 3 {
-			strat = strategies.NewRSI(st.Strategy.I[0], st.Strategy.F[0], st.Strategy.F[1], st.Strategy.F[2])
+	if st.Strategy.Type != "" {
+		if s, err := strategies.Build(st.Strategy.Type, strategies.Args(st.Strategy.Params, st.Strategy.Spec)); err != nil {
+			log.Printf("state strategy: %v, using default", err)
+		} else {
+			strat = s
 		}
 	}
 
@@ -106,23 +63,122 @@ func main() {
 	wsrv.CurTF = c.TF
 	wsrv.CurMode = defEx
 
+	var funding core.FundingSource
+	switch c.Funding {
+	case "":
+	case "binance":
+		url := c.FundingURL
+		if url == "" {
+			url = data.DefaultFuturesURL
+		}
+		funding = data.NewFundingHistory(url)
+	default:
+		rates, err := data.LoadFundingFile(c.Funding)
+		if err != nil {
+			log.Fatalf("funding file: %v", err)
+		}
+		fh := data.NewFundingHistory(c.FundingURL)
+		fh.Add(c.Symbol, rates)
+		funding = fh
+	}
+
+	bus := core.NewBus()
+	bus.Subscribe("log", core.SubOpts{Policy: core.DropOldest}, func(ev core.Event) {
+		switch e := ev.(type) {
+		case core.Notice:
+			log.Printf("%s", e.Text)
+		case core.RiskRejected:
+			log.Printf("risk rejected %s %v: %s", e.Symbol, e.Signal.Action, e.Reason)
+		case core.StrategyError:
+			log.Printf("strategy %s: %v", e.Strategy, e.Err)
+		case core.ExecutionFailed:
+			log.Printf("execution failed %s %v %.6f: %v", e.Symbol, e.Side, e.Qty, e.Err)
+		}
+	})
+	bus.Subscribe("tradelog", core.SubOpts{Policy: core.Block}, core.LogTrades(tl))
+	bus.Subscribe("web", core.SubOpts{Policy: core.DropOldest}, wsrv.HandleEvent)
+	events := core.NewEventCounter()
+	bus.Subscribe("metrics", core.SubOpts{Policy: core.Sync}, events.Handle)
+
+	var live core.Broker
+	equity := c.PaperEquity
+	if c.Mode == "live" {
+		if c.APIKey == "" || c.APISecret == "" {
+			log.Fatalf("live mode needs BINANCE_API_KEY and BINANCE_API_SECRET")
+		}
+		bc := binance.NewClient(c.BrokerURL, c.APIKey, c.APISecret, defEx == "futures")
+		bc.Symbols = []string{c.Symbol}
+		live = bc
+		bctx, bcancel := context.WithTimeout(ctx, 10*time.Second)
+		bals, err := live.Balances(bctx)
+		bcancel()
+		if err != nil {
+			log.Fatalf("live broker: %v", err)
+		}
+		equity = 0
+		for _, b := range bals {
+			if b.Asset == "USDT" {
+				equity = b.Free + b.Locked
+			}
+		}
+		log.Printf("live broker %s | USDT=%.2f", live.Name(), equity)
+	}
+
+	trading, err := core.ParseTradingState(st.Trading)
+	if err != nil {
+		log.Printf("state: %v, trading resumes as running", err)
+	}
+	if trading != core.TradingRunning {
+		log.Printf("trading restored as %s", trading)
+	}
+
 	eng := core.NewEngine(core.EngineOpts{
-		Mode:       c.Mode,
-		EqUSD:      c.PaperEquity,
-		Risk:       risk.Default(),
-		NotifyFunc: func(msg string) { log.Printf("%s", msg) },
-		TradeHook: func(ev core.TradeEvent) {
-			publishTrade(wsrv, ev)
-		},
-		Trades: tl,
+		Mode:        c.Mode,
+		EqUSD:       equity,
+		Broker:      live,
+		Trading:     trading,
+		Risk:        risk.Default(),
+		Bus:         bus,
+		Intrabar:    core.ParseIntrabarPolicy(c.Intrabar),
+		Opposite:    core.ParseOppositePolicy(c.Opposite),
+		AccountMode: core.ParseAccountMode(c.AccountMode),
+		Funding:     funding,
+		Margin:      core.MarginConfig{Mode: core.ParseMarginMode(c.MarginMode), Leverage: c.Leverage, MaintRate: c.MaintRate},
 	})
 	eng.AttachStrategy(strat)
-
+	// restore first, so reconciliation compares the exchange with what the
+	// bot knew; the reconcile policy decides what to take from the exchange
+	if st.Account != nil {
+		acct := *st.Account
+		if err := eng.Restore(acct); err != nil {
+			log.Printf("account restore failed, starting fresh: %v", err)
+		} else {
+			log.Printf("account restored | equity=%.2f positions=%d orders=%d", acct.EquityUSD, len(acct.Positions), len(acct.Orders))
+		}
+	}
 	feedType := "random"
 	if st.Feed.Type != "" {
 		feedType = st.Feed.Type
 	}
 
+	// the bot subscribes before reconciliation, so it gets the report
+	bot := tg.NewBot(c.TgToken, eng, tl, store, c.Symbol, c.TF, feedType)
+	bus.Subscribe("telegram", core.SubOpts{Policy: core.DropNewest, Buffer: 64}, bot.HandleEvent)
+
+	broker := live
+	if broker == nil {
+		broker = core.NewPaperBroker(eng)
+	} else {
+		var logged []core.Position
+		if rows, err := tl.LastN(1 << 20); err == nil {
+			logged = core.PositionsFromLog(rows)
+		}
+		rctx, rcancel := context.WithTimeout(ctx, 30*time.Second)
+		rep := eng.Reconcile(rctx, live, logged, core.ParseReconcilePolicy(c.Reconcile))
+		rcancel()
+		log.Printf("%s", rep)
+	}
+
 	var feedMu sync.Mutex
 	curFeed := feedType
 
@@ -134,86 +190,192 @@ func main() {
 		mode := wsrv.CurMode
 		feedMu.Unlock()
 		snap := eng.Snapshot()
+		positions := make([]map[string]any, 0, len(snap.Positions))
+		for _, p := range snap.Positions {
+			positions = append(positions, map[string]any{
+				"symbol": p.Symbol,
+				"leg":    p.Leg.String(),
+				"side":   web.TradeSide("", p.Side),
+				"qty":    p.Qty,
+				"entry":  p.Entry,
+				"unreal": p.Unreal,
+				"margin": p.Margin,
+				"liq":    p.LiqPrice,
+			})
+		}
+		var active map[string]any
+		if d, ok := strategies.Describe(eng.Strategy()); ok {
+			active = map[string]any{"kind": d.Schema.Kind, "params": d.Params}
+			if d.Spec != "" {
+				active["spec"] = d.Spec
+			}
+		}
+		if st, ok := eng.StrategyStatus(); ok { // e.g. grid levels
+			if active == nil {
+				active = map[string]any{}
+			}
+			active["status"] = st
+		}
+		var recon any
+		if rep, ok := eng.Reconciliation(); ok {
+			recon = map[string]any{"report": rep, "hold": rep.Pending()}
+		}
 		return map[string]any{
-			"mode":     c.Mode,
-			"symbol":   symbol,
-			"tf":       tf,
-			"feed":     feed,
-			"equity":   snap.EquityUSD,
-			"exchange": mode,
-			"strategy": wsrv.SelectedDSL(),
+			"mode":        c.Mode,
+			"accountMode": c.AccountMode,
+			"symbol":      symbol,
+			"tf":          tf,
+			"feed":        feed,
+			"equity":      snap.EquityUSD,
+			"unrealized":  snap.Unrealized,
+			"netEquity":   snap.NetEquity,
+			"positions":   positions,
+			"margin": map[string]any{
+				"mode":  core.ParseMarginMode(c.MarginMode).String(),
+				"used":  snap.MarginUsed,
+				"maint": snap.MaintMargin,
+				"ratio": snap.MarginRatio,
+			},
+			"exchange":  mode,
+			"broker":    broker.Name(),
+			"trading":   eng.TradingState().String(),
+			"reconcile": recon,
+			"strategy":  wsrv.SelectedDSL(),
+			"active":    active,
+			"events":    events.Snapshot(),
+			"dropped":   bus.Dropped(),
 		}
 	}
 
+	wsrv.GetOrders = func() any {
+		orders := eng.Orders("")
+		out := make([]map[string]any, 0, len(orders))
+		for _, o := range orders {
+			out = append(out, map[string]any{
+				"id":     o.ID,
+				"symbol": o.Symbol,
+				"side":   web.TradeSide("", o.Side),
+				"type":   o.Type.String(),
+				"status": o.Status.String(),
+				"qty":    o.Qty,
+				"price":  o.Price,
+				"stop":   o.StopPrice,
+				"note":   o.Comment,
+			})
+		}
+		return out
+	}
+	wsrv.OnCancelOrder = eng.CancelOrder
+	wsrv.OnAckRecon = eng.AckReconciliation
+	wsrv.OnSetTrading = func(name string) error {
+		s, err := core.ParseTradingState(name)
+		if err != nil {
+			return err
+		}
+		return eng.SetTradingState(s)
+	}
+
 	var (
 		stMu       sync.Mutex
 		cancelFeed context.CancelFunc
-		bot        *tg.Bot
 	)
 
+	// saveAccount persists st with the current engine strategy, account and
+	// trading state; callers hold stMu.
+	saveAccount := func() error {
+		st.Strategy = state.Capture(eng.Strategy())
+		acct := eng.Export()
+		st.Account = &acct
+		st.Trading = eng.TradingState().String()
+		return store.Save(st)
+	}
+	// a trading state change must survive a restart; any save carries the
+	// latest state, so dropping stale events is harmless.
+	bus.Subscribe("state", core.SubOpts{Policy: core.DropOldest, Buffer: 4}, func(ev core.Event) {
+		if _, ok := ev.(core.TradingStateChanged); !ok {
+			return
+		}
+		stMu.Lock()
+		defer stMu.Unlock()
+		if err := saveAccount(); err != nil {
+			log.Printf("state save: %v", err)
+		}
+	})
+
+	// warmup history comes from the market the rest feed polls; spot and
+	// futures bars are cached apart
+	restHistory := func(mode string) core.HistorySource {
+		var h core.HistorySource = data.NewRestHistory(c.MarketURL, mode == "futures")
+		if c.CandleCache != "" {
+			h = data.NewCandleCache(filepath.Join(c.CandleCache, mode), h)
+		}
+		return h
+	}
 	startFeed := func(ftype string) context.CancelFunc {
 		ctxFeed, cancelFeed := context.WithCancel(ctx)
-		switch ftype {
-		case "rest":
-			interval, err := time.ParseDuration(c.RestInterval)
-			if err != nil || interval <= 0 {
-				interval = 3 * time.Second
-			}
-			symbol := wsrv.CurSymbol
-			tf := wsrv.CurTF
-			feed := data.NewRestFeed(symbol, tf, interval)
-			go func() {
-				for k := range feed.Candles {
-					if line, err := json.Marshal(map[string]any{
-						"type": "candle",
-						"data": map[string]any{
-							"t":      k.Ts.UnixMilli(),
-							"o":      k.Open,
-							"h":      k.High,
-							"l":      k.Low,
-							"c":      k.Close,
-							"symbol": k.Symbol,
-							"tf":     k.TF,
-						},
-					}); err == nil {
-						wsrv.PublishJSON(string(line))
-					} else {
-						log.Printf("candle marshal: %v", err)
-					}
-					if err := eng.OnCandle(k.Symbol, k.TF, k); err != nil {
-						log.Printf("engine OnCandle: %v", err)
-					}
+		symbol := wsrv.CurSymbol
+		tf := wsrv.CurTF
+		mode := wsrv.CurMode
+		// a multi-timeframe strategy may read streams that cannot be
+		// resampled from the traded one; each gets a feed of the same type
+		streams := []core.Stream{{Symbol: symbol, TF: tf}}
+		_, fed := core.PlanStreams(eng.Strategy(), symbol, tf)
+		streams = append(streams, fed...)
+		mux := core.NewStreamMux(symbol, tf, eng.Strategy)
+		for i, st := range streams {
+			var candles chan core.Kline
+			switch ftype {
+			case "rest":
+				interval, err := time.ParseDuration(c.RestInterval)
+				if err != nil || interval <= 0 {
+					interval = 3 * time.Second
 				}
-			}()
-			feed.Start(ctxFeed)
-		default:
-			symbol := wsrv.CurSymbol
-			tf := wsrv.CurTF
-			feed := data.NewRandomFeed(symbol, tf, time.Now().Add(-time.Hour), 64000, 0.002)
+				feed := data.NewRestFeed(st.Symbol, st.TF, interval)
+				feed.BaseURL, feed.Futures = c.MarketURL, mode == "futures"
+				candles = feed.Candles
+				if i == 0 {
+					eng.SetWarmup(symbol, tf, restHistory(mode))
+				}
+				feed.Start(ctxFeed)
+			default:
+				feed := data.NewRandomFeed(st.Symbol, st.TF, time.Now().Add(-time.Hour), 64000, 0.002)
+				candles = feed.Candles
+				if i == 0 {
+					eng.SetWarmup(symbol, tf, feed)
+				}
+				feed.Start(ctxFeed)
+			}
+			traded := i == 0
 			go func() {
-				for k := range feed.Candles {
-					if line, err := json.Marshal(map[string]any{
-						"type": "candle",
-						"data": map[string]any{
-							"t":      k.Ts.UnixMilli(),
-							"o":      k.Open,
-							"h":      k.High,
-							"l":      k.Low,
-							"c":      k.Close,
-							"symbol": k.Symbol,
-							"tf":     k.TF,
-						},
-					}); err == nil {
-						wsrv.PublishJSON(string(line))
-					} else {
-						log.Printf("candle marshal: %v", err)
+				for k := range candles {
+					if traded {
+						if line, err := json.Marshal(map[string]any{
+							"type": "candle",
+							"data": map[string]any{
+								"t":      k.Ts.UnixMilli(),
+								"o":      k.Open,
+								"h":      k.High,
+								"l":      k.Low,
+								"c":      k.Close,
+								"symbol": k.Symbol,
+								"tf":     k.TF,
+							},
+						}); err == nil {
+							wsrv.PublishJSON(string(line))
+						} else {
+							log.Printf("candle marshal: %v", err)
+						}
 					}
-					if err := eng.OnCandle(k.Symbol, k.TF, k); err != nil {
-						log.Printf("engine OnCandle: %v", err)
+					for _, bar := range mux.Add(k) {
+						if err := eng.OnCandle(bar.Symbol, bar.TF, bar); err != nil {
+							log.Printf("engine OnCandle: %v", err)
+						}
 					}
 				}
 			}()
-			feed.Start(ctxFeed)
+		}
+		if len(fed) > 0 {
+			log.Printf("extra streams fed: %v", fed)
 		}
 		return cancelFeed
 	}
@@ -261,7 +423,7 @@ func main() {
 		st.Feed.Type = newFeed
 		var err error
 		if persist {
-			err = store.Save(st)
+			err = saveAccount()
 		}
 		stMu.Unlock()
 		if err != nil {
@@ -274,7 +436,7 @@ func main() {
 	wsrv.OnSaveState = func() error {
 		stMu.Lock()
 		defer stMu.Unlock()
-		return store.Save(st)
+		return saveAccount()
 	}
 	wsrv.OnLoadState = func() error {
 		ns, err := store.Load()
@@ -307,14 +469,49 @@ func main() {
 
 	cancelFeed = startFeed(feedType)
 
+	saveEvery, err := time.ParseDuration(c.StateSave)
+	if err != nil || saveEvery <= 0 {
+		saveEvery = time.Minute
+	}
+	go func() {
+		t := time.NewTicker(saveEvery)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				stMu.Lock()
+				if err := saveAccount(); err != nil {
+					log.Printf("state save: %v", err)
+				}
+				stMu.Unlock()
+			}
+		}
+	}()
+
+	// strategy timers fire between candles too
+	go func() {
+		t := time.NewTicker(time.Second)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				if err := eng.Tick(); err != nil {
+					log.Printf("engine Tick: %v", err)
+				}
+			}
+		}
+	}()
+
 	go func() {
 		if err := wsrv.Serve(); err != nil {
 			log.Printf("web server stopped: %v", err)
 		}
 	}()
 
-	bot = tg.NewBot(c.TgToken, eng, tl, store, c.Symbol, c.TF, feedType)
-
 	go func() {
 		if err := bot.Run(ctx, func(newFeed string) {
 			if err := changeFeed(newFeed, true); err != nil {
@@ -335,6 +532,13 @@ func main() {
 		cancelFeed()
 	}
 	feedMu.Unlock()
+	eng.Stop()
+	stMu.Lock()
+	if err := saveAccount(); err != nil {
+		log.Printf("state save: %v", err)
+	}
+	stMu.Unlock()
+	bus.Close()
 	wsrv.Stop()
 	time.Sleep(300 * time.Millisecond)
 }
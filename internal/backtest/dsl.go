@@ -10,6 +10,8 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+
+	"tradebot/internal/strategies"
 )
 
 type DSLDoc struct {
@@ -71,11 +73,21 @@ func SelectedDSL() string {
 
 type dslSpec struct {
 	Kind string
-	Args map[string]float64
+	Args map[string]any
 }
 
+// rulesKeys are the keys of a rules strategy; see strategies.RulesConfig.
+var rulesKeys = []string{"long", "short", "exit", "sl", "tp", "size"}
+
+// compositeKeys are the keys of a composite strategy; see
+// strategies.CompositeArgs.
+var compositeKeys = []string{"spec", "mode", "threshold", "children"}
+
+// compileDSL reads a strategy document: the kind, and its parameters from a
+// params block or, when the strategy's schema knows them (or the kind is
+// rules or composite), top-level keys. Values are validated later against the schema.
 func compileDSL(body []byte) (dslSpec, error) {
-	spec := dslSpec{Kind: "ema_atr", Args: map[string]float64{}}
+	spec := dslSpec{Kind: "ema_atr", Args: map[string]any{}}
 	root, err := parseDSLDocument(body)
 	if err != nil {
 		return spec, err
@@ -83,59 +95,59 @@ func compileDSL(body []byte) (dslSpec, error) {
 	if v, ok := root["strategy"]; ok {
 		spec.Kind = strings.ToLower(fmt.Sprint(v))
 	}
-	params := map[string]any{}
 	if rawParams, ok := root["params"].(map[string]any); ok {
 		for k, v := range rawParams {
-			params[k] = v
+			spec.Args[k] = v
 		}
 	}
+	sc, known := strategies.SchemaOf(spec.Kind)
 	for k, v := range root {
-		kl := strings.ToLower(k)
-		if kl == "strategy" || kl == "name" || kl == "title" || kl == "params" {
+		if _, exists := spec.Args[k]; exists || !known {
 			continue
 		}
-		if _, exists := params[k]; !exists {
-			params[k] = v
+		if _, ok := sc.Param(k); ok {
+			spec.Args[k] = v
 		}
 	}
-	for k, v := range params {
-		if f, ok := toFloat(v); ok {
-			key := k
-			spec.Args[key] = f
-			lower := strings.ToLower(k)
-			if lower != key {
-				spec.Args[lower] = f
+	for _, k := range map[string][]string{"rules": rulesKeys, strategies.CompositeKind: compositeKeys}[spec.Kind] {
+		if v, ok := root[k]; ok {
+			if _, exists := spec.Args[k]; !exists {
+				spec.Args[k] = v
 			}
 		}
 	}
-	if v, ok := spec.Args["r"]; ok {
-		spec.Args["R"] = v
-	}
 	return spec, nil
 }
 
-func toFloat(v any) (float64, bool) {
-	switch val := v.(type) {
-	case float64:
-		return val, true
-	case float32:
-		return float64(val), true
-	case int:
-		return float64(val), true
-	case int64:
-		return float64(val), true
-	case uint64:
-		return float64(val), true
-	case json.Number:
-		if f, err := val.Float64(); err == nil {
-			return f, true
-		}
-	case string:
-		if f, err := strconv.ParseFloat(val, 64); err == nil {
-			return f, true
+// rulesConfig reads rules strategy args: expressions as strings, size as a
+// number.
+func rulesConfig(args map[string]any) (strategies.RulesConfig, error) {
+	var cfg strategies.RulesConfig
+	for k, v := range args {
+		s := strings.TrimSpace(fmt.Sprint(v))
+		switch strings.ToLower(k) {
+		case "long":
+			cfg.Long = s
+		case "short":
+			cfg.Short = s
+		case "exit":
+			cfg.Exit = s
+		case "sl":
+			cfg.SL = s
+		case "tp":
+			cfg.TP = s
+		case "size":
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil || f <= 0 || f > 1 {
+				return cfg, fmt.Errorf("rules: size %q must be in (0, 1]", s)
+			}
+			cfg.Size = f
+		case "id":
+		default:
+			return cfg, fmt.Errorf("rules: unknown key %q (want %s)", k, strings.Join(rulesKeys, ", "))
 		}
 	}
-	return 0, false
+	return cfg, nil
 }
 
 func parseDSLDocument(body []byte) (map[string]any, error) {
@@ -1,16 +1,30 @@
 package backtest
 
-import "strings"
+import "tradebot/internal/core"
 
 type pair struct{ G, L float64 }
 
+// ComputeMetrics summarizes a run. PNL adds funding to the exits' PnL;
+// Trades, WinRate and ProfitFact count exits only.
 func ComputeMetrics(eq []Point, trades []Trade) Summary {
-	var sumPnL float64
+	var sumPnL, funding float64
 	var pf pair
 	wins := 0
 	closes := 0
+	deals, dealWins := 0, 0
 	for _, t := range trades {
-		if strings.ToUpper(t.Event) != "CLOSE" {
+		if t.Event == core.EvFunding {
+			funding += t.PnL
+			continue
+		}
+		if t.Event == core.EvDeal {
+			deals++
+			if t.PnL > 0 {
+				dealWins++
+			}
+			continue
+		}
+		if !core.IsExitEvent(t.Event) {
 			continue
 		}
 		closes++
@@ -46,5 +60,9 @@ func ComputeMetrics(eq []Point, trades []Trade) Summary {
 	if pf.L > 0 {
 		pfv = pf.G / pf.L
 	}
-	return Summary{PNL: sumPnL, Trades: closes, WinRate: wr, ProfitFact: pfv, MaxDD: dd}
+	dwr := 0.0
+	if deals > 0 {
+		dwr = float64(dealWins) / float64(deals)
+	}
+	return Summary{PNL: sumPnL + funding, Trades: closes, WinRate: wr, ProfitFact: pfv, MaxDD: dd, Funding: funding, Deals: deals, DealWinRate: dwr}
 }
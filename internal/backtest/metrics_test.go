@@ -0,0 +1,27 @@
+package backtest
+
+import (
+	"testing"
+
+	"tradebot/internal/core"
+)
+
+func TestMetricsPNLIncludesFunding(t *testing.T) {
+	trades := []Trade{
+		{Event: "OPEN", Side: "BUY", Qty: 1, Price: 100},
+		{Event: core.EvFunding, PnL: -3},
+		{Event: core.EvFunding, PnL: 1},
+		{Event: "TP", Side: "SELL", Qty: 1, Price: 110, PnL: 10},
+		{Event: core.EvDeal, PnL: 8},
+		{Event: "OPEN", Side: "SELL", Qty: 1, Price: 110},
+		{Event: "SL", Side: "BUY", Qty: 1, Price: 114, PnL: -4},
+		{Event: core.EvDeal, PnL: -4},
+	}
+	s := ComputeMetrics(nil, trades)
+	if s.Funding != -2 || s.PNL != 4 {
+		t.Errorf("PNL %v, funding %v; want 4 = 10 - 4 - 2 and -2", s.PNL, s.Funding)
+	}
+	if s.Trades != 2 || s.WinRate != 0.5 || s.ProfitFact != 2.5 || s.Deals != 2 || s.DealWinRate != 0.5 {
+		t.Errorf("funding counted as a trade: %+v", s)
+	}
+}
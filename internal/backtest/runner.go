@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"strconv"
 	"strings"
 	"time"
 
@@ -25,8 +24,15 @@ type Params struct {
 	SlippageBps   float64
 	Fees          FeesConfig
 	Exchange      string
-	StrategyKind  string         // "ema_atr" | "rsi" | "dsl"
-	StrategyArgs  map[string]any // params for strategy (numbers or ids)
+	Intrabar      string         // "stop_first" | "target_first" | "nearest"
+	Opposite      string         // "reverse" | "net" | "close" | "ignore"
+	AccountMode   string         // "oneway" | "hedge"
+	MarginMode    string         // "isolated" | "cross"; futures default isolated
+	MaintRate     float64        // maintenance margin rate, default 0.004
+	FundingURL    string         // futures funding source: REST base URL (default Binance)
+	FundingFile   string         // ...or a local JSON/CSV file for offline runs
+	StrategyKind  string         // a registered kind, "rules" or "dsl"
+	StrategyArgs  map[string]any // params for strategy (numbers, rule expressions or a dsl id)
 }
 
 type Trade struct {
@@ -49,14 +55,18 @@ type Result struct {
 	Trades      []Trade `json:"trades"`
 	EquityCurve []Point `json:"equity"`
 	Summary     Summary `json:"summary"`
+	Strategy    any     `json:"strategy,omitempty"` // final core.StatusReporter snapshot, e.g. grid profit
 }
 
 type Summary struct {
-	PNL        float64 `json:"pnl"`
-	Trades     int     `json:"trades"`
-	WinRate    float64 `json:"winRate"`
-	ProfitFact float64 `json:"profitFactor"`
-	MaxDD      float64 `json:"maxDD"`
+	PNL         float64 `json:"pnl"` // realized on exits plus funding, fees excluded
+	Trades      int     `json:"trades"`
+	WinRate     float64 `json:"winRate"`
+	ProfitFact  float64 `json:"profitFactor"`
+	MaxDD       float64 `json:"maxDD"`
+	Funding     float64 `json:"funding"` // net funding received (negative = paid)
+	Deals       int     `json:"deals"`   // completed position cycles, adds and partial exits included
+	DealWinRate float64 `json:"dealWinRate"`
 }
 
 type leverageRisk struct{ leverage float64 }
@@ -77,94 +87,188 @@ func (lr leverageRisk) Validate(sig core.Signal, acct core.AccountState, px floa
 // Run — упрощённый бэктест: история тянется прямым REST (spot)
 func Run(p Params) (Result, error) {
 	exch := strings.ToLower(p.Exchange)
-	// 1) загрузим историю свечей
-	var kl []kline
-	var err error
-	if exch == "futures" {
-		fr, ferr := data.FetchHistoryFutures(p.Symbol, p.TF, p.From, p.To)
-		err = ferr
-		if err == nil {
-			kl = make([]kline, len(fr))
-			for i, v := range fr {
-				kl[i] = kline{Ts: v.Ts, Open: v.Open, High: v.High, Low: v.Low, Close: v.Close, Vol: v.Vol}
-			}
-		}
-	} else {
-		kl, err = fetchHistorySpot(p.Symbol, p.TF, p.From, p.To)
+	strat, err := NewStrategyFromParams(p)
+	if err != nil {
+		return Result{}, err
 	}
+	// 1) загрузим историю свечей
+	kl, err := loadHistory(exch, p.Symbol, p.TF, p.From, p.To)
 	if err != nil {
 		return Result{}, err
 	}
 	if len(kl) == 0 {
 		return Result{}, fmt.Errorf("no history")
 	}
+	return run(p, strat, kl)
+}
+
+// run прогоняет strat по свечам kl основного потока
+func run(p Params, strat core.Strategy, kl []core.Kline) (Result, error) {
+	exch := strings.ToLower(p.Exchange)
+	var err error
 
 	// 2) инициализируем движок с буферным логом сделок
 	trades := make([]Trade, 0, 256)
-	feeAccTotal := 0.0
-	roundTripFees := 0.0
+	entryFees := map[core.Leg]float64{} // комиссии входов, ещё не отнесённые к выходу, по ноге
 	feeRate := p.Fees.TakerBps / 10000.0
 	if feeRate < 0 {
 		feeRate = 0
 	}
+	makerRate := p.Fees.MakerBps / 10000.0
+	if makerRate < 0 {
+		makerRate = 0
+	}
 
 	eq := p.InitialEquity
 	equity := []Point{{TS: kl[0].Ts, Equity: eq}}
 
-	eng := core.NewEngine(core.EngineOpts{
-		Mode:       "backtest",
-		EqUSD:      eq,
-		Risk:       leverageRisk{leverage: p.Leverage},
-		NotifyFunc: func(string) {},
-		TradeHook: func(ev core.TradeEvent) {
-			if ev.Qty <= 0 || ev.Price <= 0 {
-				return
-			}
-			fee := ev.Price * ev.Qty * feeRate
-			if fee < 0 {
-				fee = 0
-			}
-			feeAccTotal += fee
+	margin := core.MarginConfig{Mode: core.ParseMarginMode(p.MarginMode), Leverage: p.Leverage, MaintRate: p.MaintRate}
+	if exch == "futures" && margin.Mode == core.NoMargin {
+		margin.Mode = core.Isolated
+	}
+	if margin.MaintRate <= 0 {
+		margin.MaintRate = 0.004
+	}
 
-			switch strings.ToUpper(ev.Event) {
-			case "OPEN", "ADD":
-				roundTripFees += fee
-			case "CLOSE":
-				totalFee := roundTripFees + fee
-				netPnL := ev.PnL - totalFee
-				trades = append(trades, Trade{
-					TS:    ev.TS,
-					Event: ev.Event,
-					Side:  actionToSide(ev.Side),
-					Qty:   ev.Qty,
-					Price: ev.Price,
-					PnL:   netPnL,
-					Fee:   totalFee,
-					Note:  ev.Comment,
-				})
-				roundTripFees = 0
-			}
-		},
-		Trades: nil, // не пишем в файл в режиме бэктеста
+	// funding perpetual-фьючерсов
+	var funding core.FundingSource
+	if exch == "futures" {
+		var rates []data.FundingRate
+		if p.FundingFile != "" {
+			rates, err = data.LoadFundingFile(p.FundingFile)
+		} else {
+			rates, err = data.FetchFundingRates(p.FundingURL, p.Symbol, p.From, p.To)
+		}
+		if err != nil {
+			return Result{}, fmt.Errorf("funding: %w", err)
+		}
+		fh := data.NewFundingHistory("")
+		fh.Add(p.Symbol, rates)
+		funding = fh
+	}
+
+	bus := core.NewBus()
+	bus.Subscribe("backtest", core.SubOpts{Policy: core.Sync}, func(e core.Event) {
+		// закрытая сделка (цикл позиции от открытия до нуля) — одной строкой
+		// DEAL, PnL за вычетом всех её комиссий
+		if dc, ok := e.(core.DealClosed); ok {
+			d := dc.Deal
+			trades = append(trades, Trade{TS: dc.TS, Event: core.EvDeal, Side: actionToSide(d.Side), Qty: d.EntryQty, Price: d.AvgEntry, PnL: d.PnL - d.Fees, Fee: d.Fees, Note: d.String()})
+			return
+		}
+		pc, ok := e.(core.PositionChanged)
+		if !ok {
+			return
+		}
+		ev := pc.TradeEvent
+		if ev.Event == core.EvFunding {
+			trades = append(trades, Trade{TS: ev.TS, Event: ev.Event, Side: actionToSide(ev.Side), Qty: ev.Qty, Price: ev.Price, PnL: ev.PnL, Note: ev.Comment})
+			return
+		}
+		if ev.Qty <= 0 || ev.Price <= 0 {
+			return
+		}
+		// комиссию списывает движок; выходу достаётся ещё доля комиссий
+		// входов своей ноги, пропорционально закрытому объёму
+		switch {
+		case core.IsExitEvent(ev.Event):
+			share := entryFees[ev.Leg] * ev.Qty / (ev.Qty + pc.Position.Qty)
+			entryFees[ev.Leg] -= share
+			totalFee := share + ev.Fee
+			netPnL := ev.PnL - totalFee
+			trades = append(trades, Trade{
+				TS:    ev.TS,
+				Event: ev.Event,
+				Side:  actionToSide(ev.Side),
+				Qty:   ev.Qty,
+				Price: ev.Price,
+				PnL:   netPnL,
+				Fee:   totalFee,
+				Note:  ev.Comment,
+			})
+		default:
+			entryFees[ev.Leg] += ev.Fee
+		}
+	})
+
+	eng := core.NewEngine(core.EngineOpts{
+		Mode:        "backtest",
+		EqUSD:       eq,
+		Risk:        leverageRisk{leverage: p.Leverage},
+		Bus:         bus,
+		Clock:       core.NewCandleClock(),
+		Intrabar:    core.ParseIntrabarPolicy(p.Intrabar),
+		Opposite:    core.ParseOppositePolicy(p.Opposite),
+		AccountMode: core.ParseAccountMode(p.AccountMode),
+		Margin:      margin,
+		Fees:        core.FeeConfig{Maker: makerRate, Taker: feeRate},
+		Funding:     funding,
 	})
+	defer bus.Close()
 
 	// 3) стратегия
-	eng.AttachStrategy(NewStrategyFromParams(p))
+	eng.AttachStrategy(strat)
+
+	// 3a) доп. потоки multi-timeframe стратегии: старшие ТФ того же символа
+	// ресемплируются из основного потока, остальные грузятся и сливаются по
+	// времени закрытия свечи, чтобы не заглядывать в будущее
+	series := [][]core.Kline{}
+	_, fed := core.PlanStreams(strat, p.Symbol, p.TF)
+	for _, st := range fed {
+		extra, err := loadHistory(exch, st.Symbol, st.TF, p.From, p.To)
+		if err != nil {
+			return Result{}, fmt.Errorf("stream %s: %w", st, err)
+		}
+		series = append(series, extra)
+	}
+	candles := core.MergeStreams(append(series, kl)...)
+	mux := core.NewStreamMux(p.Symbol, p.TF, func() core.Strategy { return strat })
 
 	// 4) цикл по свечам
-	for _, k := range kl {
-		ck := core.Kline{Symbol: p.Symbol, TF: p.TF, Open: k.Open, High: k.High, Low: k.Low, Close: k.Close, Vol: k.Vol, Ts: k.Ts}
-		if err := eng.OnCandle(p.Symbol, p.TF, ck); err != nil {
-			return Result{}, err
+	for _, k := range candles {
+		for _, bar := range mux.Add(k) {
+			if err := eng.OnCandle(bar.Symbol, bar.TF, bar); err != nil {
+				return Result{}, err
+			}
+		}
+		if k.Symbol != p.Symbol || k.TF != p.TF {
+			continue
 		}
 		s := eng.Snapshot()
-		equity = append(equity, Point{TS: k.Ts, Equity: s.EquityUSD - feeAccTotal})
+		equity = append(equity, Point{TS: core.CloseTime(k), Equity: s.EquityUSD})
 	}
+	eng.Stop()
 
 	// 5) метрики
 	sm := ComputeMetrics(equity, trades)
+	status, _ := eng.StrategyStatus()
 
-	return Result{Trades: trades, EquityCurve: equity, Summary: sm}, nil
+	return Result{Trades: trades, EquityCurve: equity, Summary: sm, Strategy: status}, nil
+}
+
+// loadHistory fetches sym/tf candles from the spot or futures REST API.
+func loadHistory(exch, sym, tf string, from, to time.Time) ([]core.Kline, error) {
+	var kl []kline
+	if exch == "futures" {
+		fr, err := data.FetchHistoryFutures(sym, tf, from, to)
+		if err != nil {
+			return nil, err
+		}
+		kl = make([]kline, len(fr))
+		for i, v := range fr {
+			kl[i] = kline{Ts: v.Ts, Open: v.Open, High: v.High, Low: v.Low, Close: v.Close, Vol: v.Vol}
+		}
+	} else {
+		var err error
+		if kl, err = fetchHistorySpot(sym, tf, from, to); err != nil {
+			return nil, err
+		}
+	}
+	out := make([]core.Kline, len(kl))
+	for i, k := range kl {
+		out[i] = core.Kline{Symbol: sym, TF: tf, Open: k.Open, High: k.High, Low: k.Low, Close: k.Close, Vol: k.Vol, Ts: k.Ts}
+	}
+	return out, nil
 }
 
 // ===== История (Spot) — как в W2, но как внутренняя функция
@@ -175,7 +279,7 @@ type kline struct {
 }
 
 func fetchHistorySpot(sym, tf string, from, to time.Time) ([]kline, error) {
-	iv := map[string]string{"1m": "1m", "5m": "5m", "15m": "15m", "1h": "1h"}[tf]
+	iv := map[string]string{"1m": "1m", "5m": "5m", "15m": "15m", "30m": "30m", "1h": "1h", "4h": "4h", "1d": "1d"}[tf]
 	if iv == "" {
 		iv = "1m"
 	}
@@ -237,15 +341,12 @@ func toInt64(v any) int64 {
 	}
 }
 
-// NewStrategyFromParams — адаптер: соберёт реализацию core.Strategy из Params
-func NewStrategyFromParams(p Params) core.Strategy {
+// NewStrategyFromParams — адаптер: соберёт реализацию core.Strategy из Params;
+// параметры проверяются по схеме стратегии
+func NewStrategyFromParams(p Params) (core.Strategy, error) {
 	kind := strings.ToLower(p.StrategyKind)
 	args := p.StrategyArgs
-	if args == nil {
-		args = map[string]any{}
-	}
-	switch kind {
-	case "dsl":
+	if kind == "dsl" {
 		id := ""
 		if v, ok := args["id"]; ok {
 			id = fmt.Sprint(v)
@@ -253,114 +354,27 @@ func NewStrategyFromParams(p Params) core.Strategy {
 		if id == "" {
 			id = SelectedDSL()
 		}
-		if doc, ok := GetDSLDoc(id); ok {
-			if spec, err := compileDSL(doc.Body); err == nil {
-				compiledArgs := make(map[string]any, len(spec.Args))
-				for k, v := range spec.Args {
-					compiledArgs[k] = v
-				}
-				return strategyFromKind(spec.Kind, compiledArgs)
-			}
+		doc, ok := GetDSLDoc(id)
+		if !ok {
+			return nil, fmt.Errorf("dsl %q not found", id)
 		}
-		kind = "ema_atr"
-	case "", "ema", "ema_atr":
-		kind = "ema_atr"
-	}
-	return strategyFromKind(kind, args)
-}
-
-func strategyFromKind(kind string, args map[string]any) core.Strategy {
-	switch strings.ToLower(kind) {
-	case "rsi":
-		l := intArg(args, "len", 14)
-		ob := numArg(args, "overbought", 70)
-		os := numArg(args, "oversold", 30)
-		R := numArg(args, "R", 1.5)
-		return strategies.NewRSI(l, ob, os, R)
-	default:
-		f := intArg(args, "fast", 9)
-		s := intArg(args, "slow", 21)
-		a := intArg(args, "atr", 14)
-		R := numArg(args, "R", 1.5)
-		return strategies.NewEmaAtr(f, s, a, R)
-	}
-}
-
-func numArg(args map[string]any, key string, def float64) float64 {
-	v, ok := args[key]
-	if !ok {
-		lower := strings.ToLower(key)
-		upper := strings.ToUpper(key)
-		if alt, okAlt := args[lower]; okAlt {
-			v = alt
-			ok = true
-		} else if alt, okAlt := args[upper]; okAlt {
-			v = alt
-			ok = true
-		}
-	}
-	if !ok {
-		return def
-	}
-	switch val := v.(type) {
-	case float64:
-		return val
-	case float32:
-		return float64(val)
-	case int:
-		return float64(val)
-	case int64:
-		return float64(val)
-	case json.Number:
-		f, err := val.Float64()
-		if err == nil {
-			return f
-		}
-	case string:
-		if f, err := strconv.ParseFloat(val, 64); err == nil {
-			return f
-		}
-	default:
-		if f, err := strconv.ParseFloat(fmt.Sprint(v), 64); err == nil {
-			return f
+		spec, err := compileDSL(doc.Body)
+		if err != nil {
+			return nil, fmt.Errorf("dsl %s: %w", doc.Name, err)
 		}
+		kind, args = spec.Kind, spec.Args
 	}
-	return def
-}
-
-func intArg(args map[string]any, key string, def int) int {
-	v, ok := args[key]
-	if !ok {
-		lower := strings.ToLower(key)
-		upper := strings.ToUpper(key)
-		if alt, okAlt := args[lower]; okAlt {
-			v = alt
-			ok = true
-		} else if alt, okAlt := args[upper]; okAlt {
-			v = alt
-			ok = true
+	if kind == "rules" {
+		cfg, err := rulesConfig(args)
+		if err != nil {
+			return nil, err
 		}
+		return strategies.NewRules(cfg)
 	}
-	if !ok {
-		return def
-	}
-	switch val := v.(type) {
-	case int:
-		return val
-	case int64:
-		return int(val)
-	case float64:
-		return int(val)
-	case json.Number:
-		if i, err := val.Int64(); err == nil {
-			return int(i)
-		}
-	case string:
-		if i, err := strconv.Atoi(val); err == nil {
-			return i
-		}
+	if kind == "" {
+		kind = "ema_atr"
 	}
-	return int(numArg(args, key, float64(def)))
+	return strategies.Build(kind, args)
 }
 
 func actionToSide(a core.Action) string {
@@ -0,0 +1,48 @@
+package backtest
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"tradebot/internal/core"
+	"tradebot/internal/strategies"
+)
+
+// walk is n one-minute candles of BTCUSDT, a seeded random walk.
+func walk(n int) []core.Kline {
+	rng := rand.New(rand.NewSource(1))
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	out := make([]core.Kline, n)
+	px := 40000.0
+	for i := range out {
+		o := px
+		px *= 1 + rng.NormFloat64()*0.001
+		hi, lo := max(o, px)*(1+rng.Float64()*0.0005), min(o, px)*(1-rng.Float64()*0.0005)
+		out[i] = core.Kline{Symbol: "BTCUSDT", TF: "1m", Ts: t0.Add(time.Duration(i) * time.Minute), Open: o, High: hi, Low: lo, Close: px, Vol: 1}
+	}
+	return out
+}
+
+// BenchmarkBacktest1MBars runs a whole backtest over 1M bars per iteration,
+// metrics included.
+func BenchmarkBacktest1MBars(b *testing.B) {
+	bars := walk(1_000_000)
+	p := Params{Symbol: "BTCUSDT", TF: "1m", InitialEquity: 10000, Leverage: 1, Fees: FeesConfig{MakerBps: 2, TakerBps: 5}}
+	for _, kind := range []string{"ema_atr", "grid"} {
+		b.Run(kind, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				strat, err := strategies.Build(kind, strategies.Args(nil, ""))
+				if err != nil {
+					b.Fatal(err)
+				}
+				res, err := run(p, strat, bars)
+				if err != nil {
+					b.Fatal(err)
+				}
+				b.ReportMetric(float64(len(res.Trades)), "trades")
+			}
+		})
+	}
+}
@@ -0,0 +1,317 @@
+package binance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"tradebot/internal/core"
+)
+
+var _ core.Broker = (*Client)(nil)
+
+type orderResp struct {
+	OrderID             int64  `json:"orderId"`
+	Symbol              string `json:"symbol"`
+	Status              string `json:"status"`
+	Side                string `json:"side"`
+	Type                string `json:"type"`
+	PositionSide        string `json:"positionSide"`
+	Price               string `json:"price"`
+	StopPrice           string `json:"stopPrice"`
+	OrigQty             string `json:"origQty"`
+	ExecutedQty         string `json:"executedQty"`
+	CummulativeQuoteQty string `json:"cummulativeQuoteQty"` // spot
+	AvgPrice            string `json:"avgPrice"`            // futures
+	Time                int64  `json:"time"`
+	TransactTime        int64  `json:"transactTime"`
+}
+
+func (c *Client) Name() string {
+	if c.Futures {
+		return "binance-futures"
+	}
+	return "binance-spot"
+}
+
+func (c *Client) PlaceOrder(ctx context.Context, req core.OrderRequest) (core.OrderAck, error) {
+	typ, err := c.orderType(req.Type)
+	if err != nil {
+		return core.OrderAck{}, err
+	}
+	p := url.Values{}
+	p.Set("symbol", req.Symbol)
+	p.Set("side", sideName(req.Side))
+	p.Set("type", typ)
+	p.Set("quantity", fmtF(req.Qty))
+	if req.Type == core.Limit || req.Type == core.StopLimit {
+		p.Set("price", fmtF(req.Price))
+		tif := "GTC"
+		if req.TIF == core.IOC {
+			tif = "IOC"
+		}
+		p.Set("timeInForce", tif)
+	}
+	if req.Type == core.Stop || req.Type == core.StopLimit {
+		p.Set("stopPrice", fmtF(req.StopPrice))
+	}
+	if c.Futures {
+		p.Set("newOrderRespType", "RESULT")
+		switch req.Leg {
+		case core.LegLong, core.LegShort:
+			p.Set("positionSide", req.Leg.String()) // hedge mode: the side implies reduce
+		default:
+			if req.ReduceOnly {
+				p.Set("reduceOnly", "true")
+			}
+		}
+	} else {
+		p.Set("newOrderRespType", "FULL")
+	}
+	var r orderResp
+	if err := c.signed(ctx, http.MethodPost, c.path("/api/v3/order", "/fapi/v1/order"), p, &r); err != nil {
+		return core.OrderAck{}, err
+	}
+	return r.ack(), nil
+}
+
+func (r orderResp) ack() core.OrderAck {
+	ack := core.OrderAck{ID: strconv.FormatInt(r.OrderID, 10), Status: orderStatus(r.Status), ExecutedQty: parseF(r.ExecutedQty)}
+	if ack.ExecutedQty > 0 {
+		ack.AvgPrice = parseF(r.AvgPrice)
+		if ack.AvgPrice == 0 {
+			ack.AvgPrice = parseF(r.CummulativeQuoteQty) / ack.ExecutedQty
+		}
+	}
+	return ack
+}
+
+func (c *Client) Order(ctx context.Context, symbol, id string) (core.OrderAck, error) {
+	p := url.Values{}
+	p.Set("symbol", symbol)
+	p.Set("orderId", id)
+	var r orderResp
+	if err := c.signed(ctx, http.MethodGet, c.path("/api/v3/order", "/fapi/v1/order"), p, &r); err != nil {
+		return core.OrderAck{}, err
+	}
+	return r.ack(), nil
+}
+
+func (c *Client) CancelOrder(ctx context.Context, symbol, id string) error {
+	p := url.Values{}
+	p.Set("symbol", symbol)
+	p.Set("orderId", id)
+	return c.signed(ctx, http.MethodDelete, c.path("/api/v3/order", "/fapi/v1/order"), p, nil)
+}
+
+func (c *Client) OpenOrders(ctx context.Context, symbol string) ([]core.Order, error) {
+	p := url.Values{}
+	if symbol != "" {
+		p.Set("symbol", symbol)
+	}
+	var rows []orderResp
+	if err := c.signed(ctx, http.MethodGet, c.path("/api/v3/openOrders", "/fapi/v1/openOrders"), p, &rows); err != nil {
+		return nil, err
+	}
+	out := make([]core.Order, 0, len(rows))
+	for _, r := range rows {
+		o := core.Order{
+			ID:        strconv.FormatInt(r.OrderID, 10),
+			Symbol:    r.Symbol,
+			Side:      core.Buy,
+			Leg:       legOf(r.PositionSide),
+			Type:      coreType(r.Type),
+			Qty:       parseF(r.OrigQty),
+			Price:     parseF(r.Price),
+			StopPrice: parseF(r.StopPrice),
+			Status:    orderStatus(r.Status),
+			Created:   time.UnixMilli(r.Time).UTC(),
+		}
+		if r.Side == "SELL" {
+			o.Side = core.Sell
+		}
+		out = append(out, o)
+	}
+	return out, nil
+}
+
+func (c *Client) Balances(ctx context.Context) ([]core.Balance, error) {
+	if c.Futures {
+		var rows []struct {
+			Asset     string `json:"asset"`
+			Balance   string `json:"balance"`
+			Available string `json:"availableBalance"`
+		}
+		if err := c.signed(ctx, http.MethodGet, "/fapi/v2/balance", nil, &rows); err != nil {
+			return nil, err
+		}
+		var out []core.Balance
+		for _, r := range rows {
+			total, free := parseF(r.Balance), parseF(r.Available)
+			if total != 0 || free != 0 {
+				out = append(out, core.Balance{Asset: r.Asset, Free: free, Locked: total - free})
+			}
+		}
+		return out, nil
+	}
+	var acct struct {
+		Balances []struct {
+			Asset  string `json:"asset"`
+			Free   string `json:"free"`
+			Locked string `json:"locked"`
+		} `json:"balances"`
+	}
+	if err := c.signed(ctx, http.MethodGet, "/api/v3/account", nil, &acct); err != nil {
+		return nil, err
+	}
+	var out []core.Balance
+	for _, b := range acct.Balances {
+		free, locked := parseF(b.Free), parseF(b.Locked)
+		if free != 0 || locked != 0 {
+			out = append(out, core.Balance{Asset: b.Asset, Free: free, Locked: locked})
+		}
+	}
+	return out, nil
+}
+
+// Positions returns futures positions, or for spot every asset held in one of
+// Symbols worth at least MinNotional, as a long position in asset+Quote with
+// unknown entry.
+func (c *Client) Positions(ctx context.Context) ([]core.Position, error) {
+	if !c.Futures {
+		bals, err := c.Balances(ctx)
+		if err != nil {
+			return nil, err
+		}
+		var out []core.Position
+		for _, b := range bals {
+			sym := b.Asset + c.Quote
+			if b.Asset == c.Quote || len(c.Symbols) > 0 && !slices.Contains(c.Symbols, sym) {
+				continue
+			}
+			qty := b.Free + b.Locked
+			if c.MinNotional > 0 {
+				px, err := c.price(ctx, sym)
+				if err != nil {
+					var apiErr *APIError
+					if errors.As(err, &apiErr) && apiErr.Status == http.StatusBadRequest {
+						continue // no such market: not something the bot trades
+					}
+					return nil, err
+				}
+				if qty*px < c.MinNotional {
+					continue
+				}
+			}
+			out = append(out, core.Position{Symbol: sym, Side: core.Buy, Qty: qty})
+		}
+		return out, nil
+	}
+	var rows []struct {
+		Symbol       string `json:"symbol"`
+		PositionAmt  string `json:"positionAmt"`
+		EntryPrice   string `json:"entryPrice"`
+		Unrealized   string `json:"unRealizedProfit"`
+		LiqPrice     string `json:"liquidationPrice"`
+		PositionSide string `json:"positionSide"`
+		Isolated     string `json:"isolatedMargin"`
+	}
+	if err := c.signed(ctx, http.MethodGet, "/fapi/v2/positionRisk", nil, &rows); err != nil {
+		return nil, err
+	}
+	var out []core.Position
+	for _, r := range rows {
+		amt := parseF(r.PositionAmt)
+		if amt == 0 {
+			continue
+		}
+		p := core.Position{
+			Symbol:   r.Symbol,
+			Leg:      legOf(r.PositionSide),
+			Side:     core.Buy,
+			Qty:      amt,
+			Entry:    parseF(r.EntryPrice),
+			Unreal:   parseF(r.Unrealized),
+			Margin:   parseF(r.Isolated),
+			LiqPrice: parseF(r.LiqPrice),
+		}
+		if amt < 0 {
+			p.Side, p.Qty = core.Sell, -amt
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+// price is the last spot trade price of sym.
+func (c *Client) price(ctx context.Context, sym string) (float64, error) {
+	var r struct {
+		Price string `json:"price"`
+	}
+	if err := c.public(ctx, "/api/v3/ticker/price", url.Values{"symbol": {sym}}, &r); err != nil {
+		return 0, err
+	}
+	return parseF(r.Price), nil
+}
+
+func (c *Client) orderType(t core.OrderType) (string, error) {
+	switch t {
+	case core.Market:
+		return "MARKET", nil
+	case core.Limit:
+		return "LIMIT", nil
+	case core.Stop:
+		return c.path("STOP_LOSS", "STOP_MARKET"), nil
+	case core.StopLimit:
+		return c.path("STOP_LOSS_LIMIT", "STOP"), nil
+	}
+	return "", fmt.Errorf("unsupported order type %s", t)
+}
+
+func coreType(s string) core.OrderType {
+	switch s {
+	case "LIMIT", "LIMIT_MAKER":
+		return core.Limit
+	case "STOP_LOSS", "STOP_MARKET", "TAKE_PROFIT", "TAKE_PROFIT_MARKET":
+		return core.Stop
+	case "STOP_LOSS_LIMIT", "STOP", "TAKE_PROFIT_LIMIT":
+		return core.StopLimit
+	}
+	return core.Market
+}
+
+func orderStatus(s string) core.OrderStatus {
+	switch s {
+	case "FILLED":
+		return core.StatusFilled
+	case "CANCELED", "PENDING_CANCEL":
+		return core.StatusCanceled
+	case "REJECTED":
+		return core.StatusRejected
+	case "EXPIRED", "EXPIRED_IN_MATCH":
+		return core.StatusExpired
+	}
+	return core.StatusNew // NEW, PARTIALLY_FILLED
+}
+
+func sideName(a core.Action) string {
+	if a == core.Sell {
+		return "SELL"
+	}
+	return "BUY"
+}
+
+func legOf(positionSide string) core.Leg {
+	switch positionSide {
+	case "LONG":
+		return core.LegLong
+	case "SHORT":
+		return core.LegShort
+	}
+	return core.LegNet
+}
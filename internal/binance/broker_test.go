@@ -0,0 +1,190 @@
+package binance
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"tradebot/internal/core"
+)
+
+func TestPlaceOrderParams(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		futures bool
+		req     core.OrderRequest
+		route   string
+		want    map[string]string // "" asserts the parameter is absent
+	}{
+		{"spot limit", false,
+			core.OrderRequest{Symbol: "BTCUSDT", Side: core.Buy, Type: core.Limit, Qty: 0.123456789, Price: 30000.5},
+			"POST /api/v3/order",
+			map[string]string{"symbol": "BTCUSDT", "side": "BUY", "type": "LIMIT", "quantity": "0.12345679", "price": "30000.5",
+				"timeInForce": "GTC", "newOrderRespType": "FULL", "positionSide": "", "reduceOnly": ""}},
+		{"spot stop limit ioc", false,
+			core.OrderRequest{Symbol: "BTCUSDT", Side: core.Sell, Type: core.StopLimit, Qty: 1, Price: 29000, StopPrice: 29100, TIF: core.IOC},
+			"POST /api/v3/order",
+			map[string]string{"side": "SELL", "type": "STOP_LOSS_LIMIT", "price": "29000", "stopPrice": "29100", "timeInForce": "IOC"}},
+		{"futures market reduce only", true,
+			core.OrderRequest{Symbol: "ETHUSDT", Side: core.Sell, Type: core.Market, Qty: 2, ReduceOnly: true},
+			"POST /fapi/v1/order",
+			map[string]string{"type": "MARKET", "reduceOnly": "true", "newOrderRespType": "RESULT", "price": "", "timeInForce": "", "positionSide": ""}},
+		{"futures hedge leg", true,
+			core.OrderRequest{Symbol: "ETHUSDT", Side: core.Sell, Leg: core.LegLong, Type: core.Stop, Qty: 2, StopPrice: 1500, ReduceOnly: true},
+			"POST /fapi/v1/order",
+			map[string]string{"type": "STOP_MARKET", "positionSide": "LONG", "stopPrice": "1500", "reduceOnly": ""}},
+	} {
+		c, m := newMockExchange(t, tc.futures, map[string]http.HandlerFunc{
+			tc.route: reply(`{"orderId":42,"status":"NEW","executedQty":"0"}`),
+		})
+		ack, err := c.PlaceOrder(context.Background(), tc.req)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if ack.ID != "42" || ack.Status != core.StatusNew {
+			t.Errorf("%s: ack %+v", tc.name, ack)
+		}
+		q := m.last(t, tc.route)
+		for k, want := range tc.want {
+			if got := q.Get(k); got != want || want == "" && q.Has(k) {
+				t.Errorf("%s: %s=%q, want %q", tc.name, k, got, want)
+			}
+		}
+	}
+}
+
+func TestPlaceOrderRejectsUnsupportedTypes(t *testing.T) {
+	c, _ := newMockExchange(t, false, nil)
+	if _, err := c.PlaceOrder(context.Background(), core.OrderRequest{Symbol: "BTCUSDT", Type: core.OrderType(99), Qty: 1}); err == nil {
+		t.Fatal("placed an order of an unknown type")
+	}
+}
+
+func TestOrderQueryAndCancel(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		futures bool
+		path    string
+		resp    string
+		want    core.OrderAck
+	}{
+		{"spot average from quote qty", false, "/api/v3/order",
+			`{"orderId":7,"status":"PARTIALLY_FILLED","executedQty":"0.5","cummulativeQuoteQty":"15000","avgPrice":""}`,
+			core.OrderAck{ID: "7", Status: core.StatusNew, ExecutedQty: 0.5, AvgPrice: 30000}},
+		{"futures average price", true, "/fapi/v1/order",
+			`{"orderId":7,"status":"FILLED","executedQty":"2","avgPrice":"1510.25"}`,
+			core.OrderAck{ID: "7", Status: core.StatusFilled, ExecutedQty: 2, AvgPrice: 1510.25}},
+		{"expired unfilled", false, "/api/v3/order",
+			`{"orderId":7,"status":"EXPIRED","executedQty":"0","cummulativeQuoteQty":"0"}`,
+			core.OrderAck{ID: "7", Status: core.StatusExpired}},
+	} {
+		c, m := newMockExchange(t, tc.futures, map[string]http.HandlerFunc{
+			"GET " + tc.path:    reply(tc.resp),
+			"DELETE " + tc.path: reply(`{"orderId":7,"status":"CANCELED"}`),
+		})
+		ack, err := c.Order(context.Background(), "BTCUSDT", "7")
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if ack != tc.want {
+			t.Errorf("%s: ack %+v, want %+v", tc.name, ack, tc.want)
+		}
+		if q := m.last(t, "GET "+tc.path); q.Get("symbol") != "BTCUSDT" || q.Get("orderId") != "7" {
+			t.Errorf("%s: query %v", tc.name, q)
+		}
+		if err := c.CancelOrder(context.Background(), "BTCUSDT", "7"); err != nil {
+			t.Fatalf("%s: cancel: %v", tc.name, err)
+		}
+		if q := m.last(t, "DELETE "+tc.path); q.Get("symbol") != "BTCUSDT" || q.Get("orderId") != "7" {
+			t.Errorf("%s: cancel %v", tc.name, q)
+		}
+	}
+}
+
+func TestOpenOrders(t *testing.T) {
+	c, m := newMockExchange(t, true, map[string]http.HandlerFunc{
+		"GET /fapi/v1/openOrders": reply(`[
+			{"orderId":1,"symbol":"ETHUSDT","status":"NEW","side":"SELL","type":"LIMIT","positionSide":"LONG","price":"1600","origQty":"2","time":1700000000000},
+			{"orderId":2,"symbol":"ETHUSDT","status":"NEW","side":"BUY","type":"STOP_MARKET","positionSide":"BOTH","stopPrice":"1400","origQty":"1","time":1700000000000}]`),
+	})
+	orders, err := c.OpenOrders(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q := m.last(t, "GET /fapi/v1/openOrders"); q.Has("symbol") {
+		t.Errorf("all symbols asked with symbol=%s", q.Get("symbol"))
+	}
+	created := time.UnixMilli(1700000000000).UTC()
+	want := []core.Order{
+		{ID: "1", Symbol: "ETHUSDT", Side: core.Sell, Leg: core.LegLong, Type: core.Limit, Qty: 2, Price: 1600, Status: core.StatusNew, Created: created},
+		{ID: "2", Symbol: "ETHUSDT", Side: core.Buy, Leg: core.LegNet, Type: core.Stop, Qty: 1, StopPrice: 1400, Status: core.StatusNew, Created: created},
+	}
+	if len(orders) != len(want) {
+		t.Fatalf("orders %+v", orders)
+	}
+	for i := range want {
+		if orders[i] != want[i] {
+			t.Errorf("order %d: %+v, want %+v", i, orders[i], want[i])
+		}
+	}
+}
+
+func TestSpotPositionsSkipDustAndUntradedAssets(t *testing.T) {
+	c, _ := newMockExchange(t, false, map[string]http.HandlerFunc{
+		"GET /api/v3/account": reply(`{"balances":[
+			{"asset":"USDT","free":"500","locked":"0"},
+			{"asset":"BTC","free":"0.01","locked":"0.02"},
+			{"asset":"ETH","free":"0.001","locked":"0"},
+			{"asset":"LDBTC","free":"1","locked":"0"},
+			{"asset":"BNB","free":"0","locked":"0"}]}`),
+		"GET /api/v3/ticker/price": func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Query().Get("symbol") {
+			case "BTCUSDT":
+				w.Write([]byte(`{"price":"30000"}`))
+			case "ETHUSDT":
+				w.Write([]byte(`{"price":"2000"}`))
+			default:
+				fail(400, `{"code":-1121,"msg":"Invalid symbol."}`)(w, r)
+			}
+		},
+	})
+	pos, err := c.Positions(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pos) != 1 || pos[0] != (core.Position{Symbol: "BTCUSDT", Side: core.Buy, Qty: 0.03}) {
+		t.Fatalf("positions %+v, want only 0.03 BTCUSDT", pos)
+	}
+
+	c.Symbols = []string{"ETHUSDT"}
+	c.MinNotional = 0
+	if pos, err = c.Positions(context.Background()); err != nil || len(pos) != 1 || pos[0].Symbol != "ETHUSDT" {
+		t.Fatalf("positions in ETHUSDT only: %+v %v", pos, err)
+	}
+}
+
+func TestFuturesPositions(t *testing.T) {
+	c, _ := newMockExchange(t, true, map[string]http.HandlerFunc{
+		"GET /fapi/v2/positionRisk": reply(`[
+			{"symbol":"ETHUSDT","positionAmt":"-2","entryPrice":"1500","unRealizedProfit":"-20","liquidationPrice":"2200","positionSide":"BOTH","isolatedMargin":"300"},
+			{"symbol":"BTCUSDT","positionAmt":"0.5","entryPrice":"30000","unRealizedProfit":"100","liquidationPrice":"20000","positionSide":"LONG","isolatedMargin":"0"},
+			{"symbol":"BTCUSDT","positionAmt":"0","entryPrice":"0","positionSide":"SHORT"}]`),
+	})
+	pos, err := c.Positions(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []core.Position{
+		{Symbol: "ETHUSDT", Side: core.Sell, Qty: 2, Entry: 1500, Unreal: -20, Margin: 300, LiqPrice: 2200},
+		{Symbol: "BTCUSDT", Leg: core.LegLong, Side: core.Buy, Qty: 0.5, Entry: 30000, Unreal: 100, LiqPrice: 20000},
+	}
+	if len(pos) != len(want) {
+		t.Fatalf("positions %+v", pos)
+	}
+	for i := range want {
+		if pos[i] != want[i] {
+			t.Errorf("position %d: %+v, want %+v", i, pos[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,155 @@
+// Package binance is a signed-REST client for Binance spot and USDⓈ-M
+// futures accounts. It implements core.Broker.
+package binance
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SpotURL    = "https://api.binance.com"
+	FuturesURL = "https://fapi.binance.com"
+)
+
+// Client talks to one market. BaseURL can point at a testnet or a local
+// mock exchange speaking the same API.
+type Client struct {
+	BaseURL string
+	APIKey  string
+	Secret  string
+	Futures bool   // USDⓈ-M futures (/fapi) instead of spot (/api)
+	Quote   string // quote asset for spot positions (default USDT)
+	// Spot balances count as positions only in Symbols (empty: any
+	// asset+Quote) and from MinNotional of quote value (default 10); the
+	// rest is dust or holdings the bot does not trade.
+	Symbols     []string
+	MinNotional float64
+	RecvWindow  time.Duration // default 5s
+	HTTP        *http.Client
+}
+
+func NewClient(baseURL, apiKey, secret string, futures bool) *Client {
+	if baseURL == "" {
+		baseURL = SpotURL
+		if futures {
+			baseURL = FuturesURL
+		}
+	}
+	return &Client{
+		BaseURL:     strings.TrimRight(baseURL, "/"),
+		APIKey:      apiKey,
+		Secret:      secret,
+		Futures:     futures,
+		Quote:       "USDT",
+		MinNotional: 10,
+		RecvWindow:  5 * time.Second,
+		HTTP:        &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// APIError is an error response from the exchange.
+type APIError struct {
+	Status int
+	Code   int    `json:"code"`
+	Msg    string `json:"msg"`
+	// RetryAfter is the wait the exchange asks for when rate limited.
+	RetryAfter time.Duration `json:"-"`
+}
+
+func (e *APIError) Error() string {
+	if e.RateLimited() {
+		return fmt.Sprintf("binance %d: rate limited, retry after %s: code=%d %s", e.Status, e.RetryAfter, e.Code, e.Msg)
+	}
+	return fmt.Sprintf("binance %d: code=%d %s", e.Status, e.Code, e.Msg)
+}
+
+// RateLimited reports a request weight or order rate limit: 429, or 418
+// once the IP is banned for ignoring 429s.
+func (e *APIError) RateLimited() bool {
+	return e.Status == http.StatusTooManyRequests || e.Status == http.StatusTeapot
+}
+
+// signed sends a USER_DATA/TRADE request: params plus timestamp and
+// recvWindow, signed with HMAC-SHA256 of the query string.
+func (c *Client) signed(ctx context.Context, method, path string, params url.Values, out any) error {
+	if params == nil {
+		params = url.Values{}
+	}
+	params.Set("timestamp", strconv.FormatInt(time.Now().UnixMilli(), 10))
+	params.Set("recvWindow", strconv.FormatInt(c.RecvWindow.Milliseconds(), 10))
+	q := params.Encode()
+	mac := hmac.New(sha256.New, []byte(c.Secret))
+	mac.Write([]byte(q))
+	q += "&signature=" + hex.EncodeToString(mac.Sum(nil))
+
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path+"?"+q, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-MBX-APIKEY", c.APIKey)
+	return c.send(req, out)
+}
+
+// public sends an unsigned MARKET_DATA request.
+func (c *Client) public(ctx context.Context, path string, params url.Values, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+path+"?"+params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	return c.send(req, out)
+}
+
+func (c *Client) send(req *http.Request, out any) error {
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		apiErr := &APIError{Status: resp.StatusCode}
+		if json.Unmarshal(body, apiErr) != nil || apiErr.Msg == "" {
+			apiErr.Msg = strings.TrimSpace(string(body))
+		}
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			apiErr.RetryAfter = time.Duration(secs) * time.Second
+		}
+		return apiErr
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(body, out)
+}
+
+// path maps an endpoint to the market's API prefix and version.
+func (c *Client) path(spot, futures string) string {
+	if c.Futures {
+		return futures
+	}
+	return spot
+}
+
+// fmtF formats a price or quantity with at most 8 decimals, the finest
+// precision Binance accepts. Symbol tick and lot filters are not applied.
+func fmtF(f float64) string { return strconv.FormatFloat(math.Round(f*1e8)/1e8, 'f', -1, 64) }
+
+func parseF(s string) float64 {
+	f, _ := strconv.ParseFloat(s, 64)
+	return f
+}
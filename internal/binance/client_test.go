@@ -0,0 +1,164 @@
+package binance
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testKey    = "KEY"
+	testSecret = "SECRET"
+)
+
+// mockExchange is a Binance REST server. Like the exchange it rejects signed
+// requests with a bad key or signature; the rest it records and answers from
+// routes by "METHOD path", or with a 404 exchange error.
+type mockExchange struct {
+	routes map[string]http.HandlerFunc
+
+	mu   sync.Mutex
+	reqs []*http.Request
+}
+
+func newMockExchange(t *testing.T, futures bool, routes map[string]http.HandlerFunc) (*Client, *mockExchange) {
+	t.Helper()
+	m := &mockExchange{routes: routes}
+	srv := httptest.NewServer(m)
+	t.Cleanup(srv.Close)
+	return NewClient(srv.URL, testKey, testSecret, futures), m
+}
+
+func (m *mockExchange) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Has("signature") {
+		if err := checkSigned(r); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"code":-1022,"msg":"` + err.Error() + `"}`))
+			return
+		}
+	}
+	m.mu.Lock()
+	m.reqs = append(m.reqs, r)
+	m.mu.Unlock()
+	h, ok := m.routes[r.Method+" "+r.URL.Path]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"code":-1,"msg":"no route"}`))
+		return
+	}
+	h(w, r)
+}
+
+// last returns the query of the last accepted request to "METHOD path".
+func (m *mockExchange) last(t *testing.T, route string) url.Values {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := len(m.reqs) - 1; i >= 0; i-- {
+		if r := m.reqs[i]; r.Method+" "+r.URL.Path == route {
+			return r.URL.Query()
+		}
+	}
+	t.Fatalf("no request %s", route)
+	return nil
+}
+
+// checkSigned verifies a signed request the way the exchange does: the API
+// key header, the recvWindow and timestamp, and the HMAC of the query
+// string before the signature.
+func checkSigned(r *http.Request) error {
+	if got := r.Header.Get("X-MBX-APIKEY"); got != testKey {
+		return errors.New("API-key format invalid")
+	}
+	q, sig, ok := strings.Cut(r.URL.RawQuery, "&signature=")
+	if !ok {
+		return errors.New("signature is not the last parameter")
+	}
+	mac := hmac.New(sha256.New, []byte(testSecret))
+	mac.Write([]byte(q))
+	if sig != hex.EncodeToString(mac.Sum(nil)) {
+		return errors.New("Signature for this request is not valid.")
+	}
+	v, _ := url.ParseQuery(q)
+	if v.Get("recvWindow") != "5000" {
+		return errors.New("recvWindow " + v.Get("recvWindow"))
+	}
+	if d := time.Since(time.UnixMilli(int64(parseF(v.Get("timestamp"))))); d < 0 || d > time.Minute {
+		return errors.New("Timestamp for this request is outside of the recvWindow.")
+	}
+	return nil
+}
+
+func reply(body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(body)) }
+}
+
+func fail(status int, body string, header ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i+1 < len(header); i += 2 {
+			w.Header().Set(header[i], header[i+1])
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}
+}
+
+func TestSignedRequestsCarryKeyAndSignature(t *testing.T) {
+	c, m := newMockExchange(t, false, map[string]http.HandlerFunc{
+		"GET /api/v3/account": reply(`{"balances":[{"asset":"USDT","free":"100","locked":"0"}]}`),
+	})
+	if _, err := c.Balances(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	m.last(t, "GET /api/v3/account")
+
+	for name, bad := range map[string]*Client{
+		"secret": {BaseURL: c.BaseURL, APIKey: testKey, Secret: "WRONG", RecvWindow: c.RecvWindow, HTTP: c.HTTP},
+		"key":    {BaseURL: c.BaseURL, APIKey: "WRONG", Secret: testSecret, RecvWindow: c.RecvWindow, HTTP: c.HTTP},
+	} {
+		var apiErr *APIError
+		if _, err := bad.Balances(context.Background()); !errors.As(err, &apiErr) || apiErr.Status != http.StatusUnauthorized || apiErr.Code != -1022 {
+			t.Errorf("wrong %s: %v, want a -1022 APIError", name, err)
+		}
+	}
+}
+
+func TestErrorMapping(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		h       http.HandlerFunc
+		status  int
+		code    int
+		msg     string
+		limited bool
+		retry   time.Duration
+	}{
+		{"exchange error", fail(400, `{"code":-2010,"msg":"Account has insufficient balance for requested action."}`),
+			400, -2010, "Account has insufficient balance for requested action.", false, 0},
+		{"plain body", fail(502, "Bad Gateway\n"), 502, 0, "Bad Gateway", false, 0},
+		{"rate limit", fail(429, `{"code":-1003,"msg":"Too many requests."}`, "Retry-After", "7"),
+			429, -1003, "Too many requests.", true, 7 * time.Second},
+		{"ip ban", fail(418, `{"code":-1003,"msg":"Way too many requests; IP banned."}`, "Retry-After", "120"),
+			418, -1003, "Way too many requests; IP banned.", true, 2 * time.Minute},
+	} {
+		c, _ := newMockExchange(t, false, map[string]http.HandlerFunc{"DELETE /api/v3/order": tc.h})
+		err := c.CancelOrder(context.Background(), "BTCUSDT", "1")
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) {
+			t.Fatalf("%s: %v, want an APIError", tc.name, err)
+		}
+		if apiErr.Status != tc.status || apiErr.Code != tc.code || apiErr.Msg != tc.msg ||
+			apiErr.RateLimited() != tc.limited || apiErr.RetryAfter != tc.retry {
+			t.Errorf("%s: %+v rate limited %v", tc.name, *apiErr, apiErr.RateLimited())
+		}
+	}
+}
@@ -15,8 +15,23 @@ type Config struct {
 	TradesPath  string
 
 	StatePath    string
+	StateSave    string // account snapshot interval
 	Exchange     string
 	RestInterval string
+	Intrabar     string
+	Opposite     string
+	AccountMode  string
+	MarginMode   string
+	Leverage     float64
+	MaintRate    float64
+	Funding      string // "" (off) | "binance" | path to a funding file
+	FundingURL   string
+	APIKey       string
+	APISecret    string
+	BrokerURL    string // live broker REST base URL ("" = Binance production)
+	MarketURL    string // rest feed and warmup history base URL ("" = Binance production)
+	Reconcile    string // startup reconciliation policy: alert | adopt | cancel
+	CandleCache  string // warmup history cache dir ("" = always download)
 }
 
 func getenv(key, def string) string {
@@ -49,7 +64,22 @@ func Load() Config {
 		TradesPath:  getenv("TRADES_PATH", "trades.csv"),
 
 		StatePath:    getenv("STATE_PATH", "state.json"),
+		StateSave:    getenv("STATE_SAVE_INTERVAL", "1m"),
 		Exchange:     getenv("EXCHANGE", "binance"),
 		RestInterval: getenv("REST_INTERVAL", "3s"),
+		Intrabar:     getenv("INTRABAR", "stop_first"),
+		Opposite:     getenv("OPPOSITE", "reverse"),
+		AccountMode:  getenv("ACCOUNT_MODE", "oneway"),
+		MarginMode:   getenv("MARGIN_MODE", ""),
+		Leverage:     getfloat("LEVERAGE", 1),
+		MaintRate:    getfloat("MAINT_MARGIN_RATE", 0.004),
+		Funding:      getenv("FUNDING", ""),
+		FundingURL:   getenv("FUNDING_URL", ""),
+		APIKey:       getenv("BINANCE_API_KEY", ""),
+		APISecret:    getenv("BINANCE_API_SECRET", ""),
+		BrokerURL:    getenv("BROKER_URL", ""),
+		MarketURL:    getenv("MARKET_URL", ""),
+		Reconcile:    getenv("RECONCILE", "alert"),
+		CandleCache:  getenv("CANDLE_CACHE", "candles"),
 	}
 }
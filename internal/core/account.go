@@ -0,0 +1,102 @@
+package core
+
+import (
+	"fmt"
+	"time"
+)
+
+// AccountSnapshot is the persistent part of an Engine: everything needed to
+// resume a paper account after a restart.
+type AccountSnapshot struct {
+	EquityUSD   float64              `json:"equity"`
+	Positions   []PositionState      `json:"positions,omitempty"`
+	Orders      []Order              `json:"orders,omitempty"`
+	OrderSeq    int                  `json:"orderSeq,omitempty"`
+	LastPrices  map[string]float64   `json:"lastPrices,omitempty"`
+	LastFunding map[string]time.Time `json:"lastFunding,omitempty"`
+	LastSymbol  string               `json:"lastSymbol,omitempty"`
+	SavedAt     time.Time            `json:"savedAt"`
+}
+
+// PositionState is one open position with its protective levels.
+type PositionState struct {
+	Symbol string  `json:"symbol"`
+	Leg    Leg     `json:"leg,omitempty"`
+	Side   Action  `json:"side"`
+	Qty    float64 `json:"qty"`
+	Entry  float64 `json:"entry"`
+	SL     float64 `json:"sl,omitempty"`
+	TP     float64 `json:"tp,omitempty"`
+	Margin float64 `json:"margin,omitempty"`
+}
+
+// Export snapshots the account for persistence.
+func (e *Engine) Export() AccountSnapshot {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	s := AccountSnapshot{
+		EquityUSD:   e.eqUSD,
+		OrderSeq:    e.orders.seq,
+		LastPrices:  map[string]float64{},
+		LastFunding: map[string]time.Time{},
+		LastSymbol:  e.lastSym,
+		SavedAt:     e.clock.Now(),
+	}
+	for _, k := range e.book.keys() {
+		p := e.book.get(k)
+		s.Positions = append(s.Positions, PositionState{Symbol: k.sym, Leg: k.leg, Side: p.side, Qty: p.qty, Entry: p.entry, SL: p.sl, TP: p.tp, Margin: p.margin})
+	}
+	for _, o := range e.orders.open {
+		s.Orders = append(s.Orders, *o)
+	}
+	for sym, px := range e.lastPx {
+		s.LastPrices[sym] = px
+	}
+	for sym, t := range e.lastFunding {
+		s.LastFunding[sym] = t
+	}
+	return s
+}
+
+// Restore replaces the account with s. It fails without touching the engine
+// when s holds positions the account mode cannot represent.
+func (e *Engine) Restore(s AccountSnapshot) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	book := newPositionBook()
+	for _, p := range s.Positions {
+		if p.Side != Buy && p.Side != Sell || p.Qty <= 0 {
+			return fmt.Errorf("position %s leg %q: bad side or qty", p.Symbol, p.Leg)
+		}
+		if (e.acctMode == Hedge) != (p.Leg != LegNet) || p.Leg != LegNet && p.Leg.side() != p.Side {
+			return fmt.Errorf("position %s leg %q does not fit the account mode", p.Symbol, p.Leg)
+		}
+		k := posKey{p.Symbol, p.Leg}
+		if book.get(k) != nil {
+			return fmt.Errorf("duplicate position %s leg %q", p.Symbol, p.Leg)
+		}
+		np := book.set(k, p.Side, p.Qty, p.Entry)
+		np.sl, np.tp, np.margin = p.SL, p.TP, p.Margin
+	}
+	orders := &orderBook{seq: s.OrderSeq}
+	for _, o := range s.Orders {
+		o := o
+		if err := validateOrderPrices(o); err != nil {
+			return fmt.Errorf("order %s: %w", o.ID, err)
+		}
+		orders.open = append(orders.open, &o)
+	}
+	e.eqUSD = s.EquityUSD
+	e.book = book
+	e.orders = orders
+	e.lastPx = map[string]float64{}
+	for sym, px := range s.LastPrices {
+		e.lastPx[sym] = px
+	}
+	e.lastFunding = map[string]time.Time{}
+	for sym, t := range s.LastFunding {
+		e.lastFunding[sym] = t
+	}
+	e.lastSym = s.LastSymbol
+	return nil
+}
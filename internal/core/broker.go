@@ -0,0 +1,291 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Broker executes orders against an exchange account.
+type Broker interface {
+	Name() string
+	PlaceOrder(ctx context.Context, req OrderRequest) (OrderAck, error)
+	CancelOrder(ctx context.Context, symbol, id string) error
+	OpenOrders(ctx context.Context, symbol string) ([]Order, error) // "" = all symbols
+	// Order reports a placed order's status and what it has filled so far.
+	Order(ctx context.Context, symbol, id string) (OrderAck, error)
+	Balances(ctx context.Context) ([]Balance, error)
+	Positions(ctx context.Context) ([]Position, error)
+}
+
+type OrderRequest struct {
+	Symbol     string
+	Side       Action // Buy | Sell
+	Leg        Leg    // hedge mode position side
+	Type       OrderType
+	Qty        float64
+	Price      float64 // limit price; reference price for paper market orders
+	StopPrice  float64
+	TIF        TimeInForce
+	ReduceOnly bool
+}
+
+type OrderAck struct {
+	ID          string
+	Status      OrderStatus
+	ExecutedQty float64 // cumulative
+	AvgPrice    float64 // of ExecutedQty
+}
+
+type Balance struct {
+	Asset  string
+	Free   float64
+	Locked float64
+}
+
+// brokerTimeout bounds every broker call the engine makes under its lock.
+const brokerTimeout = 10 * time.Second
+
+// execute sends a market order for qty of k and reports the filled quantity
+// and average price. Paper accounts fill all of qty at px.
+func (e *Engine) execute(ts time.Time, k posKey, tf string, side Action, qty, px float64, reduce bool) (float64, float64, bool) {
+	if e.live == nil {
+		return qty, px, true
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), brokerTimeout)
+	defer cancel()
+	ack, err := e.live.PlaceOrder(ctx, OrderRequest{Symbol: k.sym, Side: side, Leg: k.leg, Type: Market, Qty: qty, ReduceOnly: reduce})
+	if err != nil {
+		e.bus.Publish(ExecutionFailed{TS: ts, Symbol: k.sym, TF: tf, Side: side, Qty: qty, Err: err})
+		return 0, 0, false
+	}
+	if ack.ExecutedQty <= 0 {
+		e.bus.Publish(ExecutionFailed{TS: ts, Symbol: k.sym, TF: tf, Side: side, Qty: qty, Err: fmt.Errorf("order %s not filled (%s)", ack.ID, ack.Status)})
+		return 0, 0, false
+	}
+	if ack.AvgPrice <= 0 {
+		ack.AvgPrice = px
+	}
+	return ack.ExecutedQty, ack.AvgPrice, true
+}
+
+// placeLive sends a resting order to the live broker. The exchange matches
+// it; the book keeps a copy so that its fills, polled by syncLive, reach the
+// account and the strategy.
+func (e *Engine) placeLive(ts time.Time, tf string, o Order) (string, error) {
+	if o.Qty <= 0 {
+		o.Qty = e.sizeUSD(o.SizePct) / o.refPrice()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), brokerTimeout)
+	defer cancel()
+	ack, err := e.live.PlaceOrder(ctx, OrderRequest{
+		Symbol: o.Symbol, Side: o.Side, Leg: e.legFor(o.Leg, o.Side), Type: o.Type,
+		Qty: o.Qty, Price: o.Price, StopPrice: o.StopPrice, TIF: o.TIF,
+	})
+	if err != nil {
+		e.bus.Publish(ExecutionFailed{TS: ts, Symbol: o.Symbol, TF: tf, Side: o.Side, Qty: o.Qty, Err: err})
+		return "", err
+	}
+	o.ID, o.Status, o.Created = ack.ID, StatusNew, ts
+	e.notify(ts, "%s %s %s placed at %s | px=%.2f stop=%.2f %s", o.ID, o.Type, actionName(o.Side), e.live.Name(), o.Price, o.StopPrice, o.Comment)
+	e.bus.Publish(OrderPlaced{TS: ts, TF: tf, Order: o})
+	e.orders.open = append(e.orders.open, &o)
+	e.applyLive(ts, tf, &o, ack) // a marketable limit may fill at once
+	return o.ID, nil
+}
+
+// syncLive polls the exchange for the book's orders in sym and applies what
+// they filled since the last poll.
+func (e *Engine) syncLive(ts time.Time, sym, tf string) {
+	for _, o := range e.orders.pending(sym) {
+		ctx, cancel := context.WithTimeout(context.Background(), brokerTimeout)
+		ack, err := e.live.Order(ctx, o.Symbol, o.ID)
+		cancel()
+		if err != nil {
+			e.notify(ts, "order %s: %v", o.ID, err)
+			continue
+		}
+		e.applyLive(ts, tf, o, ack)
+	}
+}
+
+// applyLive books the part of ack's fill the account has not seen yet and
+// drops o from the book once the exchange is done with it.
+func (e *Engine) applyLive(ts time.Time, tf string, o *Order, ack OrderAck) {
+	if d := ack.ExecutedQty - o.Filled; d > qtyEps {
+		px := ack.AvgPrice
+		if o.Filled > 0 { // the new part's price, from the cumulative average
+			px = (ack.AvgPrice*ack.ExecutedQty - o.AvgPrice*o.Filled) / d
+		}
+		o.Filled, o.AvgPrice = ack.ExecutedQty, ack.AvgPrice
+		k := posKey{o.Symbol, e.legFor(o.Leg, o.Side)}
+		if !e.fill(ts, k, tf, o.Side, d, px, o.SL, o.TP, o.Comment, o) {
+			e.notify(ts, "order %s: exchange fill of %.6f @ %.2f does not fit the local account", o.ID, d, px)
+		}
+		if ack.Status == StatusNew {
+			e.emitOrder(ts, tf, o, EvOrderPartial)
+		}
+	}
+	switch ack.Status {
+	case StatusNew:
+	case StatusFilled:
+		o.Status = StatusFilled
+		e.orders.remove(o.ID)
+		e.bus.Publish(OrderFilled{TS: ts, TF: tf, Order: *o, Price: o.AvgPrice})
+	case StatusCanceled:
+		e.closeOrder(ts, tf, o, StatusCanceled, EvOrderCanceled)
+	case StatusExpired:
+		e.closeOrder(ts, tf, o, StatusExpired, EvOrderExpired)
+	default:
+		e.closeOrder(ts, tf, o, ack.Status, EvOrderRejected)
+	}
+}
+
+// cancelLive cancels open exchange orders in sym ("" = all) matching id ("" = all).
+func (e *Engine) cancelLive(ts time.Time, sym, tf, id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), brokerTimeout)
+	defer cancel()
+	open, err := e.live.OpenOrders(ctx, sym)
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, o := range open {
+		if id != "" && o.ID != id {
+			continue
+		}
+		found = true
+		if err := e.live.CancelOrder(ctx, o.Symbol, o.ID); err != nil {
+			return err
+		}
+		if own := e.orders.find(o.ID); own != nil {
+			// book what filled before the cancel took effect
+			ack, err := e.live.Order(ctx, o.Symbol, o.ID)
+			if err != nil {
+				ack = OrderAck{Status: StatusCanceled, ExecutedQty: own.Filled, AvgPrice: own.AvgPrice}
+			}
+			ack.Status = StatusCanceled
+			e.applyLive(ts, tf, own, ack)
+			continue
+		}
+		o.Status = StatusCanceled
+		e.emitOrder(ts, tf, &o, EvOrderCanceled)
+	}
+	if id != "" && !found {
+		return fmt.Errorf("order %s not found", id)
+	}
+	return nil
+}
+
+func (e *Engine) liveOrders(sym string) []Order {
+	ctx, cancel := context.WithTimeout(context.Background(), brokerTimeout)
+	defer cancel()
+	open, err := e.live.OpenOrders(ctx, sym)
+	if err != nil {
+		e.notify(e.clock.Now(), "open orders: %v", err)
+		return nil
+	}
+	return open
+}
+
+// MarketOrder executes a market order at the last price of req.Symbol (paper)
+// or at the live broker. ReduceOnly orders never open or grow a position.
+func (e *Engine) MarketOrder(req OrderRequest) (OrderAck, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if req.Side != Buy && req.Side != Sell {
+		return OrderAck{}, errors.New("order side must be buy or sell")
+	}
+	if e.reconHold() && !req.ReduceOnly {
+		return OrderAck{}, errReconHold
+	}
+	if e.trading != TradingRunning && !req.ReduceOnly {
+		return OrderAck{}, fmt.Errorf("trading %s: only reduce-only orders are accepted", e.trading)
+	}
+	px := e.lastPx[req.Symbol]
+	if px <= 0 {
+		px = req.Price
+	}
+	if px <= 0 || req.Qty <= 0 {
+		return OrderAck{}, fmt.Errorf("%s: need a positive qty and a price", req.Symbol)
+	}
+	k := posKey{req.Symbol, e.legFor(req.Leg, req.Side)}
+	if e.acctMode == Hedge && req.ReduceOnly && req.Leg == LegNet {
+		k.leg = LegShort // a reduce-only buy closes the short leg
+		if req.Side == Sell {
+			k.leg = LegLong
+		}
+	}
+	qty := req.Qty
+	if req.ReduceOnly {
+		p := e.book.get(k)
+		if p == nil || p.side == req.Side {
+			return OrderAck{}, fmt.Errorf("%s: nothing to reduce", req.Symbol)
+		}
+		qty = minf(qty, p.qty)
+	}
+	ts := e.clock.Now()
+	qty, px, ok := e.execute(ts, k, "", req.Side, qty, px, req.ReduceOnly)
+	if !ok {
+		return OrderAck{}, fmt.Errorf("%s: market order failed", req.Symbol)
+	}
+	if !e.fill(ts, k, "", req.Side, qty, px, nil, nil, "manual", nil) {
+		return OrderAck{}, fmt.Errorf("%s: market order rejected", req.Symbol)
+	}
+	return OrderAck{Status: StatusFilled, ExecutedQty: qty, AvgPrice: px}, nil
+}
+
+var _ Broker = (*PaperBroker)(nil)
+
+// PaperBroker exposes an Engine's simulated account through the Broker
+// interface, so callers can treat paper and live accounts alike.
+type PaperBroker struct {
+	e     *Engine
+	quote string
+}
+
+func NewPaperBroker(e *Engine) *PaperBroker { return &PaperBroker{e: e, quote: "USDT"} }
+
+func (b *PaperBroker) Name() string { return "paper" }
+
+func (b *PaperBroker) PlaceOrder(_ context.Context, req OrderRequest) (OrderAck, error) {
+	if req.Type == Market {
+		return b.e.MarketOrder(req)
+	}
+	id, err := b.e.PlaceOrder(Order{
+		Symbol: req.Symbol, Side: req.Side, Leg: req.Leg, Type: req.Type, Qty: req.Qty,
+		Price: req.Price, StopPrice: req.StopPrice, TIF: req.TIF,
+	})
+	if err != nil {
+		return OrderAck{}, err
+	}
+	return OrderAck{ID: id, Status: StatusNew}, nil
+}
+
+func (b *PaperBroker) CancelOrder(_ context.Context, _, id string) error {
+	return b.e.CancelOrder(id)
+}
+
+func (b *PaperBroker) Order(_ context.Context, _, id string) (OrderAck, error) {
+	for _, o := range b.e.Orders("") {
+		if o.ID == id {
+			return OrderAck{ID: id, Status: o.Status}, nil
+		}
+	}
+	return OrderAck{}, fmt.Errorf("order %s is not open", id)
+}
+
+func (b *PaperBroker) OpenOrders(_ context.Context, symbol string) ([]Order, error) {
+	return b.e.Orders(symbol), nil
+}
+
+// Balances reports the paper equity in the quote asset; posted margin is locked.
+func (b *PaperBroker) Balances(context.Context) ([]Balance, error) {
+	s := b.e.Snapshot()
+	return []Balance{{Asset: b.quote, Free: s.EquityUSD - s.MarginUsed, Locked: s.MarginUsed}}, nil
+}
+
+func (b *PaperBroker) Positions(context.Context) ([]Position, error) {
+	return b.e.Snapshot().Positions, nil
+}
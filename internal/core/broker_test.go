@@ -0,0 +1,113 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+// fakeBroker fills market orders at once at the requested price and keeps
+// resting orders open until the test sets their fill.
+type fakeBroker struct {
+	seq  int
+	acks map[string]OrderAck
+	open map[string]Order
+}
+
+func newFakeBroker() *fakeBroker {
+	return &fakeBroker{acks: map[string]OrderAck{}, open: map[string]Order{}}
+}
+
+func (b *fakeBroker) Name() string { return "fake" }
+
+func (b *fakeBroker) PlaceOrder(_ context.Context, req OrderRequest) (OrderAck, error) {
+	b.seq++
+	id := fmt.Sprint(b.seq)
+	if req.Type == Market {
+		return OrderAck{ID: id, Status: StatusFilled, ExecutedQty: req.Qty, AvgPrice: req.Price}, nil
+	}
+	b.acks[id] = OrderAck{ID: id, Status: StatusNew}
+	b.open[id] = Order{ID: id, Symbol: req.Symbol, Side: req.Side, Type: req.Type, Qty: req.Qty, Price: req.Price}
+	return b.acks[id], nil
+}
+
+func (b *fakeBroker) CancelOrder(_ context.Context, _, id string) error {
+	ack := b.acks[id]
+	ack.Status = StatusCanceled
+	b.acks[id] = ack
+	delete(b.open, id)
+	return nil
+}
+
+func (b *fakeBroker) OpenOrders(context.Context, string) ([]Order, error) {
+	var out []Order
+	for _, o := range b.open {
+		out = append(out, o)
+	}
+	return out, nil
+}
+
+func (b *fakeBroker) Order(_ context.Context, _, id string) (OrderAck, error) {
+	ack, ok := b.acks[id]
+	if !ok {
+		return OrderAck{}, fmt.Errorf("order %s unknown", id)
+	}
+	return ack, nil
+}
+
+func (b *fakeBroker) Balances(context.Context) ([]Balance, error)   { return nil, nil }
+func (b *fakeBroker) Positions(context.Context) ([]Position, error) { return nil, nil }
+
+func TestLiveRestingOrderFillsReachStrategy(t *testing.T) {
+	br := newFakeBroker()
+	s := &scripted{sigs: map[int]Signal{0: {Orders: []Order{{Side: Buy, Type: Limit, Price: 95, Qty: 2, Tag: "dip"}}}}}
+	e, rec := newTestEngine(t, EngineOpts{Mode: "live", Broker: br}, s)
+	feed(t, e, bar(0, 100, 100, 100, 100))
+	if n := len(e.Export().Orders); n != 1 {
+		t.Fatalf("book holds %d orders, want the placed one", n)
+	}
+
+	br.acks["1"] = OrderAck{ID: "1", Status: StatusNew, ExecutedQty: 0.5, AvgPrice: 95}
+	feed(t, e, bar(1, 100, 100, 94, 96))
+	br.acks["1"] = OrderAck{ID: "1", Status: StatusFilled, ExecutedQty: 2, AvgPrice: 94.625}
+	feed(t, e, bar(2, 96, 97, 93, 95))
+
+	if len(s.fills) != 2 {
+		t.Fatalf("strategy saw %d fills, want 2: %+v", len(s.fills), s.fills)
+	}
+	if f := s.fills[0]; f.Event != "OPEN" || !near(f.Qty, 0.5) || !near(f.Price, 95) || f.OrderTag != "dip" {
+		t.Errorf("first fill = %+v, want OPEN 0.5 @ 95 tagged dip", f)
+	}
+	if f := s.fills[1]; f.Event != "ADD" || !near(f.Qty, 1.5) || !near(f.Price, 94.5) {
+		t.Errorf("second fill = %+v, want ADD 1.5 @ 94.5", f)
+	}
+	if p := e.Snapshot().PositionOf("X"); !near(p.Qty, 2) || !near(p.Entry, 94.625) {
+		t.Errorf("position = %+v, want 2 @ 94.625", p)
+	}
+	if n := len(e.Export().Orders); n != 0 {
+		t.Errorf("book holds %d orders after the fill, want 0", n)
+	}
+	if n := len(rec.orderEvents(EvOrderPartial)); n != 1 {
+		t.Errorf("%d partial fill events, want 1", n)
+	}
+	if rec.count("OrderFilled") != 1 {
+		t.Errorf("OrderFilled published %d times, want 1", rec.count("OrderFilled"))
+	}
+}
+
+func TestLiveCancelBooksFillBeforeCancel(t *testing.T) {
+	br := newFakeBroker()
+	s := &scripted{sigs: map[int]Signal{0: {Orders: []Order{{Side: Buy, Type: Limit, Price: 95, Qty: 2}}}}}
+	e, _ := newTestEngine(t, EngineOpts{Mode: "live", Broker: br}, s)
+	feed(t, e, bar(0, 100, 100, 100, 100))
+	br.acks["1"] = OrderAck{ID: "1", Status: StatusNew, ExecutedQty: 1, AvgPrice: 95}
+	if err := e.CancelOrder("1"); err != nil {
+		t.Fatal(err)
+	}
+	if p := e.Snapshot().PositionOf("X"); !near(p.Qty, 1) {
+		t.Errorf("position = %+v, want the part filled before the cancel", p)
+	}
+	if n := len(e.Export().Orders); n != 0 {
+		t.Errorf("book holds %d orders after the cancel, want 0", n)
+	}
+}
@@ -0,0 +1,182 @@
+package core
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Backpressure decides what Publish does when a subscriber's buffer is full.
+type Backpressure int
+
+const (
+	Block      Backpressure = iota // wait for buffer space (loses events only to unsubscribe)
+	DropNewest                     // drop the event being published
+	DropOldest                     // evict the oldest queued event
+	Sync                           // no buffer: deliver inline on the publisher goroutine
+)
+
+type SubOpts struct {
+	Buffer int // queue length for async policies (default 256)
+	Policy Backpressure
+}
+
+// Bus fans engine events out to independent subscribers. Each async
+// subscriber has its own queue and goroutine, so a slow consumer (Telegram,
+// SSE) never sees events out of order and only affects itself.
+//
+// Handlers may subscribe and unsubscribe, their own subscription included:
+// an unsubscribe releases a publisher blocked on that queue. A Block handler
+// must not Publish to the same bus, though: with its own queue full it would
+// wait on itself forever.
+type Bus struct {
+	mu   sync.RWMutex
+	subs []*subscriber
+	wg   sync.WaitGroup
+}
+
+type subscriber struct {
+	name    string
+	policy  Backpressure
+	fn      func(Event)
+	ch      chan Event
+	done    chan struct{} // closed on unsubscribe; releases blocked senders
+	sendMu  sync.Mutex
+	closed  bool
+	sending sync.WaitGroup // Block sends in flight; ch closes after them
+	dropped atomic.Uint64
+}
+
+func NewBus() *Bus { return &Bus{} }
+
+// Subscribe registers fn and returns a function that unsubscribes it. Async
+// subscribers drain their queue before the goroutine exits.
+func (b *Bus) Subscribe(name string, opts SubOpts, fn func(Event)) (unsubscribe func()) {
+	s := &subscriber{name: name, policy: opts.Policy, fn: fn}
+	if s.policy != Sync {
+		if opts.Buffer <= 0 {
+			opts.Buffer = 256
+		}
+		s.ch = make(chan Event, opts.Buffer)
+		s.done = make(chan struct{})
+		b.wg.Add(1)
+		go func() {
+			defer b.wg.Done()
+			for ev := range s.ch {
+				s.fn(ev)
+			}
+		}()
+	}
+	b.mu.Lock()
+	b.subs = append(b.subs, s)
+	b.mu.Unlock()
+	return func() {
+		b.mu.Lock()
+		// copy on write: Publish ranges over the old slice outside the lock
+		subs := make([]*subscriber, 0, len(b.subs))
+		for _, x := range b.subs {
+			if x != s {
+				subs = append(subs, x)
+			}
+		}
+		b.subs = subs
+		b.mu.Unlock()
+		s.close()
+	}
+}
+
+// Publish delivers ev to every subscriber according to its policy.
+func (b *Bus) Publish(ev Event) {
+	if b == nil {
+		return
+	}
+	b.mu.RLock()
+	subs := b.subs
+	b.mu.RUnlock()
+	for _, s := range subs {
+		s.deliver(ev)
+	}
+}
+
+// Close unsubscribes everyone and waits for async queues to drain.
+func (b *Bus) Close() {
+	b.mu.Lock()
+	subs := b.subs
+	b.subs = nil
+	b.mu.Unlock()
+	for _, s := range subs {
+		s.close()
+	}
+	b.wg.Wait()
+}
+
+// Dropped returns the number of events each subscriber lost to back-pressure.
+func (b *Bus) Dropped() map[string]uint64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	out := make(map[string]uint64, len(b.subs))
+	for _, s := range b.subs {
+		out[s.name] += s.dropped.Load()
+	}
+	return out
+}
+
+func (s *subscriber) deliver(ev Event) {
+	if s.policy == Sync {
+		s.fn(ev)
+		return
+	}
+	s.sendMu.Lock()
+	if s.closed {
+		s.sendMu.Unlock()
+		return
+	}
+	if s.policy == Block {
+		// wait outside sendMu, so the handler may unsubscribe meanwhile
+		s.sending.Add(1)
+		s.sendMu.Unlock()
+		defer s.sending.Done()
+		select {
+		case s.ch <- ev:
+		case <-s.done:
+		}
+		return
+	}
+	defer s.sendMu.Unlock()
+	switch s.policy {
+	case DropNewest:
+		select {
+		case s.ch <- ev:
+		default:
+			s.dropped.Add(1)
+		}
+	case DropOldest:
+		for {
+			select {
+			case s.ch <- ev:
+				return
+			default:
+			}
+			select {
+			case <-s.ch:
+				s.dropped.Add(1)
+			default:
+			}
+		}
+	}
+}
+
+func (s *subscriber) close() {
+	if s.ch == nil {
+		return
+	}
+	s.sendMu.Lock()
+	if s.closed {
+		s.sendMu.Unlock()
+		return
+	}
+	s.closed = true
+	close(s.done)
+	s.sendMu.Unlock()
+	s.sending.Wait()
+	close(s.ch)
+}
@@ -0,0 +1,54 @@
+package core
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBusUnsubscribeDuringPublish(t *testing.T) {
+	b := NewBus()
+	defer b.Close()
+	var unsub func()
+	var first, second, third atomic.Int32
+	unsub = b.Subscribe("first", SubOpts{Policy: Sync}, func(Event) {
+		first.Add(1)
+		unsub()
+	})
+	b.Subscribe("second", SubOpts{Policy: Sync}, func(Event) { second.Add(1) })
+	b.Subscribe("third", SubOpts{Policy: Sync}, func(Event) { third.Add(1) })
+
+	b.Publish(Notice{Text: "a"})
+	b.Publish(Notice{Text: "b"})
+	if first.Load() != 1 || second.Load() != 2 || third.Load() != 2 {
+		t.Fatalf("deliveries = %d/%d/%d, want 1/2/2", first.Load(), second.Load(), third.Load())
+	}
+}
+
+func TestBusBlockHandlerUnsubscribesWhilePublisherWaits(t *testing.T) {
+	b := NewBus()
+	defer b.Close()
+	release := make(chan struct{})
+	var unsub func()
+	unsub = b.Subscribe("slow", SubOpts{Policy: Block, Buffer: 1}, func(Event) {
+		<-release
+		unsub()
+	})
+
+	published := make(chan struct{})
+	go func() {
+		// the first is taken by the handler, the second fills the queue and
+		// the third blocks until the handler unsubscribes
+		for i := 0; i < 3; i++ {
+			b.Publish(Notice{Text: "x"})
+		}
+		close(published)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	select {
+	case <-published:
+	case <-time.After(2 * time.Second):
+		t.Fatal("publisher still blocked after the handler unsubscribed")
+	}
+}
@@ -0,0 +1,47 @@
+package core
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock stamps engine events and drives time-based logic.
+type Clock interface {
+	Now() time.Time
+}
+
+// WallClock is the live clock: UTC system time.
+type WallClock struct{}
+
+func (WallClock) Now() time.Time { return time.Now().UTC() }
+
+// CandleClock reports the close time of the latest candle fed to the engine,
+// so backtests and replays are stamped with bar time and are reproducible:
+// everything a bar causes, its fills included, happens when it closes, as it
+// would live. The engine advances it on every OnCandle; it never moves
+// backwards.
+type CandleClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func NewCandleClock() *CandleClock { return &CandleClock{} }
+
+func (c *CandleClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *CandleClock) Advance(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if t.After(c.t) {
+		c.t = t.UTC()
+	}
+}
+
+// candleDriven is implemented by clocks that follow market data.
+type candleDriven interface {
+	Advance(t time.Time)
+}
@@ -0,0 +1,82 @@
+package core
+
+import (
+	"fmt"
+	"time"
+)
+
+// Deal is one position cycle, from opening to flat, with all its adds,
+// partial exits and funding: a DCA cycle or a pyramided trade is one deal.
+// A position inherited from state or reconciliation starts its deal at the
+// first event seen.
+type Deal struct {
+	ID       string    `json:"id"`
+	Symbol   string    `json:"symbol"`
+	TF       string    `json:"tf,omitempty"`
+	Leg      Leg       `json:"leg,omitempty"`
+	Side     Action    `json:"side"`
+	Opened   time.Time `json:"opened"`
+	Closed   time.Time `json:"closed"`
+	Entries  int       `json:"entries"` // OPEN and ADD fills
+	Exits    int       `json:"exits"`   // REDUCE, CLOSE, SL, TP and liquidation fills
+	EntryQty float64   `json:"entryQty"`
+	AvgEntry float64   `json:"avgEntry"`
+	ExitQty  float64   `json:"exitQty"`
+	AvgExit  float64   `json:"avgExit"`
+	MaxQty   float64   `json:"maxQty"` // largest position held
+	PnL      float64   `json:"pnl"`    // realized, funding included, fees excluded
+	Funding  float64   `json:"funding"`
+	Fees     float64   `json:"fees"`
+	Comment  string    `json:"comment,omitempty"` // of the first fill
+}
+
+// String is the deal as one trade-log line.
+func (d Deal) String() string {
+	return fmt.Sprintf("%s entries=%d avg=%.4f exits=%d avgExit=%.4f maxQty=%.6f funding=%.4f fees=%.4f %s",
+		d.ID, d.Entries, d.AvgEntry, d.Exits, d.AvgExit, d.MaxQty, d.Funding, d.Fees, d.Comment)
+}
+
+// EvDeal is the trade log row of a completed deal.
+const EvDeal = "DEAL"
+
+type dealBook struct {
+	seq  int
+	open map[posKey]*Deal
+}
+
+// trackDeal books ev into the deal of its position, stamps ev.DealID, and
+// returns the deal if ev left the position flat.
+func (e *Engine) trackDeal(k posKey, ev *TradeEvent) *Deal {
+	if e.deals.open == nil {
+		e.deals.open = map[posKey]*Deal{}
+	}
+	d := e.deals.open[k]
+	if d == nil {
+		e.deals.seq++
+		d = &Deal{ID: fmt.Sprintf("d%d", e.deals.seq), Symbol: k.sym, TF: ev.TF, Leg: k.leg, Side: ev.Side, Opened: ev.TS, Comment: ev.Comment}
+		e.deals.open[k] = d
+	}
+	ev.DealID = d.ID
+	d.Fees += ev.Fee
+	switch {
+	case ev.Event == EvFunding:
+		d.Funding += ev.PnL
+		d.PnL += ev.PnL
+	case IsExitEvent(ev.Event):
+		d.AvgExit = (d.AvgExit*d.ExitQty + ev.Price*ev.Qty) / (d.ExitQty + ev.Qty)
+		d.ExitQty += ev.Qty
+		d.Exits++
+		d.PnL += ev.PnL
+	default: // OPEN, ADD
+		d.AvgEntry = (d.AvgEntry*d.EntryQty + ev.Price*ev.Qty) / (d.EntryQty + ev.Qty)
+		d.EntryQty += ev.Qty
+		d.Entries++
+	}
+	if p := e.book.get(k); p != nil {
+		d.MaxQty = maxf(d.MaxQty, p.qty)
+		return nil
+	}
+	d.Closed = ev.TS
+	delete(e.deals.open, k)
+	return d
+}
@@ -0,0 +1,50 @@
+package core
+
+import "testing"
+
+func TestDealSpansAddsUntilFlat(t *testing.T) {
+	s := &scripted{sigs: map[int]Signal{
+		0: {Action: Buy, SizePct: 0.1},
+		1: {Action: Buy, SizePct: 0.1},
+		2: {Action: Close},
+	}}
+	e, rec := newTestEngine(t, EngineOpts{Fees: FeeConfig{Taker: 0.001}}, s)
+	var deals []Deal
+	e.Bus().Subscribe("deals", SubOpts{Policy: Sync}, func(ev Event) {
+		if dc, ok := ev.(DealClosed); ok {
+			deals = append(deals, dc.Deal)
+		}
+	})
+	feed(t, e, bar(0, 100, 100, 100, 100), bar(1, 80, 80, 80, 80), bar(2, 90, 90, 90, 90))
+
+	trades := rec.trades()
+	if len(trades) != 3 || trades[0].Event != "OPEN" || trades[1].Event != "ADD" || !IsExitEvent(trades[2].Event) {
+		t.Fatalf("trades = %+v, want OPEN, ADD and an exit", trades)
+	}
+	if len(deals) != 1 {
+		t.Fatalf("deals = %+v, want one", deals)
+	}
+	d := deals[0]
+	open, add, exit := trades[0], trades[1], trades[2]
+	for _, tr := range trades {
+		if tr.DealID != d.ID {
+			t.Errorf("%s row in deal %q, want %q", tr.Event, tr.DealID, d.ID)
+		}
+	}
+	if d.Entries != 2 || d.Exits != 1 || d.Side != Buy {
+		t.Errorf("entries %d exits %d side %v, want 2, 1, buy", d.Entries, d.Exits, d.Side)
+	}
+	qty := open.Qty + add.Qty
+	if !near(d.EntryQty, qty) || !near(d.ExitQty, qty) || !near(d.MaxQty, qty) {
+		t.Errorf("entry %v exit %v max %v, want all %v", d.EntryQty, d.ExitQty, d.MaxQty, qty)
+	}
+	if avg := (open.Qty*open.Price + add.Qty*add.Price) / qty; !near(d.AvgEntry, avg) {
+		t.Errorf("avg entry = %v, want %v", d.AvgEntry, avg)
+	}
+	if !near(d.AvgExit, 90) || !near(d.PnL, exit.PnL) {
+		t.Errorf("avg exit %v pnl %v, want 90 and %v", d.AvgExit, d.PnL, exit.PnL)
+	}
+	if fees := open.Fee + add.Fee + exit.Fee; fees <= 0 || !near(d.Fees, fees) {
+		t.Errorf("fees = %v, want %v", d.Fees, fees)
+	}
+}
@@ -3,40 +3,98 @@ package core
 import (
 	"errors"
 	"fmt"
+	"sync"
 	"time"
 )
 
+// Engine is safe for concurrent use. Every exported method runs under one
+// mutex, so candles, order commands, snapshots and strategy swaps are applied
+// one at a time and a swap never lands in the middle of a candle. Events are
+// published while the lock is held: Sync bus subscribers and Block
+// subscribers whose queue is full run on the caller's goroutine and must not
+// call back into the Engine. Bus and Clock are immutable and need no lock.
 type Engine struct {
-	mode       string
-	eqUSD      float64
-	risk       RiskModel
-	strat      Strategy
-	notifyFunc func(string)
-	lastPx     float64
-	trades     TradeLogger
-	tradeHook  func(TradeEvent)
+	mu           sync.Mutex
+	mode         string
+	eqUSD        float64
+	risk         RiskModel
+	strat        Strategy
+	book         *positionBook
+	lastPx       map[string]float64
+	lastSym      string
+	orders       *orderBook
+	acctMode     AccountMode
+	intrabar     IntrabarPolicy
+	opposite     OppositePolicy
+	margin       MarginConfig
+	fees         FeeConfig
+	funding      FundingSource
+	fundingEvery time.Duration
+	lastFunding  map[string]time.Time
+	bus          *Bus
+	clock        Clock
+	live         Broker           // nil = paper execution
+	recon        *ReconcileReport // last startup reconciliation
+	trading      TradingState
+	traded       map[string]cover // per symbol: price action already traded through
+	deals        dealBook         // position cycles in progress, see Deal
+	relayDue     map[string]bool  // symbols filled since the last Relay
+	ownCancel    bool             // the strategy is canceling, see OrderObserver
+	started      bool             // strategy got OnStart
+	nextTimer    time.Time
+	history      HistorySource // warmup source, see SetWarmup
+	warmStream   Stream
+	needWarm     bool
+	warmGen      int // bumped by SetWarmup and AttachStrategy, see warmup
 }
 
 type TradeEvent struct {
-	TS      time.Time
-	Symbol  string
-	TF      string
-	Event   string
-	Side    Action
-	Qty     float64
-	Price   float64
-	PnL     float64
-	Fee     float64
-	Comment string
+	TS       time.Time
+	Symbol   string
+	TF       string
+	Event    string
+	Side     Action
+	Qty      float64
+	Price    float64
+	PnL      float64
+	Fee      float64 // charged for this fill, see FeeConfig
+	Comment  string
+	Leg      Leg    // LegNet in one-way mode
+	OrderID  string // resting order that filled, if any
+	OrderTag string // its Order.Tag
+	DealID   string // position cycle the row belongs to, see Deal
+	Maker    bool   // filled passively by a resting limit order
 }
 
 type EngineOpts struct {
-	Mode       string
-	EqUSD      float64
-	Risk       RiskModel
-	NotifyFunc func(string)
-	Trades     TradeLogger
-	TradeHook  func(TradeEvent)
+	Mode  string
+	EqUSD float64
+	Risk  RiskModel
+	// Bus receives every engine event; nil creates a private bus (see Engine.Bus).
+	Bus *Bus
+	// AccountMode selects one-way netting or hedge legs (default OneWay).
+	AccountMode AccountMode
+	// Intrabar resolves candles that touch both SL and TP (default StopFirst).
+	Intrabar IntrabarPolicy
+	// Opposite handles signals against the open position unless the strategy
+	// implements OppositePolicyProvider (default Reverse).
+	Opposite OppositePolicy
+	// Margin enables futures margin accounting and liquidation.
+	Margin MarginConfig
+	// Fees are charged to the account on every fill (default none).
+	Fees FeeConfig
+	// Funding enables perpetual funding payments every FundingInterval
+	// (default DefaultFundingInterval).
+	Funding         FundingSource
+	FundingInterval time.Duration
+	// Clock stamps events (default WallClock). A CandleClock makes the
+	// engine follow candle time, as backtests and replays need.
+	Clock Clock
+	// Broker routes executions to a live exchange account; nil keeps the
+	// built-in paper execution. Broker calls run under the engine lock.
+	Broker Broker
+	// Trading is the initial trading state, e.g. restored from state.
+	Trading TradingState
 }
 
 type RiskModel interface {
@@ -44,107 +102,289 @@ type RiskModel interface {
 }
 
 func NewEngine(opts EngineOpts) *Engine {
-	if opts.NotifyFunc == nil {
-		opts.NotifyFunc = func(string) {}
+	if opts.Bus == nil {
+		opts.Bus = NewBus()
 	}
-	return &Engine{mode: opts.Mode, eqUSD: opts.EqUSD, risk: opts.Risk, notifyFunc: opts.NotifyFunc, trades: opts.Trades, tradeHook: opts.TradeHook}
+	if opts.Clock == nil {
+		opts.Clock = WallClock{}
+	}
+	if opts.FundingInterval <= 0 {
+		opts.FundingInterval = DefaultFundingInterval
+	}
+	return &Engine{
+		mode:         opts.Mode,
+		eqUSD:        opts.EqUSD,
+		risk:         opts.Risk,
+		book:         newPositionBook(),
+		lastPx:       map[string]float64{},
+		orders:       &orderBook{},
+		acctMode:     opts.AccountMode,
+		intrabar:     opts.Intrabar,
+		opposite:     opts.Opposite,
+		margin:       opts.Margin,
+		fees:         opts.Fees,
+		funding:      opts.Funding,
+		fundingEvery: opts.FundingInterval,
+		lastFunding:  map[string]time.Time{},
+		bus:          opts.Bus,
+		clock:        opts.Clock,
+		live:         opts.Broker,
+		trading:      opts.Trading,
+		traded:       map[string]cover{},
+		relayDue:     map[string]bool{},
+	}
+}
+
+func (e *Engine) Mode() string { return e.mode }
+func (e *Engine) Bus() *Bus    { return e.bus }
+func (e *Engine) Clock() Clock { return e.clock }
+
+// AttachStrategy swaps the strategy between candles. A running strategy is
+// stopped first; the new one starts on the next candle (Tick only runs timers).
+func (e *Engine) AttachStrategy(s Strategy) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.stop()
+	e.strat = s
+	e.needWarm = e.history != nil
+	e.warmGen++
 }
 
-func (e *Engine) AttachStrategy(s Strategy) { e.strat = s }
-func (e *Engine) Strategy() Strategy        { return e.strat }
-func (e *Engine) EquityUSD() float64        { return e.eqUSD }
-func (e *Engine) Snapshot() AccountState    { return e.snapshot(e.lastPx) }
+func (e *Engine) Strategy() Strategy {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.strat
+}
+
+func (e *Engine) EquityUSD() float64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.eqUSD
+}
+
+// Snapshot returns the account with Position set to the most recently traded symbol.
+func (e *Engine) Snapshot() AccountState {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.snapshot(e.lastSym)
+}
 
 func (e *Engine) OnCandle(sym, tf string, kl Kline) error {
+	w := e.lockWarm(sym, tf, kl)
+	defer e.mu.Unlock()
 	if e.strat == nil {
 		return errors.New("strategy is nil")
 	}
-	e.lastPx = kl.Close
-	acct := e.snapshot(kl.Close)
+	if c, ok := e.clock.(candleDriven); ok {
+		c.Advance(CloseTime(kl))
+	}
+	if e.fresh(sym, tf, kl) {
+		e.lastPx[sym] = kl.Close
+		e.lastSym = sym
+		e.applyFunding(sym, tf, kl)
+		e.checkExits(sym, tf, kl)
+		e.relay(tf)
+		e.matchOrders(sym, tf, kl)
+		e.relay(tf)
+	}
+	defer func() { e.bus.Publish(EquityUpdated{TS: e.clock.Now(), Account: e.snapshot(sym)}) }()
+	if w != nil {
+		e.warmUp(kl, w)
+	}
+	if err := e.start(sym, tf); err != nil {
+		return err
+	}
+	acct := e.snapshot(sym)
 	sig, err := e.strat.OnCandle(sym, tf, kl, acct)
+	ts := e.clock.Now()
 	if err != nil {
+		e.bus.Publish(StrategyError{TS: ts, Symbol: sym, TF: tf, Strategy: e.strat.Name(), Err: err})
+		return err
+	}
+	if err := e.act(ts, sym, tf, kl.Close, sig, acct); err != nil {
 		return err
 	}
+	defer e.relay(tf)
+	return e.runTimers()
+}
+
+// act carries out a strategy signal for sym at reference price px.
+func (e *Engine) act(ts time.Time, sym, tf string, px float64, sig Signal, acct AccountState) error {
+	if sig.Cancel {
+		e.cancelOwn(ts, sym, tf)
+	}
+	e.placeSignalOrders(ts, sym, tf, sig.Orders)
 	if sig.Action == None {
 		return nil
 	}
+	e.bus.Publish(SignalGenerated{TS: ts, Symbol: sym, TF: tf, Signal: sig})
+	d := RiskDecision{TS: ts, Symbol: sym, TF: tf, Signal: sig}
+	if e.reconHold() {
+		e.reject(d, errReconHold.Error())
+		return nil
+	}
+	if reason := e.tradingGate(sym, sig); reason != "" {
+		e.reject(d, reason)
+		return nil
+	}
 
 	// Risk
-	sig, err = e.risk.Validate(sig, acct, kl.Close)
+	checked, err := e.risk.Validate(sig, acct, px)
 	if err != nil {
+		e.reject(d, err.Error())
 		return err
 	}
+	if signalChanged(sig, checked) {
+		d.Approved, d.Reason = checked, "adjusted by risk model"
+		e.observeRisk(d)
+	}
+	sig = checked
+
+	if sig.Type != Market && (sig.Action == Buy || sig.Action == Sell) {
+		if _, err := e.placeOrder(ts, tf, Order{
+			Symbol: sym, Side: sig.Action, Leg: sig.Leg, Type: sig.Type, SizePct: sig.SizePct,
+			Price: sig.Price, StopPrice: sig.StopPrice, TIF: sig.TIF, ExpireAt: sig.ExpireAt,
+			SL: sig.SL, TP: sig.TP, Comment: sig.Comment,
+		}); err != nil {
+			e.reject(d, err.Error())
+		}
+		return nil
+	}
 
-	// Execute (paper): naive fill at close
-	ts := time.Now().UTC()
+	// Execute: paper fills at close, a live broker at its market price
 	switch sig.Action {
-	case Buy:
-		if acct.Position.Side == Buy { // scale-in
-			qty := e.sizeUSD(sig.SizePct) / kl.Close
-			avg := (acct.Position.Entry*acct.Position.Qty + kl.Close*qty) / (acct.Position.Qty + qty)
-			e.notifyFunc(fmt.Sprintf("LONG add %.4f @ %.2f | TP:%v SL:%v %s", qty, kl.Close, ptrf(sig.TP), ptrf(sig.SL), sig.Comment))
-			posQty += qty
-			posEntry = avg
-			posSide = Buy
-			e.logTrade(ts, sym, tf, "ADD", Buy, qty, kl.Close, 0, sig.Comment)
-		} else { // open/reverse
-			qty := e.sizeUSD(sig.SizePct) / kl.Close
-			e.notifyFunc(fmt.Sprintf("LONG open %.4f @ %.2f | TP:%v SL:%v %s", qty, kl.Close, ptrf(sig.TP), ptrf(sig.SL), sig.Comment))
-			posSide = Buy
-			posQty = qty
-			posEntry = kl.Close
-			e.logTrade(ts, sym, tf, "OPEN", Buy, qty, kl.Close, 0, sig.Comment)
+	case Buy, Sell:
+		k := posKey{sym, e.legFor(sig.Leg, sig.Action)}
+		qty := e.sizeUSD(sig.SizePct) / px
+		if p := e.book.get(k); p != nil && p.side != sig.Action && k.leg == LegNet {
+			policy := e.oppositePolicy()
+			if e.trading == TradingCloseOnly {
+				policy = CloseOnly
+			}
+			switch policy {
+			case IgnoreOpposite:
+				e.reject(d, "opposite signal ignored")
+				return nil
+			case CloseOnly:
+				qty = p.qty
+			case Reverse:
+				qty += p.qty
+			}
+		}
+		qty, px, ok := e.execute(ts, k, tf, sig.Action, qty, px, false)
+		if !ok {
+			e.reject(d, "execution failed")
+			return nil
 		}
-	case Sell:
-		if acct.Position.Side == Sell {
-			qty := e.sizeUSD(sig.SizePct) / kl.Close
-			avg := (acct.Position.Entry*acct.Position.Qty + kl.Close*qty) / (acct.Position.Qty + qty)
-			e.notifyFunc(fmt.Sprintf("SHORT add %.4f @ %.2f | TP:%v SL:%v %s", qty, kl.Close, ptrf(sig.TP), ptrf(sig.SL), sig.Comment))
-			posQty += qty
-			posEntry = avg
-			posSide = Sell
-			e.logTrade(ts, sym, tf, "ADD", Sell, qty, kl.Close, 0, sig.Comment)
-		} else {
-			qty := e.sizeUSD(sig.SizePct) / kl.Close
-			e.notifyFunc(fmt.Sprintf("SHORT open %.4f @ %.2f | TP:%v SL:%v %s", qty, kl.Close, ptrf(sig.TP), ptrf(sig.SL), sig.Comment))
-			posSide = Sell
-			posQty = qty
-			posEntry = kl.Close
-			e.logTrade(ts, sym, tf, "OPEN", Sell, qty, kl.Close, 0, sig.Comment)
+		if !e.fill(ts, k, tf, sig.Action, qty, px, sig.SL, sig.TP, sig.Comment, nil) {
+			d.Rejected, d.Reason = true, reasonNotFilled
+			e.observeRisk(d)
 		}
 	case Close:
-		if acct.Position.Side != None {
-			pnl := e.realize(kl.Close)
-			e.notifyFunc(fmt.Sprintf("CLOSE @ %.2f | PnL: %.2f USD", kl.Close, pnl))
-			e.logTrade(ts, sym, tf, "CLOSE", acct.Position.Side, acct.Position.Qty, kl.Close, pnl, "close")
+		for _, k := range e.book.legs(sym) {
+			if sig.Leg != LegNet && k.leg != sig.Leg {
+				continue
+			}
+			p := *e.book.get(k)
+			qty, px, ok := e.execute(ts, k, tf, p.side.opposite(), p.qty, px, true)
+			if !ok {
+				continue
+			}
+			pnl := e.reduce(k, qty, px)
+			e.notify(ts, "CLOSE %s @ %.2f | PnL: %.2f USD", k.leg, px, pnl)
+			e.logTrade(TradeEvent{TS: ts, Symbol: sym, TF: tf, Event: "CLOSE", Side: p.side, Leg: k.leg, Qty: qty, Price: px, PnL: pnl, Comment: "close"})
 		}
 	}
 	return nil
 }
 
-func (e *Engine) logTrade(ts time.Time, sym, tf, etype string, side Action, qty, price, pnl float64, comment string) {
-	if e.trades == nil {
-		if e.tradeHook != nil {
-			e.tradeHook(TradeEvent{TS: ts, Symbol: sym, TF: tf, Event: etype, Side: side, Qty: qty, Price: price, PnL: pnl, Comment: comment})
+// fill applies an executed buy or sell of qty at px to the position at k.
+// A one-way position nets: an opposite fill first reduces or closes it
+// (booking realized PnL) and only the remainder opens the new side. A hedge
+// leg never flips; an opposite fill only reduces it. fill reports false when
+// nothing was applied. o is the resting order that filled, nil for market
+// executions.
+func (e *Engine) fill(ts time.Time, k posKey, tf string, side Action, qty, px float64, sl, tp *float64, comment string, o *Order) bool {
+	sym := k.sym
+	var maker bool
+	var oid, tag string
+	if o != nil {
+		maker, oid, tag = o.Type != Stop, o.ID, o.Tag
+	}
+	name := map[Action]string{Buy: "LONG", Sell: "SHORT"}[side]
+	p := e.book.get(k)
+	if k.leg != LegNet && side != k.leg.side() && p == nil {
+		return false
+	}
+	applied := false
+	if p != nil && p.side != side {
+		applied = true
+		cur := p.side
+		closeQty := minf(qty, p.qty)
+		pnl := e.reduce(k, closeQty, px)
+		event := "REDUCE"
+		if e.book.get(k) == nil {
+			event = "CLOSE"
 		}
-		return
+		e.notify(ts, "%s %s %.4f @ %.2f | PnL: %.2f USD %s", event, k.leg, closeQty, px, pnl, comment)
+		e.logTrade(TradeEvent{TS: ts, Symbol: sym, TF: tf, Event: event, Side: cur, Leg: k.leg, Qty: closeQty, Price: px, PnL: pnl, Comment: comment, OrderID: oid, OrderTag: tag, Maker: maker})
+		qty -= closeQty
+		if qty <= qtyEps || k.leg != LegNet {
+			return true
+		}
+		p = nil
+	}
+	im, err := e.postMargin(qty, px)
+	if err != nil {
+		e.notify(ts, "%s %.4f @ %.2f rejected: %v", name, qty, px, err)
+		e.bus.Publish(RiskRejected{TS: ts, Symbol: sym, TF: tf, Signal: Signal{Action: side, Leg: k.leg, SL: sl, TP: tp, Comment: comment}, Reason: err.Error()})
+		return applied // a reversal keeps the close of the old side
 	}
-	entry := TradeLogEntry{
-		TS:      ts,
-		Symbol:  sym,
-		TF:      tf,
-		Event:   etype,
-		Side:    map[Action]string{Buy: "LONG", Sell: "SHORT", None: "FLAT"}[side],
-		Qty:     qty,
-		Price:   price,
-		PnL:     pnl,
-		Fee:     0,
-		Comment: comment,
+	if p != nil { // scale-in
+		avg := (p.entry*p.qty + px*qty) / (p.qty + qty)
+		e.notify(ts, "%s add %.4f @ %.2f | TP:%v SL:%v %s", name, qty, px, ptrf(tp), ptrf(sl), comment)
+		e.book.set(k, side, p.qty+qty, avg).protect(sl, tp)
+		p.margin += im
+		e.logTrade(TradeEvent{TS: ts, Symbol: sym, TF: tf, Event: "ADD", Side: side, Leg: k.leg, Qty: qty, Price: px, Comment: comment, OrderID: oid, OrderTag: tag, Maker: maker})
+		return true
+	}
+	e.notify(ts, "%s open %.4f @ %.2f | TP:%v SL:%v %s", name, qty, px, ptrf(tp), ptrf(sl), comment)
+	np := e.book.set(k, side, qty, px)
+	np.protect(sl, tp)
+	np.margin = im
+	e.logTrade(TradeEvent{TS: ts, Symbol: sym, TF: tf, Event: "OPEN", Side: side, Leg: k.leg, Qty: qty, Price: px, Comment: comment, OrderID: oid, OrderTag: tag, Maker: maker})
+	return true
+}
+
+// logTrade charges the fee of a fill, then publishes the ledger row together
+// with the position it left behind, and the deal it completed.
+func (e *Engine) logTrade(ev TradeEvent) {
+	if ev.Event != EvFunding {
+		ev.Fee = e.feeOf(ev.Qty, ev.Price, ev.Maker)
+		e.eqUSD -= ev.Fee
+	}
+	k := posKey{ev.Symbol, ev.Leg}
+	pos := Position{Symbol: ev.Symbol, Leg: ev.Leg}
+	if p := e.book.get(k); p != nil {
+		pos = p.view(k, e.lastPx[ev.Symbol])
+	}
+	deal := e.trackDeal(k, &ev)
+	e.bus.Publish(PositionChanged{TradeEvent: ev, Position: pos})
+	if deal != nil {
+		e.bus.Publish(DealClosed{TS: ev.TS, Deal: *deal})
+		e.cancelAddOnly(ev.TS, k, ev.TF)
+	}
+	if ev.Event == EvFunding {
+		return
 	}
-	if e.tradeHook != nil {
-		e.tradeHook(TradeEvent{TS: ts, Symbol: sym, TF: tf, Event: etype, Side: side, Qty: qty, Price: price, PnL: pnl, Comment: comment})
+	e.relayDue[ev.Symbol] = true
+	if f, ok := e.strat.(FillObserver); ok {
+		f.OnFill(ev, e.snapshot(ev.Symbol))
 	}
-	_ = e.trades.Append(entry)
+}
+
+func (e *Engine) notify(ts time.Time, format string, args ...any) {
+	e.bus.Publish(Notice{TS: ts, Text: fmt.Sprintf(format, args...)})
 }
 
 func ptrf(p *float64) string {
@@ -154,41 +394,56 @@ func ptrf(p *float64) string {
 	return fmt.Sprintf("%.2f", *p)
 }
 
-func (e *Engine) snapshot(px float64) AccountState {
-	return AccountState{EquityUSD: e.eqUSD, Position: e.pos(px)}
+func (e *Engine) snapshot(sym string) AccountState {
+	acct := AccountState{EquityUSD: e.eqUSD, Position: Position{Symbol: sym}}
+	for _, k := range e.book.keys() {
+		bp := e.book.get(k)
+		px := e.lastPx[k.sym]
+		p := bp.view(k, px)
+		if e.margin.Mode != NoMargin {
+			p.Margin = bp.margin
+			p.MaintMargin = e.maintMargin(bp, px)
+			p.LiqPrice = e.liqPrice(k)
+			acct.MarginUsed += p.Margin
+			acct.MaintMargin += p.MaintMargin
+		}
+		acct.Unrealized += p.Unreal
+		acct.Positions = append(acct.Positions, p)
+		if k.sym == sym && acct.Position.Side == None {
+			acct.Position = p
+		}
+	}
+	acct.NetEquity = acct.EquityUSD + acct.Unrealized
+	if acct.NetEquity > 0 {
+		acct.MarginRatio = acct.MaintMargin / acct.NetEquity
+	}
+	return acct
 }
 
-// Position state in paper mode (package-level simple demo)
-var (
-	posSide  Action
-	posQty   float64
-	posEntry float64
-)
-
-func (e *Engine) pos(px float64) Position {
-	if posSide == None {
-		return Position{}
-	}
-	unrl := 0.0
-	if posSide == Buy {
-		unrl = (px - posEntry) * posQty
+// reduce closes qty of the position at k at px and returns the realized PnL.
+func (e *Engine) reduce(k posKey, qty, px float64) float64 {
+	p := e.book.get(k)
+	if p == nil {
+		return 0
 	}
-	if posSide == Sell {
-		unrl = (posEntry - px) * posQty
+	if qty >= p.qty-qtyEps {
+		return e.realize(k, px)
 	}
-	return Position{Side: posSide, Qty: posQty, Entry: posEntry, Unreal: unrl}
+	pnl := p.view(k, px).Unreal * qty / p.qty
+	e.eqUSD += pnl
+	p.margin -= p.margin * qty / p.qty
+	p.qty -= qty
+	return pnl
 }
 
-func (e *Engine) realize(px float64) float64 {
-	p := e.pos(px)
-	if p.Side == None {
+func (e *Engine) realize(k posKey, px float64) float64 {
+	p := e.book.get(k)
+	if p == nil {
 		return 0
 	}
-	pnl := p.Unreal
+	pnl := p.view(k, px).Unreal
 	e.eqUSD += pnl
-	posSide = None
-	posQty = 0
-	posEntry = 0
+	e.book.remove(k)
 	return pnl
 }
 
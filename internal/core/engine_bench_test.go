@@ -0,0 +1,80 @@
+package core
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// walk is n one-minute candles of X, a seeded random walk.
+func walk(n int) []Kline {
+	rng := rand.New(rand.NewSource(1))
+	out := make([]Kline, n)
+	px := 100.0
+	for i := range out {
+		o := px
+		px *= 1 + rng.NormFloat64()*0.002
+		hi, lo := max(o, px)*(1+rng.Float64()*0.001), min(o, px)*(1-rng.Float64()*0.001)
+		out[i] = Kline{Symbol: "X", TF: "1m", Ts: t0.Add(time.Duration(i) * time.Minute), Open: o, High: hi, Low: lo, Close: px, Vol: 1}
+	}
+	return out
+}
+
+// flipper alternates long and short every 50 bars with a protective stop and
+// a resting take-profit, so the engine exercises exits and order matching.
+type flipper struct{ n int }
+
+func (s *flipper) Warmup() int  { return 0 }
+func (s *flipper) Name() string { return "FLIPPER" }
+func (s *flipper) OnCandle(sym, tf string, kl Kline, acct AccountState) (Signal, error) {
+	s.n++
+	if s.n%50 != 0 {
+		return Signal{}, nil
+	}
+	side, sl, tp := Buy, kl.Close*0.98, kl.Close*1.01
+	if s.n%100 == 0 {
+		side, sl, tp = Sell, kl.Close*1.02, kl.Close*0.99
+	}
+	return Signal{Action: side, SizePct: 0.1, SL: &sl, Cancel: true, Orders: []Order{{Side: side.opposite(), Type: Limit, Price: tp, Qty: acct.PositionOf(sym).Qty + 1}}}, nil
+}
+
+const benchBars = 1_000_000
+
+// benchEngine is a paper engine with no subscribers on its bus.
+func benchEngine(b *testing.B) *Engine {
+	bus := NewBus()
+	b.Cleanup(bus.Close)
+	e := NewEngine(EngineOpts{Mode: "paper", EqUSD: 10000, Risk: passRisk{}, Bus: bus, Clock: NewCandleClock(), Fees: FeeConfig{Maker: 0.0002, Taker: 0.0005}})
+	e.AttachStrategy(&flipper{})
+	return e
+}
+
+// BenchmarkEngineOnCandle times one engine step per bar of a 1M-bar walk.
+func BenchmarkEngineOnCandle(b *testing.B) {
+	bars := walk(benchBars)
+	e := benchEngine(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		kl := bars[i%benchBars]
+		kl.Ts = t0.Add(time.Duration(i) * time.Minute)
+		if err := e.OnCandle(kl.Symbol, kl.TF, kl); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkEngine1MBars replays the whole 1M-bar walk per iteration.
+func BenchmarkEngine1MBars(b *testing.B) {
+	bars := walk(benchBars)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		e := benchEngine(b)
+		for _, kl := range bars {
+			if err := e.OnCandle(kl.Symbol, kl.TF, kl); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package core
+
+import (
+	"sync"
+	"time"
+)
+
+// Event is anything the engine publishes on its Bus.
+type Event interface {
+	Kind() string
+}
+
+// SignalGenerated: the strategy returned an actionable signal (before risk).
+type SignalGenerated struct {
+	TS     time.Time
+	Symbol string
+	TF     string
+	Signal Signal
+}
+
+// RiskRejected: the risk model or the margin check refused a signal or fill.
+type RiskRejected struct {
+	TS     time.Time
+	Symbol string
+	TF     string
+	Signal Signal
+	Reason string
+}
+
+// OrderPlaced: a resting order entered the book.
+type OrderPlaced struct {
+	TS    time.Time
+	TF    string
+	Order Order
+}
+
+// OrderUpdated: a resting order was triggered, replaced, canceled, expired
+// or rejected (Event is one of the EvOrder* constants).
+type OrderUpdated struct {
+	TS     time.Time
+	TF     string
+	Event  string
+	Order  Order
+	Reason string // why a matched order was rejected
+}
+
+// OrderFilled: a resting order was matched at Price.
+type OrderFilled struct {
+	TS    time.Time
+	TF    string
+	Order Order
+	Price float64
+}
+
+// PositionChanged is one ledger row of a position: a fill (OPEN, ADD, REDUCE,
+// CLOSE), a protective exit (SL, TP, LIQUIDATION) or a FUNDING accrual.
+// Position is the state after the change.
+type PositionChanged struct {
+	TradeEvent
+	Position Position
+}
+
+// EquityUpdated is published after every candle with the marked account.
+type EquityUpdated struct {
+	TS      time.Time
+	Account AccountState
+}
+
+// StrategyError: the strategy returned an error from a callback.
+type StrategyError struct {
+	TS       time.Time
+	Symbol   string
+	TF       string
+	Strategy string
+	Err      error
+}
+
+// ExecutionFailed: the broker refused or did not fill a market order.
+type ExecutionFailed struct {
+	TS     time.Time
+	Symbol string
+	TF     string
+	Side   Action
+	Qty    float64
+	Err    error
+}
+
+// Reconciled carries the report of a startup reconciliation.
+type Reconciled struct {
+	TS     time.Time
+	Report ReconcileReport
+}
+
+// TradingStateChanged: the trading state machine moved From -> To.
+type TradingStateChanged struct {
+	TS       time.Time
+	From, To TradingState
+}
+
+// DealClosed: a position went flat, completing Deal.
+type DealClosed struct {
+	TS   time.Time
+	Deal Deal
+}
+
+// Notice is a human-readable line for logs and chats.
+type Notice struct {
+	TS   time.Time
+	Text string
+}
+
+func (SignalGenerated) Kind() string     { return "SignalGenerated" }
+func (RiskRejected) Kind() string        { return "RiskRejected" }
+func (OrderPlaced) Kind() string         { return "OrderPlaced" }
+func (OrderUpdated) Kind() string        { return "OrderUpdated" }
+func (OrderFilled) Kind() string         { return "OrderFilled" }
+func (PositionChanged) Kind() string     { return "PositionChanged" }
+func (EquityUpdated) Kind() string       { return "EquityUpdated" }
+func (StrategyError) Kind() string       { return "StrategyError" }
+func (ExecutionFailed) Kind() string     { return "ExecutionFailed" }
+func (Reconciled) Kind() string          { return "Reconciled" }
+func (TradingStateChanged) Kind() string { return "TradingStateChanged" }
+func (DealClosed) Kind() string          { return "DealClosed" }
+func (Notice) Kind() string              { return "Notice" }
+
+// EventCounter is a bus subscriber that counts events by kind.
+type EventCounter struct {
+	mu     sync.Mutex
+	counts map[string]uint64
+}
+
+func NewEventCounter() *EventCounter { return &EventCounter{counts: map[string]uint64{}} }
+
+func (c *EventCounter) Handle(ev Event) {
+	c.mu.Lock()
+	c.counts[ev.Kind()]++
+	c.mu.Unlock()
+}
+
+func (c *EventCounter) Snapshot() map[string]uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make(map[string]uint64, len(c.counts))
+	for k, v := range c.counts {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,124 @@
+package core
+
+import (
+	"math"
+	"strings"
+)
+
+// IntrabarPolicy decides which protective level fills first when a single
+// candle touches both the stop-loss and the take-profit of a position.
+type IntrabarPolicy int
+
+const (
+	StopFirst   IntrabarPolicy = iota // assume the worst case: the stop fills
+	TargetFirst                       // assume the target fills
+	NearestOpen                       // the level closer to the candle open fills
+)
+
+// ParseIntrabarPolicy maps "stop_first" | "target_first" | "nearest" to a
+// policy; anything else yields StopFirst.
+func ParseIntrabarPolicy(s string) IntrabarPolicy {
+	switch strings.ToLower(s) {
+	case "target_first", "tp_first":
+		return TargetFirst
+	case "nearest", "nearest_open":
+		return NearestOpen
+	}
+	return StopFirst
+}
+
+// checkExits fills the stop-loss or take-profit of each position in sym if kl
+// reaches it. A candle that opens beyond a level fills at the open.
+func (e *Engine) checkExits(sym, tf string, kl Kline) {
+	for _, k := range e.book.legs(sym) {
+		e.checkLegExits(k, tf, kl)
+	}
+}
+
+func (e *Engine) checkLegExits(k posKey, tf string, kl Kline) {
+	p := e.book.get(k)
+	liq := e.liqPrice(k)
+	if p.sl == 0 && p.tp == 0 && liq == 0 {
+		return
+	}
+	// the adverse level reached first: the stop or the liquidation price
+	stopEvent, stop := "SL", p.sl
+	if liq > 0 && (stop == 0 || p.side == Buy && liq > stop || p.side == Sell && liq < stop) {
+		stopEvent, stop = EvLiquidation, liq
+	}
+	slHit, slPx := adverseHit(p.side, stop, kl)
+	tpHit, tpPx := favorableHit(p.side, p.tp, kl)
+	switch {
+	case slHit && tpHit:
+		switch e.intrabar {
+		case TargetFirst:
+			slHit = false
+		case NearestOpen:
+			if math.Abs(tpPx-kl.Open) < math.Abs(kl.Open-slPx) {
+				slHit = false
+			} else {
+				tpHit = false
+			}
+		default:
+			tpHit = false
+		}
+	case !slHit && !tpHit:
+		return
+	}
+	event, px := stopEvent, slPx
+	if tpHit {
+		event, px = "TP", tpPx
+	}
+	side, posted := p.side, p.margin
+	ts := e.clock.Now()
+	qty, px, ok := e.execute(ts, k, tf, side.opposite(), p.qty, px, true)
+	if !ok {
+		return
+	}
+	pnl := e.reduce(k, qty, px)
+	if event == EvLiquidation && e.margin.Mode == Isolated {
+		// a gap through the liquidation price loses no more than the leg's
+		// margin, its fee included; the rest of the equity is untouched
+		if floor := e.feeOf(qty, px, false) - posted; pnl < floor {
+			e.eqUSD += floor - pnl
+			pnl = floor
+		}
+	}
+	e.notify(ts, "%s %s @ %.2f | PnL: %.2f USD", event, k.leg, px, pnl)
+	e.logTrade(TradeEvent{TS: ts, Symbol: k.sym, TF: tf, Event: event, Side: side, Leg: k.leg, Qty: qty, Price: px, PnL: pnl, Comment: event})
+}
+
+// adverseHit reports whether kl trades through a level below a long (above a
+// short) and the fill price.
+func adverseHit(side Action, level float64, kl Kline) (bool, float64) {
+	if level == 0 {
+		return false, 0
+	}
+	if side == Buy {
+		if kl.Open <= level {
+			return true, kl.Open
+		}
+		return kl.Low <= level, level
+	}
+	if kl.Open >= level {
+		return true, kl.Open
+	}
+	return kl.High >= level, level
+}
+
+// favorableHit is the mirror of adverseHit for profit targets.
+func favorableHit(side Action, level float64, kl Kline) (bool, float64) {
+	if level == 0 {
+		return false, 0
+	}
+	if side == Buy {
+		if kl.Open >= level {
+			return true, kl.Open
+		}
+		return kl.High >= level, level
+	}
+	if kl.Open <= level {
+		return true, kl.Open
+	}
+	return kl.Low <= level, level
+}
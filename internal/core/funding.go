@@ -0,0 +1,51 @@
+package core
+
+import (
+	"fmt"
+	"time"
+)
+
+// FundingSource provides the perpetual funding rate settled at a funding time.
+type FundingSource interface {
+	FundingRate(sym string, at time.Time) (float64, bool)
+}
+
+// EvFunding is the ledger event for a funding payment; PnL holds the amount
+// credited (negative when paid).
+const EvFunding = "FUNDING"
+
+// DefaultFundingInterval matches Binance perpetuals (00:00, 08:00, 16:00 UTC).
+const DefaultFundingInterval = 8 * time.Hour
+
+// applyFunding settles every funding time in (last candle, kl.Ts] against the
+// positions held in sym, priced at the candle open.
+func (e *Engine) applyFunding(sym, tf string, kl Kline) {
+	if e.funding == nil || e.live != nil { // live accounts are settled by the exchange
+		return
+	}
+	prev, seen := e.lastFunding[sym]
+	e.lastFunding[sym] = kl.Ts
+	if !seen || !kl.Ts.After(prev) {
+		return
+	}
+	for at := prev.Truncate(e.fundingEvery).Add(e.fundingEvery); !at.After(kl.Ts); at = at.Add(e.fundingEvery) {
+		legs := e.book.legs(sym)
+		if len(legs) == 0 {
+			continue
+		}
+		rate, ok := e.funding.FundingRate(sym, at)
+		if !ok {
+			continue
+		}
+		for _, k := range legs {
+			p := e.book.get(k)
+			pay := p.qty * kl.Open * rate // longs pay a positive rate
+			if p.side == Sell {
+				pay = -pay
+			}
+			e.eqUSD -= pay
+			e.notify(at, "FUNDING %s %s rate=%.6f | %.4f USD", sym, k.leg, rate, -pay)
+			e.logTrade(TradeEvent{TS: at, Symbol: sym, TF: tf, Event: EvFunding, Side: p.side, Leg: k.leg, Qty: p.qty, Price: kl.Open, PnL: -pay, Comment: fmt.Sprintf("rate=%.6f", rate)})
+		}
+	}
+}
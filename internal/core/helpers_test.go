@@ -0,0 +1,129 @@
+package core
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// passRisk approves every signal unchanged.
+type passRisk struct{}
+
+func (passRisk) Validate(sig Signal, _ AccountState, _ float64) (Signal, error) { return sig, nil }
+
+// scripted emits sigs[i] on its i-th candle and records its fills.
+type scripted struct {
+	sigs  map[int]Signal
+	n     int
+	fills []TradeEvent
+}
+
+func (s *scripted) OnCandle(sym, tf string, kl Kline, acct AccountState) (Signal, error) {
+	sig := s.sigs[s.n]
+	s.n++
+	return sig, nil
+}
+func (s *scripted) Warmup() int  { return 0 }
+func (s *scripted) Name() string { return "SCRIPTED" }
+func (s *scripted) OnFill(ev TradeEvent, acct AccountState) {
+	s.fills = append(s.fills, ev)
+}
+
+// recorder collects bus events synchronously.
+type recorder struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (r *recorder) add(ev Event) {
+	r.mu.Lock()
+	r.events = append(r.events, ev)
+	r.mu.Unlock()
+}
+
+func (r *recorder) trades() []TradeEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []TradeEvent
+	for _, ev := range r.events {
+		if pc, ok := ev.(PositionChanged); ok {
+			out = append(out, pc.TradeEvent)
+		}
+	}
+	return out
+}
+
+func (r *recorder) count(kind string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, ev := range r.events {
+		if ev.Kind() == kind {
+			n++
+		}
+	}
+	return n
+}
+
+func (r *recorder) orderEvents(event string) []OrderUpdated {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []OrderUpdated
+	for _, ev := range r.events {
+		if ou, ok := ev.(OrderUpdated); ok && ou.Event == event {
+			out = append(out, ou)
+		}
+	}
+	return out
+}
+
+// newTestEngine is a paper engine on candle time with equity 10000 and a
+// pass-through risk model unless opts say otherwise.
+func newTestEngine(t testing.TB, opts EngineOpts, s Strategy) (*Engine, *recorder) {
+	t.Helper()
+	rec := &recorder{}
+	if opts.Bus == nil {
+		opts.Bus = NewBus()
+	}
+	opts.Bus.Subscribe("test", SubOpts{Policy: Sync}, rec.add)
+	if opts.Risk == nil {
+		opts.Risk = passRisk{}
+	}
+	if opts.EqUSD == 0 {
+		opts.EqUSD = 10000
+	}
+	if opts.Clock == nil {
+		opts.Clock = NewCandleClock()
+	}
+	if opts.Mode == "" {
+		opts.Mode = "paper"
+	}
+	e := NewEngine(opts)
+	if s == nil {
+		s = &scripted{}
+	}
+	e.AttachStrategy(s)
+	t.Cleanup(opts.Bus.Close)
+	return e, rec
+}
+
+var t0 = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// bar is hourly candle i of X.
+func bar(i int, o, h, l, c float64) Kline {
+	return Kline{Symbol: "X", TF: "1h", Ts: t0.Add(time.Duration(i) * time.Hour), Open: o, High: h, Low: l, Close: c, Vol: 1}
+}
+
+func feed(t testing.TB, e *Engine, bars ...Kline) {
+	t.Helper()
+	for _, kl := range bars {
+		if err := e.OnCandle(kl.Symbol, kl.TF, kl); err != nil {
+			t.Fatalf("OnCandle %s: %v", kl.Ts, err)
+		}
+	}
+}
+
+func near(a, b float64) bool {
+	d := a - b
+	return d < 1e-9 && d > -1e-9
+}
@@ -0,0 +1,223 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Optional strategy lifecycle. The engine invokes these under its lock, in
+// live trading and in backtests alike, so implementations must not call back
+// into the Engine.
+
+// Starter is implemented by strategies that initialize from the account they
+// inherit. OnStart runs before the first candle after the strategy is
+// attached, once restored positions and orders are in place and warmup
+// history was replayed; an error leaves the strategy unstarted and is retried
+// on the next candle.
+type Starter interface {
+	OnStart(acct AccountState) error
+}
+
+// Stopper is implemented by strategies that release state when they are
+// swapped out or the engine stops.
+type Stopper interface {
+	OnStop(acct AccountState)
+}
+
+// FillObserver is implemented by strategies that track their executions.
+// OnFill gets every open, add, reduce and exit (SL, TP, liquidation, manual,
+// flatten) with the quantity and price actually executed, and the account
+// after it.
+type FillObserver interface {
+	OnFill(ev TradeEvent, acct AccountState)
+}
+
+// Relayer is implemented by strategies whose resting orders follow the
+// position, e.g. a take-profit that has to cover every add. Relay runs once
+// the fills of a candle's exits, order matching or signal are booked, for each
+// symbol that had one; its signal's Cancel and Orders apply at once, its
+// Action is ignored.
+type Relayer interface {
+	Relay(sym string, acct AccountState) Signal
+}
+
+// RiskDecision tells a strategy what became of its signal before execution.
+type RiskDecision struct {
+	TS       time.Time
+	Symbol   string
+	TF       string
+	Signal   Signal // as emitted by the strategy
+	Approved Signal // as it executes when adjusted; zero when rejected
+	Rejected bool
+	Reason   string
+	Tag      string // Order.Tag when a resting order of the signal was rejected
+}
+
+// RiskObserver is implemented by strategies that want to know when the risk
+// model adjusts a signal (e.g. caps SizePct) or a signal is rejected by risk,
+// a trading hold, the trading state or the broker.
+type RiskObserver interface {
+	OnRisk(d RiskDecision)
+}
+
+// OrderObserver is implemented by strategies that keep track of their resting
+// orders. OnOrderClosed gets every order that leaves the book unfilled:
+// canceled by the user or the engine, expired, or rejected when it matched.
+// Orders the strategy cancels itself (Signal.Cancel) are not reported.
+type OrderObserver interface {
+	OnOrderClosed(o Order)
+}
+
+// Timer is implemented by strategies that act on time rather than candles,
+// e.g. to time out a position. OnTimer runs every Every() of engine clock
+// time, checked on each candle and Tick; missed periods are not replayed. Its
+// signal acts on the most recently traded symbol (acct.Position).
+type Timer interface {
+	Every() time.Duration
+	OnTimer(now time.Time, acct AccountState) (Signal, error)
+}
+
+// StatusReporter is implemented by strategies with state worth showing, e.g.
+// grid levels. Status returns a JSON-encodable snapshot.
+type StatusReporter interface {
+	Status() any
+}
+
+// StrategyStatus returns the strategy's StatusReporter snapshot, taken under
+// the engine lock.
+func (e *Engine) StrategyStatus() (any, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	r, ok := e.strat.(StatusReporter)
+	if !ok {
+		return nil, false
+	}
+	return r.Status(), true
+}
+
+// Tick runs strategy timers that are due. Candles run them too; a live bot
+// also calls Tick periodically so timers fire between sparse candles. A
+// strategy starts on its first candle, so Tick does nothing before that.
+func (e *Engine) Tick() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.strat == nil {
+		return nil
+	}
+	defer e.relay("")
+	return e.runTimers()
+}
+
+// Stop ends the strategy's run: a Stopper gets OnStop with the final
+// account. The engine stays usable; the next candle starts the strategy again.
+func (e *Engine) Stop() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.stop()
+}
+
+func (e *Engine) start(sym, tf string) error {
+	if e.started {
+		return nil
+	}
+	if s, ok := e.strat.(Starter); ok {
+		if err := s.OnStart(e.snapshot(sym)); err != nil {
+			err = fmt.Errorf("start: %w", err)
+			e.bus.Publish(StrategyError{TS: e.clock.Now(), Symbol: sym, TF: tf, Strategy: e.strat.Name(), Err: err})
+			return err
+		}
+	}
+	e.started = true
+	e.nextTimer = time.Time{}
+	return nil
+}
+
+func (e *Engine) stop() {
+	if !e.started {
+		return
+	}
+	e.started = false
+	if s, ok := e.strat.(Stopper); ok {
+		s.OnStop(e.snapshot(e.lastSym))
+	}
+}
+
+func (e *Engine) runTimers() error {
+	t, ok := e.strat.(Timer)
+	if !ok || !e.started || t.Every() <= 0 {
+		return nil
+	}
+	every, now := t.Every(), e.clock.Now()
+	if e.nextTimer.IsZero() {
+		e.nextTimer = now.Add(every)
+		return nil
+	}
+	if now.Before(e.nextTimer) {
+		return nil
+	}
+	e.nextTimer = e.nextTimer.Add((now.Sub(e.nextTimer)/every + 1) * every)
+	sym := e.lastSym
+	acct := e.snapshot(sym)
+	sig, err := t.OnTimer(now, acct)
+	if err != nil {
+		e.bus.Publish(StrategyError{TS: now, Symbol: sym, Strategy: e.strat.Name(), Err: err})
+		return err
+	}
+	if sym == "" || e.lastPx[sym] <= 0 {
+		return nil // nothing traded yet
+	}
+	return e.act(now, sym, "", e.lastPx[sym], sig, acct)
+}
+
+// relay hands the symbols filled since the last call to a Relayer.
+func (e *Engine) relay(tf string) {
+	if len(e.relayDue) == 0 {
+		return
+	}
+	syms := make([]string, 0, len(e.relayDue))
+	for sym := range e.relayDue {
+		syms = append(syms, sym)
+	}
+	clear(e.relayDue)
+	r, ok := e.strat.(Relayer)
+	if !ok || !e.started {
+		return
+	}
+	sort.Strings(syms)
+	ts := e.clock.Now()
+	for _, sym := range syms {
+		sig := r.Relay(sym, e.snapshot(sym))
+		if sig.Cancel {
+			e.cancelOwn(ts, sym, tf)
+		}
+		e.placeSignalOrders(ts, sym, tf, sig.Orders)
+	}
+}
+
+// reject publishes RiskRejected for d's signal and tells the strategy.
+func (e *Engine) reject(d RiskDecision, reason string) {
+	e.bus.Publish(RiskRejected{TS: d.TS, Symbol: d.Symbol, TF: d.TF, Signal: d.Signal, Reason: reason})
+	d.Approved, d.Rejected, d.Reason = Signal{}, true, reason
+	e.observeRisk(d)
+}
+
+func (e *Engine) observeRisk(d RiskDecision) {
+	if o, ok := e.strat.(RiskObserver); ok {
+		o.OnRisk(d)
+	}
+}
+
+// signalChanged reports whether the risk model altered anything that affects
+// execution.
+func signalChanged(a, b Signal) bool {
+	return a.Action != b.Action || a.SizePct != b.SizePct || a.Leg != b.Leg || a.Type != b.Type ||
+		a.Price != b.Price || a.StopPrice != b.StopPrice || !samePtr(a.SL, b.SL) || !samePtr(a.TP, b.TP)
+}
+
+func samePtr(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
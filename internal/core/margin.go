@@ -0,0 +1,119 @@
+package core
+
+import (
+	"errors"
+	"strings"
+)
+
+// MarginMode selects how futures positions are collateralized.
+type MarginMode int
+
+const (
+	NoMargin MarginMode = iota // spot-like: no margin, no liquidation
+	Isolated                   // each position is backed by its own initial margin
+	Cross                      // all positions share the account balance
+)
+
+// ParseMarginMode maps "isolated" | "cross" to a mode; anything else yields
+// NoMargin.
+func ParseMarginMode(s string) MarginMode {
+	switch strings.ToLower(s) {
+	case "isolated":
+		return Isolated
+	case "cross":
+		return Cross
+	}
+	return NoMargin
+}
+
+func (m MarginMode) String() string {
+	return [...]string{"none", "isolated", "cross"}[m]
+}
+
+type MarginConfig struct {
+	Mode      MarginMode
+	Leverage  float64 // notional / initial margin, >= 1
+	MaintRate float64 // maintenance margin rate of notional, e.g. 0.004
+}
+
+// FeeConfig holds trading fee rates as a share of notional: Maker for resting
+// limit orders filled passively, Taker for everything else.
+type FeeConfig struct {
+	Maker, Taker float64
+}
+
+func (e *Engine) feeOf(qty, px float64, maker bool) float64 {
+	rate := e.fees.Taker
+	if maker {
+		rate = e.fees.Maker
+	}
+	if rate <= 0 || qty <= 0 || px <= 0 {
+		return 0
+	}
+	return qty * px * rate
+}
+
+// EvLiquidation is logged when a position is force-closed at its
+// liquidation price.
+const EvLiquidation = "LIQUIDATION"
+
+var errInsufficientMargin = errors.New("insufficient margin")
+
+func (e *Engine) leverage() float64 {
+	if e.margin.Leverage < 1 {
+		return 1
+	}
+	return e.margin.Leverage
+}
+
+// postMargin checks that opening qty at px fits into the free margin and
+// returns the initial margin to attach to the position.
+func (e *Engine) postMargin(qty, px float64) (float64, error) {
+	if e.margin.Mode == NoMargin {
+		return 0, nil
+	}
+	im := qty * px / e.leverage()
+	acct := e.snapshot("")
+	if im > acct.NetEquity-acct.MarginUsed+qtyEps {
+		return 0, errInsufficientMargin
+	}
+	return im, nil
+}
+
+// maintMargin is the maintenance margin of p at px.
+func (e *Engine) maintMargin(p *position, px float64) float64 {
+	return p.qty * px * e.margin.MaintRate
+}
+
+// liqPrice returns the price at which the position at k is liquidated, or 0
+// when there is none (no margin model, or the collateral covers any move).
+func (e *Engine) liqPrice(k posKey) float64 {
+	p := e.book.get(k)
+	if p == nil || e.margin.Mode == NoMargin || p.qty <= 0 {
+		return 0
+	}
+	collateral := p.margin
+	if e.margin.Mode == Cross {
+		// the whole balance backs the position, less what the others need
+		collateral = e.eqUSD
+		for _, ok := range e.book.keys() {
+			if ok == k {
+				continue
+			}
+			op := e.book.get(ok)
+			px := e.lastPx[ok.sym]
+			collateral += op.view(ok, px).Unreal - e.maintMargin(op, px)
+		}
+	}
+	mmr := e.margin.MaintRate
+	var liq float64
+	if p.side == Buy {
+		liq = (p.entry*p.qty - collateral) / (p.qty * (1 - mmr))
+	} else {
+		liq = (p.entry*p.qty + collateral) / (p.qty * (1 + mmr))
+	}
+	if liq <= 0 {
+		return 0
+	}
+	return liq
+}
@@ -0,0 +1,57 @@
+package core
+
+import "testing"
+
+func TestIsolatedLiquidationGapLosesOnlyMargin(t *testing.T) {
+	s := &scripted{sigs: map[int]Signal{0: {Action: Buy, SizePct: 0.5}}}
+	e, rec := newTestEngine(t, EngineOpts{Margin: MarginConfig{Mode: Isolated, Leverage: 10, MaintRate: 0.005}}, s)
+	feed(t, e, bar(0, 100, 100, 100, 100))
+	open := rec.trades()
+	if len(open) != 1 || open[0].Event != "OPEN" {
+		t.Fatalf("trades after entry = %+v, want one OPEN", open)
+	}
+	posted := open[0].Qty * open[0].Price / 10
+
+	// the next bar opens far below the liquidation price (~90.5)
+	feed(t, e, bar(1, 50, 50, 40, 45))
+	trades := rec.trades()
+	liq := trades[len(trades)-1]
+	if liq.Event != EvLiquidation {
+		t.Fatalf("last event = %s, want %s", liq.Event, EvLiquidation)
+	}
+	if !near(liq.PnL, -posted) {
+		t.Errorf("liquidation PnL = %v, want %v (the leg's margin)", liq.PnL, -posted)
+	}
+	if got := e.EquityUSD(); !near(got, 10000-posted) {
+		t.Errorf("equity = %v, want %v", got, 10000-posted)
+	}
+}
+
+func TestFeesChargedPerFill(t *testing.T) {
+	s := &scripted{sigs: map[int]Signal{0: {Action: Buy, SizePct: 0.5}, 1: {Action: Close}}}
+	e, rec := newTestEngine(t, EngineOpts{Fees: FeeConfig{Maker: 0.0002, Taker: 0.001}}, s)
+	feed(t, e, bar(0, 100, 100, 100, 100), bar(1, 110, 110, 110, 110))
+	trades := rec.trades()
+	if len(trades) != 2 {
+		t.Fatalf("trades = %+v, want entry and exit", trades)
+	}
+	var fees float64
+	for _, tr := range trades {
+		if want := tr.Qty * tr.Price * 0.001; !near(tr.Fee, want) {
+			t.Errorf("%s fee = %v, want %v", tr.Event, tr.Fee, want)
+		}
+		fees += tr.Fee
+	}
+	if want := 10000 + trades[1].PnL - fees; !near(e.EquityUSD(), want) {
+		t.Errorf("equity = %v, want %v", e.EquityUSD(), want)
+	}
+	var deals []DealClosed
+	for _, ev := range rec.events {
+		if dc, ok := ev.(DealClosed); ok {
+			deals = append(deals, dc)
+		}
+	}
+	if len(deals) != 1 || !near(deals[0].Deal.Fees, fees) {
+		t.Fatalf("deals = %+v, want one with fees %v", deals, fees)
+	}
+}
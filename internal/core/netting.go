@@ -0,0 +1,43 @@
+package core
+
+import "strings"
+
+// OppositePolicy decides what a Buy/Sell signal does to a position on the
+// other side.
+type OppositePolicy int
+
+const (
+	Reverse        OppositePolicy = iota // close the position, then open the signal size on the new side
+	Net                                  // net the signal size against the position
+	CloseOnly                            // close the position, never flip
+	IgnoreOpposite                       // drop the signal
+)
+
+// OppositePolicyProvider is implemented by strategies that choose their own
+// opposite-signal handling instead of EngineOpts.Opposite.
+type OppositePolicyProvider interface {
+	OppositePolicy() OppositePolicy
+}
+
+// ParseOppositePolicy maps "reverse" | "net" | "close" | "ignore" to a policy;
+// anything else yields Reverse.
+func ParseOppositePolicy(s string) OppositePolicy {
+	switch strings.ToLower(s) {
+	case "net":
+		return Net
+	case "close", "close_only":
+		return CloseOnly
+	case "ignore":
+		return IgnoreOpposite
+	}
+	return Reverse
+}
+
+const qtyEps = 1e-12
+
+func (e *Engine) oppositePolicy() OppositePolicy {
+	if p, ok := e.strat.(OppositePolicyProvider); ok {
+		return p.OppositePolicy()
+	}
+	return e.opposite
+}
@@ -0,0 +1,449 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+type OrderType int
+
+const (
+	Market OrderType = iota
+	Limit
+	Stop
+	StopLimit
+)
+
+func (t OrderType) String() string {
+	return [...]string{"MARKET", "LIMIT", "STOP", "STOP_LIMIT"}[t]
+}
+
+type TimeInForce int
+
+const (
+	GTC TimeInForce = iota // good till cancel
+	IOC                    // lives for the next candle only
+	GTD                    // good till Order.ExpireAt
+)
+
+type OrderStatus int
+
+const (
+	StatusNew OrderStatus = iota
+	StatusTriggered
+	StatusFilled
+	StatusCanceled
+	StatusExpired
+	StatusRejected // matched, but the fill could not be applied
+)
+
+func (s OrderStatus) String() string {
+	return [...]string{"NEW", "TRIGGERED", "FILLED", "CANCELED", "EXPIRED", "REJECTED"}[s]
+}
+
+// Order is a resting paper order matched against later candles.
+type Order struct {
+	ID        string      `json:"id"`
+	Symbol    string      `json:"symbol"`
+	Side      Action      `json:"side"`          // Buy | Sell
+	Leg       Leg         `json:"leg,omitempty"` // hedge mode target leg, see Signal.Leg
+	Type      OrderType   `json:"type"`
+	Qty       float64     `json:"qty,omitempty"` // base qty; 0 = size from SizePct at fill time
+	SizePct   float64     `json:"sizePct,omitempty"`
+	Price     float64     `json:"price,omitempty"`     // limit price (Limit, StopLimit)
+	StopPrice float64     `json:"stopPrice,omitempty"` // trigger price (Stop, StopLimit)
+	TIF       TimeInForce `json:"tif,omitempty"`
+	ExpireAt  time.Time   `json:"expireAt,omitempty"`
+	SL        *float64    `json:"sl,omitempty"` // protective levels attached to the position on fill
+	TP        *float64    `json:"tp,omitempty"`
+	Status    OrderStatus `json:"status"`
+	Created   time.Time   `json:"created"`
+	Comment   string      `json:"comment,omitempty"`
+	Tag       string      `json:"tag,omitempty"` // placer's label, echoed in the TradeEvent of its fill
+	// AddOnly orders only grow an open position of their side, e.g. DCA
+	// safety orders: placing one needs that position, and the paper book
+	// cancels it when the position's deal closes.
+	AddOnly bool `json:"addOnly,omitempty"`
+	// Filled and AvgPrice track a live order's exchange fills so far.
+	Filled   float64 `json:"filled,omitempty"`
+	AvgPrice float64 `json:"avgPrice,omitempty"`
+}
+
+// Order lifecycle event names; OrderUpdated.Event carries one of them.
+const (
+	EvOrderNew       = "ORDER_NEW"
+	EvOrderTriggered = "ORDER_TRIGGERED"
+	EvOrderFilled    = "ORDER_FILLED"
+	EvOrderCanceled  = "ORDER_CANCELED"
+	EvOrderExpired   = "ORDER_EXPIRED"
+	EvOrderReplaced  = "ORDER_REPLACED"
+	EvOrderRejected  = "ORDER_REJECTED"
+	EvOrderPartial   = "ORDER_PARTIAL" // a live order filled in part
+)
+
+type orderBook struct {
+	seq  int
+	open []*Order // in placement order
+}
+
+func (b *orderBook) find(id string) *Order {
+	for _, o := range b.open {
+		if o.ID == id {
+			return o
+		}
+	}
+	return nil
+}
+
+func (b *orderBook) remove(id string) {
+	for i, o := range b.open {
+		if o.ID == id {
+			b.open = append(b.open[:i], b.open[i+1:]...)
+			return
+		}
+	}
+}
+
+func (b *orderBook) pending(sym string) []*Order {
+	var out []*Order
+	for _, o := range b.open {
+		if sym == "" || o.Symbol == sym {
+			out = append(out, o)
+		}
+	}
+	return out
+}
+
+// PlaceOrder validates o against the risk model and rests it in the book.
+// It becomes eligible for matching from the next candle of its symbol.
+func (e *Engine) PlaceOrder(o Order) (string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err := e.checkOrder(&o); err != nil {
+		return "", err
+	}
+	return e.placeOrder(e.clock.Now(), "", o)
+}
+
+// placeSignalOrders rests the orders a strategy signal carries, each checked
+// as by PlaceOrder. A rejected one is reported to the strategy.
+func (e *Engine) placeSignalOrders(ts time.Time, sym, tf string, orders []Order) {
+	for _, o := range orders {
+		if o.Symbol == "" {
+			o.Symbol = sym
+		}
+		err := e.checkOrder(&o)
+		if err == nil {
+			_, err = e.placeOrder(ts, tf, o)
+		}
+		if err != nil {
+			e.reject(RiskDecision{TS: ts, Symbol: o.Symbol, TF: tf, Signal: Signal{
+				Action: o.Side, SizePct: o.SizePct, SL: o.SL, TP: o.TP, Comment: o.Comment, Leg: o.Leg,
+				Type: o.Type, Price: o.Price, StopPrice: o.StopPrice, TIF: o.TIF, ExpireAt: o.ExpireAt,
+			}, Tag: o.Tag}, err.Error())
+		}
+	}
+}
+
+// checkOrder validates a new order and sizes it through the risk model
+// unless it has a fixed Qty.
+func (e *Engine) checkOrder(o *Order) error {
+	if o.Side != Buy && o.Side != Sell {
+		return errors.New("order side must be buy or sell")
+	}
+	if e.reconHold() {
+		return errReconHold
+	}
+	if e.trading != TradingRunning {
+		return fmt.Errorf("trading %s: new orders are not accepted", e.trading)
+	}
+	if err := validateOrderPrices(*o); err != nil {
+		return err
+	}
+	if o.AddOnly {
+		if p := e.book.get(posKey{o.Symbol, e.legFor(o.Leg, o.Side)}); p == nil || p.side != o.Side {
+			return fmt.Errorf("add-only order needs an open %s position", actionName(o.Side))
+		}
+	}
+	if o.Qty <= 0 {
+		acct := e.snapshot(o.Symbol)
+		sig, err := e.risk.Validate(Signal{Action: o.Side, SizePct: o.SizePct, SL: o.SL, TP: o.TP, Comment: o.Comment}, acct, o.refPrice())
+		if err != nil {
+			return err
+		}
+		o.SizePct = sig.SizePct
+	}
+	return nil
+}
+
+// CancelOrder cancels a pending order by ID.
+func (e *Engine) CancelOrder(id string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.live != nil {
+		return e.cancelLive(e.clock.Now(), "", "", id)
+	}
+	o := e.orders.find(id)
+	if o == nil {
+		return fmt.Errorf("order %s not found", id)
+	}
+	e.closeOrder(e.clock.Now(), "", o, StatusCanceled, EvOrderCanceled)
+	return nil
+}
+
+// ReplaceOrder amends the prices and quantity of a pending order; zero values
+// keep the current ones.
+func (e *Engine) ReplaceOrder(id string, price, stopPrice, qty float64) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.live != nil {
+		return errors.New("replace is not supported by live brokers; cancel and place again")
+	}
+	o := e.orders.find(id)
+	if o == nil {
+		return fmt.Errorf("order %s not found", id)
+	}
+	upd := *o
+	if price > 0 {
+		upd.Price = price
+	}
+	if stopPrice > 0 {
+		upd.StopPrice = stopPrice
+	}
+	if qty > 0 {
+		upd.Qty = qty
+	}
+	if err := validateOrderPrices(upd); err != nil {
+		return err
+	}
+	*o = upd
+	e.emitOrder(e.clock.Now(), "", o, EvOrderReplaced)
+	return nil
+}
+
+// Orders returns copies of the pending orders in sym ("" = all symbols).
+func (e *Engine) Orders(sym string) []Order {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.live != nil {
+		return e.liveOrders(sym)
+	}
+	var out []Order
+	for _, o := range e.orders.pending(sym) {
+		out = append(out, *o)
+	}
+	return out
+}
+
+func validateOrderPrices(o Order) error {
+	switch o.Type {
+	case Limit:
+		if o.Price <= 0 {
+			return errors.New("limit order needs price")
+		}
+	case Stop:
+		if o.StopPrice <= 0 {
+			return errors.New("stop order needs stop price")
+		}
+	case StopLimit:
+		if o.Price <= 0 || o.StopPrice <= 0 {
+			return errors.New("stop-limit order needs price and stop price")
+		}
+	default:
+		return errors.New("only limit, stop and stop-limit orders can rest")
+	}
+	return nil
+}
+
+func (o *Order) refPrice() float64 {
+	if o.Type == Stop {
+		return o.StopPrice
+	}
+	return o.Price
+}
+
+func (e *Engine) placeOrder(ts time.Time, tf string, o Order) (string, error) {
+	if e.live != nil {
+		return e.placeLive(ts, tf, o)
+	}
+	e.orders.seq++
+	o.ID = fmt.Sprintf("o%d", e.orders.seq)
+	o.Status = StatusNew
+	o.Created = ts
+	e.orders.open = append(e.orders.open, &o)
+	e.notify(ts, "%s %s %s placed | px=%.2f stop=%.2f %s", o.ID, o.Type, actionName(o.Side), o.Price, o.StopPrice, o.Comment)
+	e.bus.Publish(OrderPlaced{TS: ts, TF: tf, Order: o})
+	return o.ID, nil
+}
+
+func (e *Engine) cancelAll(ts time.Time, sym, tf string) {
+	if e.live != nil {
+		if err := e.cancelLive(ts, sym, tf, ""); err != nil {
+			e.notify(ts, "cancel %s orders: %v", sym, err)
+		}
+		return
+	}
+	for _, o := range e.orders.pending(sym) {
+		e.closeOrder(ts, tf, o, StatusCanceled, EvOrderCanceled)
+	}
+}
+
+// cancelAddOnly cancels the AddOnly orders of the position at k once it is
+// flat.
+func (e *Engine) cancelAddOnly(ts time.Time, k posKey, tf string) {
+	for _, o := range e.orders.pending(k.sym) {
+		if !o.AddOnly || e.legFor(o.Leg, o.Side) != k.leg {
+			continue
+		}
+		if e.live == nil {
+			e.closeOrder(ts, tf, o, StatusCanceled, EvOrderCanceled)
+		} else if err := e.cancelLive(ts, k.sym, tf, o.ID); err != nil {
+			e.notify(ts, "cancel %s: %v", o.ID, err)
+		}
+	}
+}
+
+func (e *Engine) closeOrder(ts time.Time, tf string, o *Order, st OrderStatus, event string) {
+	o.Status = st
+	e.orders.remove(o.ID)
+	e.emitOrder(ts, tf, o, event)
+	e.orderClosed(o)
+}
+
+// orderClosed tells an OrderObserver that o left the book unfilled, unless
+// the strategy canceled it.
+func (e *Engine) orderClosed(o *Order) {
+	if ob, ok := e.strat.(OrderObserver); ok && !e.ownCancel {
+		ob.OnOrderClosed(*o)
+	}
+}
+
+// cancelOwn cancels sym's orders for the strategy, see OrderObserver.
+func (e *Engine) cancelOwn(ts time.Time, sym, tf string) {
+	e.ownCancel = true
+	defer func() { e.ownCancel = false }()
+	e.cancelAll(ts, sym, tf)
+}
+
+func (e *Engine) emitOrder(ts time.Time, tf string, o *Order, event string) {
+	e.bus.Publish(OrderUpdated{TS: ts, TF: tf, Event: event, Order: *o})
+}
+
+// reasonNotFilled explains a fill that could not be applied.
+const reasonNotFilled = "not filled: insufficient margin or no leg to reduce"
+
+// matchOrders runs the paper matching step for the pending orders in sym;
+// a live account polls the exchange for their fills instead.
+func (e *Engine) matchOrders(sym, tf string, kl Kline) {
+	ts := e.clock.Now()
+	if e.live != nil {
+		e.syncLive(ts, sym, tf)
+		return
+	}
+	for _, o := range e.orders.pending(sym) {
+		if e.orders.find(o.ID) == nil {
+			continue // canceled by an earlier fill, see cancelAddOnly
+		}
+		if o.TIF == GTD && !o.ExpireAt.IsZero() && !kl.Ts.Before(o.ExpireAt) {
+			e.closeOrder(ts, tf, o, StatusExpired, EvOrderExpired)
+			continue
+		}
+		k := posKey{sym, e.legFor(o.Leg, o.Side)}
+		if e.trading == TradingPaused || e.trading == TradingHalted || e.trading == TradingCloseOnly && !e.reduces(k, o.Side) {
+			continue // rests until trading resumes
+		}
+		px, ok := e.matchOrder(ts, tf, o, kl)
+		if ok {
+			qty := o.Qty
+			if qty <= 0 {
+				qty = e.sizeUSD(o.SizePct) / px
+			}
+			if e.trading == TradingCloseOnly {
+				qty = minf(qty, e.book.get(k).qty)
+			}
+			o.Qty = qty
+			e.orders.remove(o.ID)
+			if !e.fill(ts, k, tf, o.Side, qty, px, o.SL, o.TP, o.Comment, o) {
+				o.Status = StatusRejected
+				e.bus.Publish(OrderUpdated{TS: ts, TF: tf, Event: EvOrderRejected, Order: *o, Reason: reasonNotFilled})
+				e.orderClosed(o)
+				continue
+			}
+			o.Status = StatusFilled
+			e.bus.Publish(OrderFilled{TS: ts, TF: tf, Order: *o, Price: px})
+			continue
+		}
+		if o.TIF == IOC {
+			e.closeOrder(ts, tf, o, StatusExpired, EvOrderExpired)
+		}
+	}
+}
+
+// matchOrder reports whether kl fills o and at which price. A candle that
+// opens through the order price fills at the open. A stop-limit fills on its
+// trigger candle only if the limit is marketable at the trigger price, at that
+// price: the bar does not tell whether the rest of its range came after the
+// trigger, so a limit beyond the trigger waits for later candles.
+func (e *Engine) matchOrder(ts time.Time, tf string, o *Order, kl Kline) (float64, bool) {
+	switch o.Type {
+	case Limit:
+		return limitFill(o.Side, o.Price, kl)
+	case Stop:
+		return stopFill(o.Side, o.StopPrice, kl)
+	case StopLimit:
+		if o.Status == StatusTriggered {
+			return limitFill(o.Side, o.Price, kl)
+		}
+		if _, hit := stopFill(o.Side, o.StopPrice, kl); !hit {
+			return 0, false
+		}
+		o.Status = StatusTriggered
+		e.emitOrder(ts, tf, o, EvOrderTriggered)
+		trig, _ := stopFill(o.Side, o.StopPrice, kl)
+		if o.Side == Buy && trig <= o.Price || o.Side == Sell && trig >= o.Price {
+			return trig, true
+		}
+	}
+	return 0, false
+}
+
+func limitFill(side Action, price float64, kl Kline) (float64, bool) {
+	if side == Buy {
+		return minf(kl.Open, price), kl.Low <= price
+	}
+	return maxf(kl.Open, price), kl.High >= price
+}
+
+func stopFill(side Action, stop float64, kl Kline) (float64, bool) {
+	if side == Buy {
+		return maxf(kl.Open, stop), kl.High >= stop
+	}
+	return minf(kl.Open, stop), kl.Low <= stop
+}
+
+func actionName(a Action) string {
+	switch a {
+	case Buy:
+		return "BUY"
+	case Sell:
+		return "SELL"
+	case Close:
+		return "CLOSE"
+	}
+	return "NONE"
+}
+
+func minf(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxf(a, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,151 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMatchOrder(t *testing.T) {
+	tests := []struct {
+		name   string
+		o      Order
+		kl     Kline
+		px     float64
+		filled bool
+	}{
+		{"limit buy touched", Order{Side: Buy, Type: Limit, Price: 95}, bar(0, 100, 101, 94, 99), 95, true},
+		{"limit buy gap below", Order{Side: Buy, Type: Limit, Price: 95}, bar(0, 90, 92, 89, 91), 90, true},
+		{"limit buy missed", Order{Side: Buy, Type: Limit, Price: 95}, bar(0, 100, 101, 96, 99), 0, false},
+		{"limit sell touched", Order{Side: Sell, Type: Limit, Price: 105}, bar(0, 100, 106, 99, 101), 105, true},
+		{"stop buy touched", Order{Side: Buy, Type: Stop, StopPrice: 105}, bar(0, 100, 106, 99, 101), 105, true},
+		{"stop buy gap above", Order{Side: Buy, Type: Stop, StopPrice: 105}, bar(0, 108, 109, 107, 108), 108, true},
+		{"stop sell touched", Order{Side: Sell, Type: Stop, StopPrice: 95}, bar(0, 100, 101, 94, 99), 95, true},
+		{"stop-limit buy marketable at trigger", Order{Side: Buy, Type: StopLimit, StopPrice: 105, Price: 106}, bar(0, 100, 107, 99, 101), 105, true},
+		{"stop-limit buy limit below trigger waits", Order{Side: Buy, Type: StopLimit, StopPrice: 105, Price: 103}, bar(0, 100, 107, 99, 101), 0, false},
+		{"stop-limit buy gap past limit waits", Order{Side: Buy, Type: StopLimit, StopPrice: 105, Price: 106}, bar(0, 108, 109, 100, 101), 0, false},
+		{"stop-limit sell marketable at trigger", Order{Side: Sell, Type: StopLimit, StopPrice: 95, Price: 94}, bar(0, 100, 101, 90, 99), 95, true},
+		{"stop-limit triggered earlier fills as limit", Order{Side: Buy, Type: StopLimit, StopPrice: 105, Price: 103, Status: StatusTriggered}, bar(0, 104, 104, 102, 103), 103, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, _ := newTestEngine(t, EngineOpts{}, nil)
+			o := tt.o
+			px, ok := e.matchOrder(t0, "1h", &o, tt.kl)
+			if ok != tt.filled || ok && !near(px, tt.px) {
+				t.Fatalf("matchOrder = %v, %v; want %v, %v", px, ok, tt.px, tt.filled)
+			}
+		})
+	}
+}
+
+func TestStopLimitFillsAfterTrigger(t *testing.T) {
+	e, rec := newTestEngine(t, EngineOpts{}, nil)
+	feed(t, e, bar(0, 100, 100, 100, 100))
+	if _, err := e.PlaceOrder(Order{Symbol: "X", Side: Buy, Type: StopLimit, StopPrice: 105, Price: 103, Qty: 1}); err != nil {
+		t.Fatal(err)
+	}
+	feed(t, e, bar(1, 100, 107, 99, 106)) // triggers; 103 not reachable after 105 within the bar
+	if n := rec.count("OrderFilled"); n != 0 {
+		t.Fatalf("filled on the trigger bar")
+	}
+	if got := rec.orderEvents(EvOrderTriggered); len(got) != 1 {
+		t.Fatalf("triggered events = %d, want 1", len(got))
+	}
+	feed(t, e, bar(2, 104, 104, 102, 103))
+	tr := rec.trades()
+	if len(tr) != 1 || tr[0].Event != "OPEN" || !near(tr[0].Price, 103) {
+		t.Fatalf("trades = %+v, want OPEN at 103", tr)
+	}
+}
+
+func TestRejectedFillIsNotReportedFilled(t *testing.T) {
+	e, rec := newTestEngine(t, EngineOpts{EqUSD: 1000, Margin: MarginConfig{Mode: Isolated, Leverage: 1, MaintRate: 0.004}}, nil)
+	feed(t, e, bar(0, 100, 100, 100, 100))
+	id, err := e.PlaceOrder(Order{Symbol: "X", Side: Buy, Type: Limit, Price: 95, Qty: 50}) // 4750 notional on 1000 margin
+	if err != nil {
+		t.Fatal(err)
+	}
+	feed(t, e, bar(1, 100, 100, 94, 96))
+	if n := rec.count("OrderFilled"); n != 0 {
+		t.Fatalf("OrderFilled published for a rejected fill")
+	}
+	rej := rec.orderEvents(EvOrderRejected)
+	if len(rej) != 1 || rej[0].Order.ID != id || rej[0].Order.Status != StatusRejected || rej[0].Reason == "" {
+		t.Fatalf("rejected events = %+v", rej)
+	}
+	if len(e.Orders("X")) != 0 {
+		t.Fatalf("rejected order still rests")
+	}
+	if p := e.Snapshot().Position; p.Side != None {
+		t.Fatalf("position opened: %+v", p)
+	}
+}
+
+func TestFilledOrderEventFollowsFill(t *testing.T) {
+	s := &scripted{}
+	e, rec := newTestEngine(t, EngineOpts{}, s)
+	feed(t, e, bar(0, 100, 100, 100, 100))
+	if _, err := e.PlaceOrder(Order{Symbol: "X", Side: Buy, Type: Limit, Price: 99, Qty: 1, Tag: "t"}); err != nil {
+		t.Fatal(err)
+	}
+	feed(t, e, bar(1, 100, 100, 98, 99))
+	if rec.count("OrderFilled") != 1 || len(s.fills) != 1 || s.fills[0].OrderTag != "t" || !s.fills[0].Maker {
+		t.Fatalf("filled=%d fills=%+v", rec.count("OrderFilled"), s.fills)
+	}
+}
+
+func TestCandleFillsStampedAtBarClose(t *testing.T) {
+	s := &scripted{sigs: map[int]Signal{0: {Action: Buy, SizePct: 0.1, Orders: []Order{{Side: Sell, Type: Limit, Price: 105, Qty: 10}}}}}
+	e, rec := newTestEngine(t, EngineOpts{}, s)
+	feed(t, e, bar(0, 100, 100, 100, 100), bar(1, 100, 106, 100, 104))
+	trades := rec.trades()
+	if len(trades) != 2 {
+		t.Fatalf("trades = %+v, want entry and limit exit", trades)
+	}
+	for i, tr := range trades {
+		if want := t0.Add(time.Duration(i+1) * time.Hour); !tr.TS.Equal(want) {
+			t.Errorf("%s at %s, want the bar close %s", tr.Event, tr.TS, want)
+		}
+	}
+	var deal Deal
+	for _, ev := range rec.events {
+		if dc, ok := ev.(DealClosed); ok {
+			deal = dc.Deal
+		}
+	}
+	if !deal.Opened.Equal(t0.Add(time.Hour)) || !deal.Closed.Equal(t0.Add(2*time.Hour)) {
+		t.Errorf("deal %s..%s, want bar closes", deal.Opened, deal.Closed)
+	}
+}
+
+// watcher is scripted, and records the orders closed under it.
+type watcher struct {
+	scripted
+	closed []Order
+}
+
+func (w *watcher) OnOrderClosed(o Order) { w.closed = append(w.closed, o) }
+
+func TestOrderObserverSeesOnlyOrdersItDidNotCancel(t *testing.T) {
+	rest := Order{Side: Buy, Type: Limit, Price: 90, Qty: 1}
+	w := &watcher{scripted: scripted{sigs: map[int]Signal{
+		0: {Orders: []Order{rest, rest}},
+		1: {Cancel: true},
+	}}}
+	e, _ := newTestEngine(t, EngineOpts{}, w)
+	feed(t, e, bar(0, 100, 100, 100, 100))
+	orders := e.Orders("X")
+	if len(orders) != 2 {
+		t.Fatalf("orders = %+v, want two", orders)
+	}
+	if err := e.CancelOrder(orders[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	feed(t, e, bar(1, 100, 100, 100, 100))
+	if len(w.closed) != 1 || w.closed[0].ID != orders[0].ID || w.closed[0].Status != StatusCanceled {
+		t.Errorf("closed = %+v, want only the user-canceled %s", w.closed, orders[0].ID)
+	}
+	if n := len(e.Orders("X")); n != 0 {
+		t.Errorf("%d orders left, want the strategy's cancel to clear them", n)
+	}
+}
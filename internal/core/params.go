@@ -0,0 +1,176 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type ParamType string
+
+const (
+	ParamInt   ParamType = "int"
+	ParamFloat ParamType = "float"
+)
+
+// Param describes one strategy parameter. Bounds are inclusive.
+type Param struct {
+	Name        string    `json:"name"`
+	Type        ParamType `json:"type"`
+	Default     float64   `json:"default"`
+	Min         float64   `json:"min"`
+	Max         float64   `json:"max"`
+	Description string    `json:"description"`
+}
+
+// Schema describes a strategy kind and its parameters, in positional order.
+type Schema struct {
+	Kind        string   `json:"kind"`
+	Aliases     []string `json:"aliases,omitempty"`
+	Description string   `json:"description"`
+	Params      []Param  `json:"params"`
+	// Spec is the default of a kind configured by a text spec rather than
+	// by numbers (e.g. a composite); it is passed as the "spec" argument.
+	Spec string `json:"spec,omitempty"`
+}
+
+// Parameterized is implemented by strategies that describe their parameters,
+// so callers can validate, render and persist them without knowing the kind.
+type Parameterized interface {
+	Schema() Schema
+	Params() Params
+}
+
+// Params holds validated parameter values by name.
+type Params map[string]float64
+
+func (p Params) Int(name string) int       { return int(math.Round(p[name])) }
+func (p Params) Float(name string) float64 { return p[name] }
+
+// Defaults returns every parameter at its default.
+func (s Schema) Defaults() Params {
+	out := make(Params, len(s.Params))
+	for _, p := range s.Params {
+		out[p.Name] = p.Default
+	}
+	return out
+}
+
+// Param looks a parameter up by name, ignoring case.
+func (s Schema) Param(name string) (Param, bool) {
+	for _, p := range s.Params {
+		if strings.EqualFold(p.Name, name) {
+			return p, true
+		}
+	}
+	return Param{}, false
+}
+
+// Validate checks raw values (numbers, numeric strings, json.Number) against
+// the schema and fills in defaults. Unknown names, non-integral ints and
+// out-of-range values are errors.
+func (s Schema) Validate(raw map[string]any) (Params, error) {
+	out := s.Defaults()
+	names := make([]string, 0, len(raw))
+	for k := range raw {
+		names = append(names, k)
+	}
+	sort.Strings(names) // deterministic first error
+	for _, k := range names {
+		p, ok := s.Param(k)
+		if !ok {
+			return nil, fmt.Errorf("%s: unknown parameter %q", s.Kind, k)
+		}
+		v, err := paramFloat(raw[k])
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %v", s.Kind, p.Name, err)
+		}
+		if err := p.check(v); err != nil {
+			return nil, fmt.Errorf("%s.%s: %v", s.Kind, p.Name, err)
+		}
+		out[p.Name] = v
+	}
+	return out, nil
+}
+
+// ParseArgs reads command-line style values: positional in schema order,
+// or name=value in any order.
+func (s Schema) ParseArgs(args []string) (map[string]any, error) {
+	out := map[string]any{}
+	for i, a := range args {
+		if name, val, ok := strings.Cut(a, "="); ok {
+			out[name] = val
+			continue
+		}
+		if i >= len(s.Params) {
+			return nil, fmt.Errorf("%s: too many arguments (%d parameters)", s.Kind, len(s.Params))
+		}
+		out[s.Params[i].Name] = a
+	}
+	return out, nil
+}
+
+// Usage renders the schema as one line per parameter.
+func (s Schema) Usage() string {
+	var b strings.Builder
+	b.WriteString(s.Kind)
+	if s.Description != "" {
+		b.WriteString(" — " + s.Description)
+	}
+	for _, p := range s.Params {
+		fmt.Fprintf(&b, "\n  %s (%s, %s..%s, default %s) %s", p.Name, p.Type, fmtParam(p.Min), fmtParam(p.Max), fmtParam(p.Default), p.Description)
+	}
+	if s.Spec != "" {
+		fmt.Fprintf(&b, "\n  e.g. %s %s", s.Kind, s.Spec)
+	}
+	return b.String()
+}
+
+// Format renders values in schema order as name=value pairs.
+func (s Schema) Format(p Params) string {
+	parts := make([]string, 0, len(s.Params))
+	for _, sp := range s.Params {
+		parts = append(parts, sp.Name+"="+fmtParam(p[sp.Name]))
+	}
+	return strings.Join(parts, " ")
+}
+
+func (p Param) check(v float64) error {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return fmt.Errorf("not a number")
+	}
+	if p.Type == ParamInt && v != math.Trunc(v) {
+		return fmt.Errorf("%s is not an integer", fmtParam(v))
+	}
+	if v < p.Min || v > p.Max {
+		return fmt.Errorf("%s out of range [%s, %s]", fmtParam(v), fmtParam(p.Min), fmtParam(p.Max))
+	}
+	return nil
+}
+
+func paramFloat(v any) (float64, error) {
+	switch t := v.(type) {
+	case float64:
+		return t, nil
+	case float32:
+		return float64(t), nil
+	case int:
+		return float64(t), nil
+	case int64:
+		return float64(t), nil
+	case json.Number:
+		return t.Float64()
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(t), 64)
+		if err != nil {
+			return 0, fmt.Errorf("%q is not a number", t)
+		}
+		return f, nil
+	}
+	return 0, fmt.Errorf("unsupported value %v", v)
+}
+
+func fmtParam(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
@@ -0,0 +1,143 @@
+package core
+
+import (
+	"sort"
+	"strings"
+)
+
+// AccountMode selects how positions in one symbol are held.
+type AccountMode int
+
+const (
+	OneWay AccountMode = iota // a single net position per symbol
+	Hedge                     // independent long and short legs per symbol
+)
+
+// ParseAccountMode maps "hedge" to Hedge; anything else yields OneWay.
+func ParseAccountMode(s string) AccountMode {
+	if strings.EqualFold(s, "hedge") {
+		return Hedge
+	}
+	return OneWay
+}
+
+// Leg identifies a position side in hedge mode. One-way positions use LegNet.
+type Leg int
+
+const (
+	LegNet Leg = iota
+	LegLong
+	LegShort
+)
+
+func (l Leg) String() string {
+	return [...]string{"", "LONG", "SHORT"}[l]
+}
+
+// side returns the only side a hedge leg can hold.
+func (l Leg) side() Action {
+	switch l {
+	case LegLong:
+		return Buy
+	case LegShort:
+		return Sell
+	}
+	return None
+}
+
+type posKey struct {
+	sym string
+	leg Leg
+}
+
+// positionBook holds the open positions of one Engine keyed by symbol and leg.
+type positionBook struct {
+	m map[posKey]*position
+}
+
+type position struct {
+	side   Action
+	qty    float64
+	entry  float64
+	sl     float64 // 0 = none
+	tp     float64 // 0 = none
+	margin float64 // initial margin posted (margin mode only)
+}
+
+func newPositionBook() *positionBook { return &positionBook{m: map[posKey]*position{}} }
+
+func (b *positionBook) get(k posKey) *position { return b.m[k] }
+
+func (b *positionBook) set(k posKey, side Action, qty, entry float64) *position {
+	p := b.m[k]
+	if p == nil || p.side != side {
+		p = &position{}
+		b.m[k] = p
+	}
+	p.side, p.qty, p.entry = side, qty, entry
+	return p
+}
+
+func (b *positionBook) remove(k posKey) { delete(b.m, k) }
+
+// keys returns the open positions sorted by symbol, then leg.
+func (b *positionBook) keys() []posKey {
+	out := make([]posKey, 0, len(b.m))
+	for k := range b.m {
+		out = append(out, k)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].sym != out[j].sym {
+			return out[i].sym < out[j].sym
+		}
+		return out[i].leg < out[j].leg
+	})
+	return out
+}
+
+// legs returns the open positions in sym.
+func (b *positionBook) legs(sym string) []posKey {
+	var out []posKey
+	for _, l := range []Leg{LegNet, LegLong, LegShort} {
+		if b.m[posKey{sym, l}] != nil {
+			out = append(out, posKey{sym, l})
+		}
+	}
+	return out
+}
+
+func (p *position) view(k posKey, px float64) Position {
+	unrl := 0.0
+	switch p.side {
+	case Buy:
+		unrl = (px - p.entry) * p.qty
+	case Sell:
+		unrl = (p.entry - px) * p.qty
+	}
+	return Position{Symbol: k.sym, Leg: k.leg, Side: p.side, Qty: p.qty, Entry: p.entry, Unreal: unrl, SL: p.sl, TP: p.tp}
+}
+
+// protect updates the protective levels; nil leaves a level unchanged.
+func (p *position) protect(sl, tp *float64) {
+	if sl != nil {
+		p.sl = *sl
+	}
+	if tp != nil {
+		p.tp = *tp
+	}
+}
+
+// legFor resolves the leg a signal or order acts on. In hedge mode an unset
+// leg follows the side: Buy targets the long leg, Sell the short one.
+func (e *Engine) legFor(leg Leg, side Action) Leg {
+	if e.acctMode != Hedge {
+		return LegNet
+	}
+	if leg != LegNet {
+		return leg
+	}
+	if side == Sell {
+		return LegShort
+	}
+	return LegLong
+}
@@ -0,0 +1,318 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ReconcilePolicy decides what startup reconciliation does with mismatches
+// between the local account and the exchange.
+type ReconcilePolicy int
+
+const (
+	ReconcileAlert  ReconcilePolicy = iota // report and hold trading until acknowledged
+	ReconcileAdopt                         // take the exchange positions, balance and orders as truth
+	ReconcileCancel                        // cancel unknown exchange orders; positions still alert
+)
+
+// ParseReconcilePolicy maps "adopt" | "cancel" to a policy; anything else
+// yields ReconcileAlert.
+func ParseReconcilePolicy(s string) ReconcilePolicy {
+	switch strings.ToLower(s) {
+	case "adopt":
+		return ReconcileAdopt
+	case "cancel":
+		return ReconcileCancel
+	}
+	return ReconcileAlert
+}
+
+func (p ReconcilePolicy) String() string {
+	return [...]string{"alert", "adopt", "cancel"}[p]
+}
+
+// Discrepancy is one difference found by reconciliation. Quantities are
+// signed: positive long, negative short.
+type Discrepancy struct {
+	Kind       string  `json:"kind"`   // position | order | balance
+	Source     string  `json:"source"` // state | log: the local record that disagrees
+	Symbol     string  `json:"symbol,omitempty"`
+	Leg        string  `json:"leg,omitempty"`
+	OrderID    string  `json:"orderId,omitempty"`
+	Local      float64 `json:"local"`
+	Remote     float64 `json:"remote"`
+	Resolution string  `json:"resolution"` // adopted | canceled | pending
+}
+
+// ReconcileReport is the outcome of a reconciliation run.
+type ReconcileReport struct {
+	At           time.Time     `json:"at"`
+	Broker       string        `json:"broker"`
+	Policy       string        `json:"policy"`
+	Items        []Discrepancy `json:"items"`
+	Acknowledged bool          `json:"acknowledged"`
+	Err          string        `json:"error,omitempty"`
+}
+
+// Pending reports whether unresolved discrepancies still hold trading.
+func (r ReconcileReport) Pending() bool {
+	if r.Acknowledged {
+		return false
+	}
+	if r.Err != "" {
+		return true
+	}
+	for _, d := range r.Items {
+		if d.Resolution == "pending" {
+			return true
+		}
+	}
+	return false
+}
+
+func (r ReconcileReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Reconciliation with %s (%s): %d item(s)", r.Broker, r.Policy, len(r.Items))
+	if r.Err != "" {
+		fmt.Fprintf(&b, "\nerror: %s", r.Err)
+	}
+	for _, d := range r.Items {
+		switch d.Kind {
+		case "order":
+			fmt.Fprintf(&b, "\norder %s %s: unknown locally → %s", d.OrderID, d.Symbol, d.Resolution)
+		case "balance":
+			fmt.Fprintf(&b, "\nbalance: %s=%.2f exchange=%.2f → %s", d.Source, d.Local, d.Remote, d.Resolution)
+		default:
+			fmt.Fprintf(&b, "\nposition %s%s: %s=%.6f exchange=%.6f → %s", d.Symbol, legSuffix(d.Leg), d.Source, d.Local, d.Remote, d.Resolution)
+		}
+	}
+	if r.Pending() {
+		b.WriteString("\nTrading is on hold until acknowledged.")
+	}
+	return b.String()
+}
+
+func legSuffix(leg string) string {
+	if leg == "" {
+		return ""
+	}
+	return "/" + leg
+}
+
+// balanceTolerance is the relative equity difference reconciliation ignores
+// (fees and funding accrued while the bot was down).
+const balanceTolerance = 0.01
+
+// Reconcile compares the engine account, and the positions replayed from the
+// trade log when given, with the broker account, applies policy and holds
+// trading while discrepancies remain. The report is kept on the engine and
+// published as a Reconciled event.
+func (e *Engine) Reconcile(ctx context.Context, b Broker, logged []Position, policy ReconcilePolicy) ReconcileReport {
+	rep := ReconcileReport{At: e.clock.Now(), Broker: b.Name(), Policy: policy.String()}
+	remotePos, err := b.Positions(ctx)
+	var remoteOrders []Order
+	var bals []Balance
+	if err == nil {
+		remoteOrders, err = b.OpenOrders(ctx, "")
+	}
+	if err == nil {
+		bals, err = b.Balances(ctx)
+	}
+	if err != nil {
+		rep.Err = err.Error()
+		e.finishReconcile(rep)
+		return rep
+	}
+	local := e.Export()
+
+	// positions
+	remote := signedPositions(remotePos)
+	state := map[posKey]float64{}
+	for _, p := range local.Positions {
+		state[posKey{p.Symbol, p.Leg}] = signedQty(p.Side, p.Qty)
+	}
+	adopt := false
+	for _, k := range unionKeys(remote, state) {
+		if !sameQty(remote[k], state[k]) {
+			d := Discrepancy{Kind: "position", Source: "state", Symbol: k.sym, Leg: k.leg.String(), Local: state[k], Remote: remote[k], Resolution: "pending"}
+			if policy == ReconcileAdopt {
+				d.Resolution, adopt = "adopted", true
+			}
+			rep.Items = append(rep.Items, d)
+		}
+	}
+	if logged != nil {
+		fromLog := signedPositions(logged)
+		for _, k := range unionKeys(remote, fromLog) {
+			if k.leg == LegNet && !sameQty(remote[k], fromLog[k]) {
+				res := "pending"
+				if policy == ReconcileAdopt {
+					res = "adopted"
+				}
+				rep.Items = append(rep.Items, Discrepancy{Kind: "position", Source: "log", Symbol: k.sym, Local: fromLog[k], Remote: remote[k], Resolution: res})
+			}
+		}
+	}
+
+	// orders the engine does not know about
+	known := map[string]bool{}
+	for _, o := range local.Orders {
+		known[o.ID] = true
+	}
+	for _, o := range remoteOrders {
+		if known[o.ID] {
+			continue
+		}
+		d := Discrepancy{Kind: "order", Source: "state", Symbol: o.Symbol, OrderID: o.ID, Remote: signedQty(o.Side, o.Qty), Resolution: "pending"}
+		switch policy {
+		case ReconcileAdopt:
+			d.Resolution = "adopted" // the exchange keeps managing it
+		case ReconcileCancel:
+			if err := b.CancelOrder(ctx, o.Symbol, o.ID); err == nil {
+				d.Resolution = "canceled"
+			}
+		}
+		rep.Items = append(rep.Items, d)
+	}
+
+	// quote balance
+	equity := 0.0
+	for _, bal := range bals {
+		if bal.Asset == "USDT" {
+			equity = bal.Free + bal.Locked
+		}
+	}
+	if math.Abs(equity-local.EquityUSD) > balanceTolerance*math.Max(math.Abs(equity), 1) {
+		d := Discrepancy{Kind: "balance", Source: "state", Local: local.EquityUSD, Remote: equity, Resolution: "pending"}
+		if policy == ReconcileAdopt {
+			d.Resolution, adopt = "adopted", true
+		}
+		rep.Items = append(rep.Items, d)
+	}
+
+	if adopt {
+		local.EquityUSD = equity
+		local.Positions = local.Positions[:0]
+		for _, p := range remotePos {
+			local.Positions = append(local.Positions, PositionState{Symbol: p.Symbol, Leg: p.Leg, Side: p.Side, Qty: p.Qty, Entry: p.Entry})
+		}
+		if err := e.Restore(local); err != nil {
+			rep.Err = "adopt: " + err.Error()
+		}
+	}
+	e.finishReconcile(rep)
+	return rep
+}
+
+func (e *Engine) finishReconcile(rep ReconcileReport) {
+	e.mu.Lock()
+	e.recon = &rep
+	e.mu.Unlock()
+	e.bus.Publish(Reconciled{TS: rep.At, Report: rep})
+}
+
+// Reconciliation returns the last reconciliation report, if any.
+func (e *Engine) Reconciliation() (ReconcileReport, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.recon == nil {
+		return ReconcileReport{}, false
+	}
+	return *e.recon, true
+}
+
+// AckReconciliation accepts the remaining discrepancies and releases the
+// trading hold.
+func (e *Engine) AckReconciliation() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.recon == nil || !e.recon.Pending() {
+		return errors.New("nothing to acknowledge")
+	}
+	e.recon.Acknowledged = true
+	return nil
+}
+
+var errReconHold = errors.New("trading on hold: reconciliation not acknowledged")
+
+// reconHold reports whether an unresolved reconciliation holds trading.
+func (e *Engine) reconHold() bool { return e.recon != nil && e.recon.Pending() }
+
+// PositionsFromLog replays trade log rows into the net positions they leave
+// open. The log carries no hedge legs, so the result is one-way.
+func PositionsFromLog(rows []TradeLogEntry) []Position {
+	open := map[string]*Position{}
+	for _, r := range rows {
+		side := Buy
+		if r.Side == "SHORT" {
+			side = Sell
+		}
+		p := open[r.Symbol]
+		switch strings.ToUpper(r.Event) {
+		case "OPEN":
+			open[r.Symbol] = &Position{Symbol: r.Symbol, Side: side, Qty: r.Qty, Entry: r.Price}
+		case "ADD":
+			if p != nil {
+				p.Qty += r.Qty
+			}
+		case "REDUCE":
+			if p != nil {
+				p.Qty -= r.Qty
+			}
+		case "CLOSE", "SL", "TP", EvLiquidation:
+			delete(open, r.Symbol)
+		}
+		if p := open[r.Symbol]; p != nil && p.Qty <= qtyEps {
+			delete(open, r.Symbol)
+		}
+	}
+	out := make([]Position, 0, len(open))
+	for _, p := range open {
+		out = append(out, *p)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Symbol < out[j].Symbol })
+	return out
+}
+
+func signedQty(side Action, qty float64) float64 {
+	if side == Sell {
+		return -qty
+	}
+	return qty
+}
+
+func signedPositions(ps []Position) map[posKey]float64 {
+	out := map[posKey]float64{}
+	for _, p := range ps {
+		out[posKey{p.Symbol, p.Leg}] += signedQty(p.Side, p.Qty)
+	}
+	return out
+}
+
+func unionKeys(a, b map[posKey]float64) []posKey {
+	seen := map[posKey]bool{}
+	var out []posKey
+	for _, m := range []map[posKey]float64{a, b} {
+		for k := range m {
+			if !seen[k] {
+				seen[k] = true
+				out = append(out, k)
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].sym != out[j].sym {
+			return out[i].sym < out[j].sym
+		}
+		return out[i].leg < out[j].leg
+	})
+	return out
+}
+
+// sameQty compares quantities up to exchange rounding (8 decimals).
+func sameQty(a, b float64) bool { return math.Abs(a-b) < 1e-8 }
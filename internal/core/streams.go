@@ -0,0 +1,221 @@
+package core
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Stream is one candle series: a symbol on a timeframe.
+type Stream struct {
+	Symbol string
+	TF     string
+}
+
+func (s Stream) String() string { return s.Symbol + "@" + s.TF }
+
+// MultiTimeframe is implemented by strategies that read more than the stream
+// they trade, e.g. 1m entries filtered by the 1h trend. Streams gets the
+// traded stream and returns the extra ones; OnCandle is then called for every
+// stream in close-time order, with tf telling them apart. A bar is delivered
+// only once it has closed, and coarser bars come before the traded bar that
+// closes at the same time.
+type MultiTimeframe interface {
+	Streams(sym, tf string) []Stream
+}
+
+// PlanStreams splits the extra streams s reads beside (sym, tf) into those
+// StreamMux resamples from the traded stream (same symbol, a coarser multiple
+// of tf) and those that need a feed of their own.
+func PlanStreams(s Strategy, sym, tf string) (resampled, fed []Stream) {
+	mt, ok := s.(MultiTimeframe)
+	if !ok {
+		return nil, nil
+	}
+	base := TFDuration(tf)
+	seen := map[Stream]bool{{sym, tf}: true}
+	for _, st := range mt.Streams(sym, tf) {
+		if st.Symbol == "" {
+			st.Symbol = sym
+		}
+		if seen[st] {
+			continue
+		}
+		seen[st] = true
+		if st.Symbol == sym && divides(base, TFDuration(st.TF)) {
+			resampled = append(resampled, st)
+		} else {
+			fed = append(fed, st)
+		}
+	}
+	return resampled, fed
+}
+
+func divides(base, d time.Duration) bool { return base > 0 && d > base && d%base == 0 }
+
+// TFDuration parses a Binance-style interval (1m, 15m, 4h, 1d, 1w); 0 if
+// unknown. Months are not fixed-length and are unsupported.
+func TFDuration(tf string) time.Duration {
+	if len(tf) < 2 {
+		return 0
+	}
+	n, err := strconv.Atoi(tf[:len(tf)-1])
+	if err != nil || n <= 0 {
+		return 0
+	}
+	unit := map[byte]time.Duration{'s': time.Second, 'm': time.Minute, 'h': time.Hour, 'd': 24 * time.Hour, 'w': 7 * 24 * time.Hour}[tf[len(tf)-1]]
+	return time.Duration(n) * unit
+}
+
+// CloseTime is when kl's bar closes, or kl.Ts if its timeframe is unknown.
+func CloseTime(kl Kline) time.Time { return kl.Ts.Add(TFDuration(kl.TF)) }
+
+// Resampler builds bars of a coarser timeframe from a finer stream. Buckets
+// are aligned to UTC (weeks start on Monday, as on Binance).
+type Resampler struct {
+	stream Stream
+	dur    time.Duration
+	start  time.Time // current bucket
+	whole  bool      // the bucket's first candle was seen
+	done   bool      // the bucket's bar was emitted
+	agg    Kline     // candles before cur
+	cur    Kline     // latest candle; a polling feed repeats it while it forms
+}
+
+func NewResampler(sym, tf string) *Resampler {
+	return &Resampler{stream: Stream{sym, tf}, dur: TFDuration(tf)}
+}
+
+// Add folds a candle of the finer stream into the current bucket and returns
+// the bucket's bar once its last candle is final: at once for a closed
+// candle, or when the next bucket starts if a polling feed only showed it
+// Forming. A bucket whose start was missed is never emitted, so a partial
+// first bar cannot mislead a strategy. A candle with the same Ts as the
+// previous one replaces it.
+func (r *Resampler) Add(kl Kline) (Kline, bool) {
+	if r.dur <= 0 {
+		return Kline{}, false
+	}
+	var prev Kline
+	flush := false
+	start := kl.Ts.UTC().Truncate(r.dur)
+	if !start.Equal(r.start) {
+		if flush = r.whole && !r.done && !r.cur.Ts.IsZero() && r.complete(r.cur); flush {
+			prev = r.bar()
+		}
+		r.start, r.whole, r.done = start, kl.Ts.Equal(start), false
+		r.agg, r.cur = Kline{}, Kline{}
+	}
+	if !r.cur.Ts.IsZero() && !kl.Ts.Equal(r.cur.Ts) {
+		r.agg = mergeBars(r.agg, r.cur)
+	}
+	r.cur = kl
+	if flush {
+		return prev, true
+	}
+	if !r.whole || r.done || kl.Forming || !r.complete(kl) {
+		return Kline{}, false
+	}
+	r.done = true
+	return r.bar(), true
+}
+
+// complete reports whether kl is the last candle of the current bucket.
+func (r *Resampler) complete(kl Kline) bool {
+	return !CloseTime(kl).Before(r.start.Add(r.dur))
+}
+
+func (r *Resampler) bar() Kline {
+	bar := mergeBars(r.agg, r.cur)
+	bar.Symbol, bar.TF, bar.Ts, bar.Forming = r.stream.Symbol, r.stream.TF, r.start, false
+	return bar
+}
+
+func mergeBars(a, b Kline) Kline {
+	if a.Ts.IsZero() {
+		return b
+	}
+	a.High = maxf(a.High, b.High)
+	a.Low = minf(a.Low, b.Low)
+	a.Close = b.Close
+	a.Vol += b.Vol
+	return a
+}
+
+// StreamMux turns the candles of a traded stream, plus those of separately
+// fed streams, into the sequence a MultiTimeframe strategy expects: bars
+// resampled from the traded stream are inserted as their buckets close. The
+// strategy is asked for its streams on every candle, so a strategy swap takes
+// effect at once (fed streams need their feeds restarted). Safe for
+// concurrent use by several feeds.
+type StreamMux struct {
+	mu       sync.Mutex
+	traded   Stream
+	strategy func() Strategy
+	rs       map[Stream]*Resampler
+}
+
+func NewStreamMux(sym, tf string, strategy func() Strategy) *StreamMux {
+	return &StreamMux{traded: Stream{sym, tf}, strategy: strategy, rs: map[Stream]*Resampler{}}
+}
+
+// Add returns the bars kl completes, coarsest first, followed by kl itself.
+func (m *StreamMux) Add(kl Kline) []Kline {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if (Stream{kl.Symbol, kl.TF}) != m.traded {
+		return []Kline{kl}
+	}
+	resampled, _ := PlanStreams(m.strategy(), m.traded.Symbol, m.traded.TF)
+	var out []Kline
+	for _, st := range resampled {
+		r := m.rs[st]
+		if r == nil {
+			r = NewResampler(st.Symbol, st.TF)
+			m.rs[st] = r
+		}
+		if bar, ok := r.Add(kl); ok {
+			out = append(out, bar)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return TFDuration(out[i].TF) > TFDuration(out[j].TF) })
+	return append(out, kl)
+}
+
+// MergeStreams interleaves candle series in close-time order without
+// lookahead. On equal close times earlier series go first, so callers pass
+// the traded stream last.
+func MergeStreams(series ...[]Kline) []Kline {
+	var out []Kline
+	for _, s := range series {
+		out = append(out, s...)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return CloseTime(out[i]).Before(CloseTime(out[j])) })
+	return out
+}
+
+// cover is the span of a symbol's price action the engine has traded
+// through, and the timeframe that covered it.
+type cover struct {
+	end time.Time
+	tf  time.Duration
+}
+
+// fresh reports whether kl carries price action the engine has not traded
+// through yet, and records it. A coarser bar that closes within what finer
+// bars of sym already covered (a higher timeframe of a multi-timeframe
+// strategy) only informs the strategy: its range must not re-trigger exits,
+// fills or funding. A polling feed's repeated candle stays fresh.
+func (e *Engine) fresh(sym, tf string, kl Kline) bool {
+	d := TFDuration(tf)
+	c := e.traded[sym]
+	end := kl.Ts.Add(d)
+	if d > c.tf && !end.After(c.end) {
+		return false
+	}
+	if end.After(c.end) || d < c.tf {
+		e.traded[sym] = cover{end: end, tf: d}
+	}
+	return true
+}
@@ -0,0 +1,99 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+// q is 15-minute candle i of X.
+func q(i int, c float64, forming bool) Kline {
+	return Kline{Symbol: "X", TF: "15m", Ts: t0.Add(time.Duration(i) * 15 * time.Minute), Open: c, High: c + 1, Low: c - 1, Close: c, Vol: 1, Forming: forming}
+}
+
+func TestResampler(t *testing.T) {
+	type step struct {
+		kl    Kline
+		emit  bool
+		close float64 // of the emitted bar
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"closed candles emit on the last one", []step{
+			{q(0, 10, false), false, 0},
+			{q(1, 11, false), false, 0},
+			{q(2, 12, false), false, 0},
+			{q(3, 13, false), true, 13},
+			{q(4, 14, false), false, 0},
+		}},
+		{"a forming last candle waits for its final version", []step{
+			{q(0, 10, false), false, 0},
+			{q(1, 11, false), false, 0},
+			{q(2, 12, false), false, 0},
+			{q(3, 13, true), false, 0},
+			{q(3, 15, true), false, 0},
+			{q(3, 14, false), true, 14},
+			{q(3, 14, false), false, 0},
+		}},
+		{"a last candle seen only forming is final once the next bucket starts", []step{
+			{q(0, 10, false), false, 0},
+			{q(1, 11, false), false, 0},
+			{q(2, 12, false), false, 0},
+			{q(3, 13, true), false, 0},
+			{q(4, 20, true), true, 13},
+		}},
+		{"a bucket joined late is skipped", []step{
+			{q(1, 11, false), false, 0},
+			{q(2, 12, false), false, 0},
+			{q(3, 13, true), false, 0},
+			{q(4, 20, false), false, 0},
+		}},
+		{"a bucket whose last candle was never seen is skipped", []step{
+			{q(0, 10, false), false, 0},
+			{q(1, 11, true), false, 0},
+			{q(4, 20, false), false, 0},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewResampler("X", "1h")
+			for i, s := range tt.steps {
+				bar, ok := r.Add(s.kl)
+				if ok != s.emit {
+					t.Fatalf("step %d: emitted %v, want %v", i, ok, s.emit)
+				}
+				if !ok {
+					continue
+				}
+				if bar.Close != s.close || bar.Open != 10 || !bar.Ts.Equal(t0) || bar.TF != "1h" || bar.Forming {
+					t.Fatalf("step %d: bar %+v, want 1h from t0 open 10 close %v", i, bar, s.close)
+				}
+			}
+		})
+	}
+}
+
+func TestStreamMuxEmitsCoarseBarBeforeTheCandleClosingIt(t *testing.T) {
+	m := NewStreamMux("X", "15m", func() Strategy { return &mtf{} })
+	var got []string
+	for i := 0; i < 8; i++ {
+		for _, kl := range m.Add(q(i, float64(10+i), false)) {
+			got = append(got, kl.TF)
+		}
+	}
+	want := []string{"15m", "15m", "15m", "1h", "15m", "15m", "15m", "15m", "1h", "15m"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+}
+
+// mtf is a strategy that reads X 1h besides the traded stream.
+type mtf struct{ scripted }
+
+func (*mtf) Streams(sym, tf string) []Stream { return []Stream{{sym, "1h"}} }
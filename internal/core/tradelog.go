@@ -1,6 +1,9 @@
 package core
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 type TradeLogEntry struct {
 	TS      time.Time
@@ -19,3 +22,53 @@ type TradeLogger interface {
 	Append(TradeLogEntry) error
 	LastN(n int) ([]TradeLogEntry, error)
 }
+
+// IsExitEvent reports whether a trade log event closes (part of) a position
+// and realizes PnL.
+func IsExitEvent(event string) bool {
+	switch strings.ToUpper(event) {
+	case "CLOSE", "REDUCE", "SL", "TP", EvLiquidation:
+		return true
+	}
+	return false
+}
+
+// LogTrades returns a bus subscriber that appends position ledger rows to tl,
+// and a DEAL row summing up each completed deal after its last fill. A DEAL
+// row is not an exit, so it does not count twice in exit-based totals.
+func LogTrades(tl TradeLogger) func(Event) {
+	return func(ev Event) {
+		if dc, ok := ev.(DealClosed); ok {
+			d := dc.Deal
+			_ = tl.Append(TradeLogEntry{
+				TS:      dc.TS,
+				Symbol:  d.Symbol,
+				TF:      d.TF,
+				Event:   EvDeal,
+				Side:    map[Action]string{Buy: "LONG", Sell: "SHORT"}[d.Side],
+				Qty:     d.EntryQty,
+				Price:   d.AvgEntry,
+				PnL:     d.PnL,
+				Fee:     d.Fees,
+				Comment: d.String(),
+			})
+			return
+		}
+		pc, ok := ev.(PositionChanged)
+		if !ok {
+			return
+		}
+		_ = tl.Append(TradeLogEntry{
+			TS:      pc.TS,
+			Symbol:  pc.Symbol,
+			TF:      pc.TF,
+			Event:   pc.Event,
+			Side:    map[Action]string{Buy: "LONG", Sell: "SHORT", None: "FLAT"}[pc.Side],
+			Qty:     pc.Qty,
+			Price:   pc.Price,
+			PnL:     pc.PnL,
+			Fee:     pc.Fee,
+			Comment: pc.Comment,
+		})
+	}
+}
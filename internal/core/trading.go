@@ -0,0 +1,98 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TradingState gates what the engine may execute. Protective exits (SL, TP,
+// liquidation) run in every state.
+type TradingState int
+
+const (
+	TradingRunning   TradingState = iota // signals and orders execute normally
+	TradingPaused                        // signals are dropped, resting orders wait
+	TradingCloseOnly                     // only executions that reduce a position
+	TradingHalted                        // flattened: positions closed, orders canceled, nothing trades
+)
+
+func (s TradingState) String() string {
+	return [...]string{"running", "paused", "close_only", "halted"}[s]
+}
+
+// ParseTradingState maps running | paused | close_only | halted (or flatten)
+// to a state.
+func ParseTradingState(s string) (TradingState, error) {
+	switch strings.ReplaceAll(strings.ToLower(strings.TrimSpace(s)), "-", "_") {
+	case "running", "run", "start", "":
+		return TradingRunning, nil
+	case "paused", "pause", "stop":
+		return TradingPaused, nil
+	case "close_only", "closeonly":
+		return TradingCloseOnly, nil
+	case "halted", "halt", "flatten", "flatten_and_halt":
+		return TradingHalted, nil
+	}
+	return TradingRunning, fmt.Errorf("unknown trading state %q", s)
+}
+
+func (e *Engine) TradingState() TradingState {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.trading
+}
+
+// SetTradingState switches the trading state. Entering TradingHalted flattens the
+// account first: every resting order is canceled and every position closed at
+// the last price (paper) or at market (live).
+func (e *Engine) SetTradingState(s TradingState) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	from := e.trading
+	ts := e.clock.Now()
+	if s == TradingHalted {
+		e.cancelAll(ts, "", "")
+		for _, k := range e.book.keys() {
+			p := *e.book.get(k)
+			px := e.lastPx[k.sym]
+			if px <= 0 {
+				px = p.entry
+			}
+			qty, px, ok := e.execute(ts, k, "", p.side.opposite(), p.qty, px, true)
+			if !ok {
+				return fmt.Errorf("flatten %s: close failed", k.sym)
+			}
+			pnl := e.reduce(k, qty, px)
+			e.notify(ts, "FLATTEN %s%s @ %.2f | PnL: %.2f USD", k.sym, legSuffix(k.leg.String()), px, pnl)
+			e.logTrade(TradeEvent{TS: ts, Symbol: k.sym, Event: "CLOSE", Side: p.side, Leg: k.leg, Qty: qty, Price: px, PnL: pnl, Comment: "flatten"})
+		}
+	}
+	e.trading = s
+	if from != s {
+		e.notify(ts, "trading %s -> %s", from, s)
+		e.bus.Publish(TradingStateChanged{TS: ts, From: from, To: s})
+	}
+	return nil
+}
+
+// tradingGate returns why sig may not execute in the current state, or "".
+func (e *Engine) tradingGate(sym string, sig Signal) string {
+	switch e.trading {
+	case TradingPaused, TradingHalted:
+		return "trading " + e.trading.String()
+	case TradingCloseOnly:
+		if sig.Action == Close {
+			return ""
+		}
+		if sig.Type != Market || !e.reduces(posKey{sym, e.legFor(sig.Leg, sig.Action)}, sig.Action) {
+			return "trading close_only: signal would open a position"
+		}
+	}
+	return ""
+}
+
+// reduces reports whether a side executed against k would shrink a position.
+func (e *Engine) reduces(k posKey, side Action) bool {
+	p := e.book.get(k)
+	return p != nil && p.side != side
+}
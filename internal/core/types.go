@@ -11,6 +11,14 @@ const (
 	Close
 )
 
+// opposite returns the side that reduces a position held on a.
+func (a Action) opposite() Action {
+	if a == Buy {
+		return Sell
+	}
+	return Buy
+}
+
 type Kline struct {
 	Symbol string
 	TF     string
@@ -20,18 +28,58 @@ type Kline struct {
 	Close  float64
 	Vol    float64
 	Ts     time.Time
+	// Forming marks a polling feed's candle that has not closed yet; the feed
+	// repeats it with the same Ts as it updates.
+	Forming bool
 }
 
 type AccountState struct {
-	EquityUSD float64
-	Position  Position
+	EquityUSD  float64    // realized balance
+	Unrealized float64    // unrealized PnL over all open positions
+	NetEquity  float64    // EquityUSD + Unrealized
+	Position   Position   // position in the symbol being processed (hedge mode: its first open leg)
+	Positions  []Position // all open positions, sorted by symbol and leg
+
+	// Margin mode only.
+	MarginUsed  float64 // initial margin of all positions
+	MaintMargin float64 // maintenance margin of all positions
+	MarginRatio float64 // MaintMargin / NetEquity; liquidation at 1
+}
+
+// PositionOf returns the first open position in sym, or a flat one.
+func (a AccountState) PositionOf(sym string) Position {
+	for _, p := range a.Positions {
+		if p.Symbol == sym {
+			return p
+		}
+	}
+	return Position{Symbol: sym}
+}
+
+// Leg returns the hedge-mode leg of sym, or a flat one.
+func (a AccountState) Leg(sym string, leg Leg) Position {
+	for _, p := range a.Positions {
+		if p.Symbol == sym && p.Leg == leg {
+			return p
+		}
+	}
+	return Position{Symbol: sym, Leg: leg}
 }
 
 type Position struct {
+	Symbol string
+	Leg    Leg    // LegNet in one-way mode
 	Side   Action // Buy=long, Sell=short, None
 	Qty    float64
 	Entry  float64
 	Unreal float64
+	SL     float64 // protective stop, 0 = none
+	TP     float64 // protective target, 0 = none
+
+	// Margin mode only.
+	Margin      float64 // initial margin
+	MaintMargin float64
+	LiqPrice    float64 // 0 = none
 }
 
 type Signal struct {
@@ -40,6 +88,21 @@ type Signal struct {
 	SL      *float64
 	TP      *float64
 	Comment string
+	Leg     Leg // hedge mode: leg to act on (unset: Buy→long, Sell→short; Close→both)
+
+	// Non-market types rest in the engine's order book until a later candle
+	// crosses Price/StopPrice.
+	Type      OrderType
+	Price     float64
+	StopPrice float64
+	TIF       TimeInForce
+	ExpireAt  time.Time // GTD only
+	Cancel    bool      // cancel the symbol's pending orders before acting
+
+	// Orders rest in the book alongside the signal's own action, after
+	// Cancel, e.g. the levels of a grid. Each is checked as by
+	// Engine.PlaceOrder; Symbol defaults to the candle's.
+	Orders []Order
 }
 
 type Strategy interface {
@@ -0,0 +1,111 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// HistorySource serves closed candles from before a point in time.
+type HistorySource interface {
+	// History returns up to n closed sym/tf candles that close at or before
+	// before, oldest first.
+	History(ctx context.Context, sym, tf string, n int, before time.Time) ([]Kline, error)
+}
+
+// warmupTimeout bounds the history fetch.
+const warmupTimeout = 15 * time.Second
+
+// SetWarmup declares the stream the bot trades and where its history comes
+// from. On the first candle of a new stream, and after every AttachStrategy,
+// the engine fetches Warmup() bars ending before the candle and replays them
+// into the strategy without trading, so it is not blind for its first bars.
+// Declaring the stream already warmed again, e.g. when the feed restarts,
+// only swaps the source. A nil src disables warmup.
+func (e *Engine) SetWarmup(sym, tf string, src HistorySource) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	st := Stream{sym, tf}
+	if st != e.warmStream || e.history == nil {
+		e.needWarm = true
+	}
+	e.warmStream, e.history = st, src
+	e.needWarm = e.needWarm && src != nil
+	e.warmGen++
+}
+
+// warmup is the history fetched for one strategy and stream.
+type warmup struct {
+	gen    int // Engine.warmGen it was fetched for
+	n      int
+	bars   []Kline   // the warmed stream
+	series [][]Kline // the streams fed besides it
+	errs   []error
+}
+
+// lockWarm takes the engine lock for a candle of sym/tf and returns the
+// history to replay first, if any. The fetch runs outside the lock so a slow
+// source does not hold up the rest of the engine; a strategy or stream
+// swapped in meanwhile gets its own fetch before the candle.
+func (e *Engine) lockWarm(sym, tf string, kl Kline) *warmup {
+	e.mu.Lock()
+	var w *warmup
+	for e.needWarm && (Stream{sym, tf}) == e.warmStream && e.strat != nil && (w == nil || w.gen != e.warmGen) {
+		strat, src, gen := e.strat, e.history, e.warmGen
+		e.mu.Unlock()
+		w = fetchWarmup(strat, src, sym, tf, kl.Ts)
+		w.gen = gen
+		e.mu.Lock()
+	}
+	return w
+}
+
+// fetchWarmup fetches the history strat needs before the sym/tf bar at ts.
+func fetchWarmup(strat Strategy, src HistorySource, sym, tf string, ts time.Time) *warmup {
+	w := &warmup{n: strat.Warmup()}
+	if w.n <= 0 {
+		return w
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), warmupTimeout)
+	defer cancel()
+	bars, err := src.History(ctx, sym, tf, w.n, ts)
+	if err != nil {
+		w.errs = append(w.errs, fmt.Errorf("%s: %w", Stream{sym, tf}, err))
+		return w
+	}
+	w.bars = bars
+	_, fed := PlanStreams(strat, sym, tf)
+	for _, st := range fed {
+		extra, err := src.History(ctx, st.Symbol, st.TF, w.n, ts)
+		if err != nil {
+			w.errs = append(w.errs, fmt.Errorf("%s: %w", st, err))
+			continue
+		}
+		w.series = append(w.series, extra)
+	}
+	return w
+}
+
+// warmUp replays w into the strategy. Signals are discarded; the strategy's
+// streams are resampled and merged as they are live.
+func (e *Engine) warmUp(kl Kline, w *warmup) {
+	if !e.needWarm || w.gen != e.warmGen {
+		return
+	}
+	e.needWarm = false
+	for _, err := range w.errs {
+		e.notify(kl.Ts, "warmup %v", err)
+	}
+	if w.bars == nil {
+		return
+	}
+	sym, tf := e.warmStream.Symbol, e.warmStream.TF
+	strat := e.strat
+	mux := NewStreamMux(sym, tf, func() Strategy { return strat })
+	for _, c := range MergeStreams(append(w.series, w.bars)...) {
+		for _, bar := range mux.Add(c) {
+			_, _ = strat.OnCandle(bar.Symbol, bar.TF, bar, e.snapshot(bar.Symbol))
+		}
+	}
+	e.notify(e.clock.Now(), "warmup %s: %d of %d bars replayed into %s", e.warmStream, len(w.bars), w.n, strat.Name())
+}
@@ -0,0 +1,73 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// warming needs n bars of history and counts the candles it sees.
+type warming struct {
+	scripted
+	warm int
+}
+
+func (s *warming) Warmup() int { return s.warm }
+
+// history serves flat hourly X bars and counts its fetches. With eng set, it
+// fails the test if a fetch holds the engine lock.
+type history struct {
+	t       *testing.T
+	eng     *Engine
+	fetches int
+}
+
+func (h *history) History(_ context.Context, sym, tf string, n int, before time.Time) ([]Kline, error) {
+	h.fetches++
+	if h.eng != nil {
+		done := make(chan struct{})
+		go func() { h.eng.EquityUSD(); close(done) }()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			h.t.Error("history fetched under the engine lock")
+		}
+	}
+	out := make([]Kline, n)
+	for i := range out {
+		out[i] = Kline{Symbol: sym, TF: tf, Ts: before.Add(-time.Duration(n-i) * time.Hour), Open: 100, High: 100, Low: 100, Close: 100}
+	}
+	return out, nil
+}
+
+func TestWarmupOnlyForNewStrategyOrStream(t *testing.T) {
+	s := &warming{warm: 3}
+	e, _ := newTestEngine(t, EngineOpts{}, s)
+	h := &history{t: t, eng: e}
+	e.SetWarmup("X", "1h", h)
+
+	feed(t, e, bar(0, 100, 100, 100, 100))
+	if h.fetches != 1 || s.n != 4 {
+		t.Fatalf("first candle: %d fetches, strategy saw %d bars; want 1 and 3 replayed + 1", h.fetches, s.n)
+	}
+
+	// a feed restart declares the same stream again
+	e.SetWarmup("X", "1h", h)
+	feed(t, e, bar(1, 100, 100, 100, 100))
+	if h.fetches != 1 || s.n != 5 {
+		t.Fatalf("same stream: %d fetches, strategy saw %d bars; want no replay", h.fetches, s.n)
+	}
+
+	e.SetWarmup("X", "2h", h)
+	feed(t, e, Kline{Symbol: "X", TF: "2h", Ts: t0.Add(2 * time.Hour), Open: 100, High: 100, Low: 100, Close: 100})
+	if h.fetches != 2 || s.n != 9 {
+		t.Fatalf("new stream: %d fetches, strategy saw %d bars; want a second replay", h.fetches, s.n)
+	}
+
+	next := &warming{warm: 2}
+	e.AttachStrategy(next)
+	feed(t, e, Kline{Symbol: "X", TF: "2h", Ts: t0.Add(4 * time.Hour), Open: 100, High: 100, Low: 100, Close: 100})
+	if h.fetches != 3 || next.n != 3 {
+		t.Fatalf("new strategy: %d fetches, strategy saw %d bars; want 2 replayed + 1", h.fetches, next.n)
+	}
+}
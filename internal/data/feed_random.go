@@ -12,17 +12,18 @@ type RandomFeed struct {
 	Symbol  string
 	TF      string
 	start   time.Time
+	open    float64 // starting price, also where History ends
 	price   float64
 	vol     float64
 	Candles chan core.Kline
 }
 
 func NewRandomFeed(symbol, tf string, start time.Time, startPrice float64, vol float64) *RandomFeed {
-	return &RandomFeed{Symbol: symbol, TF: tf, start: start, price: startPrice, vol: vol, Candles: make(chan core.Kline, 1000)}
+	return &RandomFeed{Symbol: symbol, TF: tf, start: start, open: startPrice, price: startPrice, vol: vol, Candles: make(chan core.Kline, 1000)}
 }
 
 func (f *RandomFeed) Start(ctx context.Context) {
-	step := tfDur(f.TF)
+	step := core.TFDuration(f.TF)
 	if step <= 0 {
 		step = time.Minute
 	}
@@ -51,18 +52,6 @@ func (f *RandomFeed) Start(ctx context.Context) {
 	}()
 }
 
-func tfDur(tf string) time.Duration {
-	switch tf {
-	case "1m":
-		return time.Minute
-	case "5m":
-		return 5 * time.Minute
-	case "15m":
-		return 15 * time.Minute
-	}
-	return time.Minute
-}
-
 func maxf(a, b float64) float64 {
 	if a < b {
 		return b
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"tradebot/internal/core"
@@ -15,6 +16,8 @@ type RestFeed struct {
 	Symbol   string
 	TF       string
 	Interval time.Duration
+	BaseURL  string // "" = Binance production
+	Futures  bool
 	Candles  chan core.Kline
 	client   *http.Client
 }
@@ -58,7 +61,17 @@ func (f *RestFeed) Start(ctx context.Context) {
 
 func (f *RestFeed) fetchLast() (core.Kline, error) {
 	interval := tfToBinance(f.TF)
-	url := fmt.Sprintf("https://api.binance.com/api/v3/klines?symbol=%s&interval=%s&limit=1", f.Symbol, interval)
+	base, path := f.BaseURL, "/api/v3/klines"
+	if f.Futures {
+		path = "/fapi/v1/klines"
+	}
+	if base == "" {
+		base = "https://api.binance.com"
+		if f.Futures {
+			base = DefaultFuturesURL
+		}
+	}
+	url := fmt.Sprintf("%s%s?symbol=%s&interval=%s&limit=1", strings.TrimRight(base, "/"), path, f.Symbol, interval)
 	resp, err := f.client.Get(url)
 	if err != nil {
 		return core.Kline{}, err
@@ -81,6 +94,7 @@ func (f *RestFeed) fetchLast() (core.Kline, error) {
 	low := toF64(row[3])
 	close := toF64(row[4])
 	vol := toF64(row[5])
+	closeTime := toInt64(row[6])
 	return core.Kline{
 		Symbol: f.Symbol,
 		TF:     f.TF,
@@ -90,6 +104,8 @@ func (f *RestFeed) fetchLast() (core.Kline, error) {
 		Close:  close,
 		Vol:    vol,
 		Ts:     time.UnixMilli(openTime),
+		// the last kline is the one in progress until its close time passes
+		Forming: time.Now().Before(time.UnixMilli(closeTime)),
 	}, nil
 }
 
@@ -101,8 +117,8 @@ func tfToBinance(tf string) string {
 		return "5m"
 	case "15m":
 		return "15m"
-	case "1h":
-		return "1h"
+	case "30m", "1h", "4h", "1d":
+		return tf
 	default:
 		return "1m"
 	}
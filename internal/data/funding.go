@@ -0,0 +1,153 @@
+package data
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultFuturesURL is the Binance UM futures REST base URL.
+const DefaultFuturesURL = "https://fapi.binance.com"
+
+type FundingRate struct {
+	Ts        time.Time
+	Rate      float64
+	MarkPrice float64
+}
+
+type fundingRow struct {
+	Symbol      string `json:"symbol"`
+	FundingTime int64  `json:"fundingTime"`
+	FundingRate string `json:"fundingRate"`
+	MarkPrice   string `json:"markPrice"`
+}
+
+// FetchFundingRates pulls funding history from baseURL (Binance UM futures or
+// a local server speaking the same /fapi/v1/fundingRate API).
+func FetchFundingRates(baseURL, symbol string, from, to time.Time) ([]FundingRate, error) {
+	if baseURL == "" {
+		baseURL = DefaultFuturesURL
+	}
+	baseURL = strings.TrimRight(baseURL, "/")
+	client := &http.Client{Timeout: 10 * time.Second}
+	out := make([]FundingRate, 0, 256)
+	start := from
+	for start.Before(to) {
+		url := fmt.Sprintf("%s/fapi/v1/fundingRate?symbol=%s&startTime=%d&endTime=%d&limit=1000", baseURL, symbol, start.UnixMilli(), to.UnixMilli())
+		resp, err := client.Get(url)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("funding status %d", resp.StatusCode)
+		}
+		var rows []fundingRow
+		if err := json.NewDecoder(resp.Body).Decode(&rows); err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		resp.Body.Close()
+		if len(rows) == 0 {
+			break
+		}
+		for _, r := range rows {
+			out = append(out, FundingRate{Ts: time.UnixMilli(r.FundingTime), Rate: toF64(r.FundingRate), MarkPrice: toF64(r.MarkPrice)})
+		}
+		start = time.UnixMilli(rows[len(rows)-1].FundingTime).Add(time.Millisecond)
+	}
+	return out, nil
+}
+
+// LoadFundingFile reads funding history for offline runs: either the JSON
+// array returned by /fapi/v1/fundingRate or a CSV of "ts,rate" where ts is
+// unix millis or RFC3339.
+func LoadFundingFile(path string) ([]FundingRate, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rows []fundingRow
+	if err := json.Unmarshal(b, &rows); err == nil {
+		out := make([]FundingRate, 0, len(rows))
+		for _, r := range rows {
+			out = append(out, FundingRate{Ts: time.UnixMilli(r.FundingTime), Rate: toF64(r.FundingRate), MarkPrice: toF64(r.MarkPrice)})
+		}
+		return out, nil
+	}
+	recs, err := csv.NewReader(strings.NewReader(string(b))).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]FundingRate, 0, len(recs))
+	for _, rec := range recs {
+		if len(rec) < 2 {
+			continue
+		}
+		ts, err := time.Parse(time.RFC3339, rec[0])
+		if err != nil {
+			ms := toInt64(rec[0])
+			if ms == 0 {
+				continue // header
+			}
+			ts = time.UnixMilli(ms)
+		}
+		out = append(out, FundingRate{Ts: ts, Rate: toF64(rec[1])})
+	}
+	return out, nil
+}
+
+// FundingHistory serves funding rates from memory, optionally refilling from
+// a REST base URL on misses. It implements core.FundingSource.
+type FundingHistory struct {
+	baseURL string
+	mu      sync.Mutex
+	rates   map[string][]FundingRate // sorted by Ts
+}
+
+// NewFundingHistory returns a source; baseURL == "" disables remote lookups.
+func NewFundingHistory(baseURL string) *FundingHistory {
+	return &FundingHistory{baseURL: baseURL, rates: map[string][]FundingRate{}}
+}
+
+func (h *FundingHistory) Add(symbol string, rates []FundingRate) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	all := append(h.rates[symbol], rates...)
+	sort.Slice(all, func(i, j int) bool { return all[i].Ts.Before(all[j].Ts) })
+	h.rates[symbol] = all
+}
+
+// FundingRate returns the rate settled at the funding time at (within a
+// minute of tolerance).
+func (h *FundingHistory) FundingRate(symbol string, at time.Time) (float64, bool) {
+	if r, ok := h.lookup(symbol, at); ok {
+		return r, true
+	}
+	if h.baseURL == "" {
+		return 0, false
+	}
+	rates, err := FetchFundingRates(h.baseURL, symbol, at.Add(-time.Minute), at.Add(time.Minute))
+	if err != nil || len(rates) == 0 {
+		return 0, false
+	}
+	h.Add(symbol, rates)
+	return h.lookup(symbol, at)
+}
+
+func (h *FundingHistory) lookup(symbol string, at time.Time) (float64, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	rates := h.rates[symbol]
+	i := sort.Search(len(rates), func(i int) bool { return !rates[i].Ts.Before(at.Add(-time.Minute)) })
+	if i < len(rates) && rates[i].Ts.Sub(at) <= time.Minute {
+		return rates[i].Rate, true
+	}
+	return 0, false
+}
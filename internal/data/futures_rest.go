@@ -16,7 +16,7 @@ type Kline struct {
 
 // FetchHistoryFutures fetches Binance UM futures klines via REST.
 func FetchHistoryFutures(symbol, interval string, from, to time.Time) ([]Kline, error) {
-	iv := map[string]string{"1m": "1m", "5m": "5m", "15m": "15m", "1h": "1h"}[interval]
+	iv := map[string]string{"1m": "1m", "5m": "5m", "15m": "15m", "30m": "30m", "1h": "1h", "4h": "4h", "1d": "1d"}[interval]
 	if iv == "" {
 		iv = "1m"
 	}
@@ -0,0 +1,213 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"tradebot/internal/core"
+)
+
+var (
+	_ core.HistorySource = (*RestHistory)(nil)
+	_ core.HistorySource = (*CandleCache)(nil)
+	_ core.HistorySource = (*RandomFeed)(nil)
+)
+
+// RestHistory serves recent klines from the Binance REST API, spot or UM
+// futures, for strategy warmup.
+type RestHistory struct {
+	BaseURL string // "" = Binance production
+	Futures bool
+	client  *http.Client
+}
+
+func NewRestHistory(baseURL string, futures bool) *RestHistory {
+	return &RestHistory{BaseURL: baseURL, Futures: futures, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// History pages backwards from before, 1000 klines per request.
+func (h *RestHistory) History(ctx context.Context, sym, tf string, n int, before time.Time) ([]core.Kline, error) {
+	base, path := h.BaseURL, "/api/v3/klines"
+	if h.Futures {
+		path = "/fapi/v1/klines"
+	}
+	if base == "" {
+		base = "https://api.binance.com"
+		if h.Futures {
+			base = "https://fapi.binance.com"
+		}
+	}
+	dur := core.TFDuration(tf)
+	var out []core.Kline
+	end := before.Add(-dur) // open time of the last closed bar
+	for len(out) < n {
+		limit := min(n-len(out), 1000)
+		url := fmt.Sprintf("%s%s?symbol=%s&interval=%s&endTime=%d&limit=%d", strings.TrimRight(base, "/"), path, sym, tfToBinance(tf), end.UnixMilli(), limit)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := h.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		var raw [][]any
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("binance status %d", resp.StatusCode)
+		}
+		err = json.NewDecoder(resp.Body).Decode(&raw)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		page := make([]core.Kline, 0, len(raw))
+		for _, k := range raw {
+			kl := core.Kline{
+				Symbol: sym, TF: tf, Ts: time.UnixMilli(toInt64(k[0])),
+				Open: toF64(k[1]), High: toF64(k[2]), Low: toF64(k[3]), Close: toF64(k[4]), Vol: toF64(k[5]),
+			}
+			if !core.CloseTime(kl).After(before) {
+				page = append(page, kl)
+			}
+		}
+		out = append(page, out...)
+		if len(raw) < limit || len(page) == 0 {
+			break // no older data
+		}
+		end = page[0].Ts.Add(-time.Millisecond)
+	}
+	return lastN(out, n), nil
+}
+
+// maxCached bounds each cache file.
+const maxCached = 10_000
+
+// CandleCache keeps history fetched from Src on disk, one JSON file per
+// stream, so restarts and strategy switches warm up without a download. It
+// goes to Src only when the cached bars do not reach up to before.
+type CandleCache struct {
+	Dir string
+	Src core.HistorySource // nil = serve the cache only
+	mu  sync.Mutex
+}
+
+func NewCandleCache(dir string, src core.HistorySource) *CandleCache {
+	return &CandleCache{Dir: dir, Src: src}
+}
+
+func (c *CandleCache) History(ctx context.Context, sym, tf string, n int, before time.Time) ([]core.Kline, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	path := filepath.Join(c.Dir, sym+"_"+tf+".json")
+	cached, err := readCandles(path)
+	if err != nil {
+		return nil, err
+	}
+	have := closedBy(cached, before)
+	// current: the last bar that closed by before is cached
+	current := len(have) > 0 && !core.CloseTime(have[len(have)-1]).Before(before.Truncate(core.TFDuration(tf)))
+	if c.Src == nil || current && len(have) >= n {
+		return lastN(have, n), nil
+	}
+	fetched, err := c.Src.History(ctx, sym, tf, n, before)
+	if err != nil {
+		return nil, err
+	}
+	merged := mergeCandles(cached, fetched)
+	if err := writeCandles(path, lastN(merged, maxCached)); err != nil {
+		return nil, err
+	}
+	return lastN(closedBy(merged, before), n), nil
+}
+
+func readCandles(path string) ([]core.Kline, error) {
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var out []core.Kline
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return out, nil
+}
+
+func writeCandles(path string, ks []core.Kline) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	b, err := json.Marshal(ks)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// mergeCandles unions two series by open time; b wins on duplicates.
+func mergeCandles(a, b []core.Kline) []core.Kline {
+	byTs := make(map[int64]core.Kline, len(a)+len(b))
+	for _, k := range a {
+		byTs[k.Ts.UnixMilli()] = k
+	}
+	for _, k := range b {
+		byTs[k.Ts.UnixMilli()] = k
+	}
+	out := make([]core.Kline, 0, len(byTs))
+	for _, k := range byTs {
+		out = append(out, k)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Ts.Before(out[j].Ts) })
+	return out
+}
+
+// closedBy returns the prefix of sorted ks that closes at or before t.
+func closedBy(ks []core.Kline, t time.Time) []core.Kline {
+	i := sort.Search(len(ks), func(i int) bool { return core.CloseTime(ks[i]).After(t) })
+	return ks[:i]
+}
+
+func lastN(ks []core.Kline, n int) []core.Kline {
+	if len(ks) > n {
+		return ks[len(ks)-n:]
+	}
+	return ks
+}
+
+// History generates n random bars that close at before, walking backwards
+// so the last one closes at the price the feed starts from.
+func (f *RandomFeed) History(_ context.Context, sym, tf string, n int, before time.Time) ([]core.Kline, error) {
+	step := core.TFDuration(tf)
+	if step <= 0 {
+		step = time.Minute
+	}
+	r := rand.New(rand.NewSource(before.UnixNano()))
+	out := make([]core.Kline, n)
+	close := f.open
+	for i := n - 1; i >= 0; i-- {
+		ret := (r.Float64() - 0.5) * 2.0 * f.vol
+		open := close / (1.0 + ret)
+		high := maxf(open, close) * (1.0 + r.Float64()*f.vol*0.5)
+		low := minf(open, close) * (1.0 - r.Float64()*f.vol*0.5)
+		vol := 10_000 + r.Float64()*5_000
+		out[i] = core.Kline{Symbol: sym, TF: tf, Open: open, High: high, Low: low, Close: close, Vol: vol, Ts: before.Add(-time.Duration(n-i) * step)}
+		close = open
+	}
+	return out, nil
+}
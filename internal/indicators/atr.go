@@ -0,0 +1,41 @@
+package indicators
+
+import (
+	"math"
+
+	"tradebot/internal/core"
+)
+
+// TrueRange is a bar's range extended to the previous close; the first bar's
+// is its high-low range.
+type TrueRange struct {
+	prev float64
+	seen bool
+	v    float64
+}
+
+func (t *TrueRange) Update(kl core.Kline) float64 {
+	t.v = kl.High - kl.Low
+	if t.seen {
+		t.v = math.Max(t.v, math.Max(math.Abs(kl.High-t.prev), math.Abs(kl.Low-t.prev)))
+	}
+	t.prev, t.seen = kl.Close, true
+	return t.v
+}
+
+func (t *TrueRange) Value() float64 { return t.v }
+func (t *TrueRange) Ready() bool    { return t.seen }
+func (t *TrueRange) Warmup() int    { return 1 }
+
+// ATR is the average true range, smoothed by an EMA of length n.
+type ATR struct {
+	tr  TrueRange
+	ema *EMA
+}
+
+func NewATR(n int) *ATR { return &ATR{ema: NewEMA(n)} }
+
+func (a *ATR) Update(kl core.Kline) float64 { return a.ema.Add(a.tr.Update(kl)) }
+func (a *ATR) Value() float64               { return a.ema.Value() }
+func (a *ATR) Ready() bool                  { return a.ema.Ready() }
+func (a *ATR) Warmup() int                  { return a.ema.Warmup() }
@@ -0,0 +1,91 @@
+package indicators
+
+import "tradebot/internal/core"
+
+// Bollinger bands: the SMA of the last n closes, k standard deviations
+// either side.
+type Bollinger struct {
+	k   float64
+	sd  *StdDev
+	mid float64
+	dev float64
+}
+
+func NewBollinger(n int, k float64) *Bollinger { return &Bollinger{k: k, sd: NewStdDev(n)} }
+
+func (b *Bollinger) Update(kl core.Kline) float64 {
+	b.dev = b.sd.Add(kl.Close)
+	b.mid = b.sd.Mean()
+	return b.mid
+}
+
+func (b *Bollinger) Value() float64 { return b.mid }
+func (b *Bollinger) Mid() float64   { return b.mid }
+func (b *Bollinger) Upper() float64 { return b.mid + b.k*b.dev }
+func (b *Bollinger) Lower() float64 { return b.mid - b.k*b.dev }
+
+// Width is the band width relative to the middle band.
+func (b *Bollinger) Width() float64 {
+	if b.mid == 0 {
+		return 0
+	}
+	return (b.Upper() - b.Lower()) / b.mid
+}
+
+// PercentB places x within the bands: 0 at the lower, 1 at the upper.
+func (b *Bollinger) PercentB(x float64) float64 {
+	if b.dev == 0 {
+		return 0.5
+	}
+	return (x - b.Lower()) / (b.Upper() - b.Lower())
+}
+
+func (b *Bollinger) Ready() bool { return b.sd.Ready() }
+func (b *Bollinger) Warmup() int { return b.sd.Warmup() }
+
+// Keltner channel: an EMA of closes, mult ATRs either side.
+type Keltner struct {
+	mult float64
+	ema  *EMA
+	atr  *ATR
+}
+
+func NewKeltner(n, atrLen int, mult float64) *Keltner {
+	return &Keltner{mult: mult, ema: NewEMA(n), atr: NewATR(atrLen)}
+}
+
+func (k *Keltner) Update(kl core.Kline) float64 {
+	k.atr.Update(kl)
+	return k.ema.Update(kl)
+}
+
+func (k *Keltner) Value() float64 { return k.ema.Value() }
+func (k *Keltner) Mid() float64   { return k.ema.Value() }
+func (k *Keltner) Upper() float64 { return k.ema.Value() + k.mult*k.atr.Value() }
+func (k *Keltner) Lower() float64 { return k.ema.Value() - k.mult*k.atr.Value() }
+func (k *Keltner) Ready() bool    { return k.ema.Ready() && k.atr.Ready() }
+func (k *Keltner) Warmup() int    { return max(k.ema.Warmup(), k.atr.Warmup()) }
+
+// Donchian channel: the highest high and lowest low of the last n bars,
+// including the current one. Read it before Update for a breakout of the
+// previous n bars.
+type Donchian struct {
+	hi, lo *extreme
+}
+
+func NewDonchian(n int) *Donchian {
+	return &Donchian{hi: newExtreme(n, true), lo: newExtreme(n, false)}
+}
+
+func (d *Donchian) Update(kl core.Kline) float64 {
+	d.hi.add(kl.High)
+	d.lo.add(kl.Low)
+	return d.Mid()
+}
+
+func (d *Donchian) Value() float64 { return d.Mid() }
+func (d *Donchian) Upper() float64 { return d.hi.value() }
+func (d *Donchian) Lower() float64 { return d.lo.value() }
+func (d *Donchian) Mid() float64   { return (d.hi.value() + d.lo.value()) / 2 }
+func (d *Donchian) Ready() bool    { return d.hi.full() }
+func (d *Donchian) Warmup() int    { return d.hi.n }
@@ -0,0 +1,541 @@
+package indicators
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"tradebot/internal/core"
+)
+
+// Env evaluates indicator expressions over one candle stream, for rule-based
+// strategies written in the backtest DSL, e.g.
+//
+//	cross_up(ema(9), ema(21)) and rsi(14) < 70
+//	close > prev(donchian_upper(20))
+//
+// Expressions combine numbers, the bar fields open, high, low, close and
+// volume, the indicator functions listed by Functions, cross_up/cross_down,
+// prev, abs/min/max, arithmetic, comparisons and and/or/not; true is 1 and
+// false 0. Indicator arguments are numeric literals, and calls with the same
+// arguments share one instance, updated once per candle.
+type Env struct {
+	inds  map[string]Indicator // by canonical call, e.g. "bb(20,2)"
+	steps []func()             // per candle, in dependency order
+	kl    core.Kline
+	bars  int
+	lag   int // extra bars needed by cross_up/cross_down/prev
+}
+
+func NewEnv() *Env { return &Env{inds: map[string]Indicator{}} }
+
+// Expr is a compiled expression bound to its Env.
+type Expr struct {
+	src  string
+	eval func() float64
+}
+
+func (x *Expr) Eval() float64  { return x.eval() }
+func (x *Expr) True() bool     { return x.eval() != 0 }
+func (x *Expr) String() string { return x.src }
+
+// Update advances every indicator and stateful node by one candle.
+func (e *Env) Update(kl core.Kline) {
+	e.kl = kl
+	e.bars++
+	for _, step := range e.steps {
+		step()
+	}
+}
+
+// Warmup is the bars every expression compiled so far needs to be ready.
+func (e *Env) Warmup() int {
+	n := 1
+	for _, ind := range e.inds {
+		n = max(n, ind.Warmup())
+	}
+	return n + e.lag
+}
+
+// Ready reports whether every indicator is ready and prev/cross nodes have
+// seen a bar before that.
+func (e *Env) Ready() bool {
+	for _, ind := range e.inds {
+		if !ind.Ready() {
+			return false
+		}
+	}
+	return e.bars >= e.Warmup()
+}
+
+// Compile parses src and registers its indicators with e.
+func (e *Env) Compile(src string) (*Expr, error) {
+	var eval evalFn
+	toks, err := lex(src)
+	if err == nil {
+		p := &parser{env: e, toks: toks}
+		eval, err = p.or()
+		if err == nil && p.peek().kind != tkEOF {
+			err = fmt.Errorf("unexpected %q", p.peek().text)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%q: %w", src, err)
+	}
+	return &Expr{src: src, eval: eval}, nil
+}
+
+// function is an indicator function of the expression language. Functions
+// with the same base share an instance, e.g. bb_upper(20,2) and bb_lower(20,2).
+type function struct {
+	base  string
+	defs  []float64 // default arguments; fewer may be given
+	build func(a []float64) Indicator
+	read  func(Indicator) float64
+}
+
+func value(ind Indicator) float64 { return ind.Value() }
+
+// length reads argument i as a bar count; fractions are dropped.
+func length(a []float64, i int) int { return int(a[i]) }
+
+var (
+	bb       = func(a []float64) Indicator { return NewBollinger(length(a, 0), a[1]) }
+	keltner  = func(a []float64) Indicator { return NewKeltner(length(a, 0), length(a, 1), a[2]) }
+	donchian = func(a []float64) Indicator { return NewDonchian(length(a, 0)) }
+	macd     = func(a []float64) Indicator { return NewMACD(length(a, 0), length(a, 1), length(a, 2)) }
+	stoch    = func(a []float64) Indicator { return NewStochastic(length(a, 0), length(a, 1), length(a, 2)) }
+	adx      = func(a []float64) Indicator { return NewADX(length(a, 0)) }
+	st       = func(a []float64) Indicator { return NewSuperTrend(length(a, 0), a[1]) }
+)
+
+var functions = map[string]function{
+	"sma":    {"sma", []float64{20}, func(a []float64) Indicator { return NewSMA(length(a, 0)) }, value},
+	"ema":    {"ema", []float64{20}, func(a []float64) Indicator { return NewEMA(length(a, 0)) }, value},
+	"wma":    {"wma", []float64{20}, func(a []float64) Indicator { return NewWMA(length(a, 0)) }, value},
+	"rsi":    {"rsi", []float64{14}, func(a []float64) Indicator { return NewRSI(length(a, 0)) }, value},
+	"atr":    {"atr", []float64{14}, func(a []float64) Indicator { return NewATR(length(a, 0)) }, value},
+	"tr":     {"tr", nil, func([]float64) Indicator { return &TrueRange{} }, value},
+	"stdev":  {"stdev", []float64{20}, func(a []float64) Indicator { return NewStdDev(length(a, 0)) }, value},
+	"zscore": {"zscore", []float64{20}, func(a []float64) Indicator { return NewZScore(length(a, 0)) }, value},
+	"vwap":   {"vwap", nil, func([]float64) Indicator { return NewVWAP(0) }, value},
+	"obv":    {"obv", nil, func([]float64) Indicator { return &OBV{} }, value},
+
+	"bb_mid":   {"bb", []float64{20, 2}, bb, value},
+	"bb_upper": {"bb", []float64{20, 2}, bb, func(i Indicator) float64 { return i.(*Bollinger).Upper() }},
+	"bb_lower": {"bb", []float64{20, 2}, bb, func(i Indicator) float64 { return i.(*Bollinger).Lower() }},
+	"bb_width": {"bb", []float64{20, 2}, bb, func(i Indicator) float64 { return i.(*Bollinger).Width() }},
+
+	"keltner_mid":   {"keltner", []float64{20, 10, 2}, keltner, value},
+	"keltner_upper": {"keltner", []float64{20, 10, 2}, keltner, func(i Indicator) float64 { return i.(*Keltner).Upper() }},
+	"keltner_lower": {"keltner", []float64{20, 10, 2}, keltner, func(i Indicator) float64 { return i.(*Keltner).Lower() }},
+
+	"donchian_mid":   {"donchian", []float64{20}, donchian, value},
+	"donchian_upper": {"donchian", []float64{20}, donchian, func(i Indicator) float64 { return i.(*Donchian).Upper() }},
+	"donchian_lower": {"donchian", []float64{20}, donchian, func(i Indicator) float64 { return i.(*Donchian).Lower() }},
+
+	"macd":        {"macd", []float64{12, 26, 9}, macd, value},
+	"macd_signal": {"macd", []float64{12, 26, 9}, macd, func(i Indicator) float64 { return i.(*MACD).Signal() }},
+	"macd_hist":   {"macd", []float64{12, 26, 9}, macd, func(i Indicator) float64 { return i.(*MACD).Histogram() }},
+
+	"stoch_k": {"stoch", []float64{14, 3, 3}, stoch, value},
+	"stoch_d": {"stoch", []float64{14, 3, 3}, stoch, func(i Indicator) float64 { return i.(*Stochastic).D() }},
+
+	"adx":      {"adx", []float64{14}, adx, value},
+	"plus_di":  {"adx", []float64{14}, adx, func(i Indicator) float64 { return i.(*ADX).PlusDI() }},
+	"minus_di": {"adx", []float64{14}, adx, func(i Indicator) float64 { return i.(*ADX).MinusDI() }},
+
+	"supertrend":     {"supertrend", []float64{10, 3}, st, value},
+	"supertrend_dir": {"supertrend", []float64{10, 3}, st, func(i Indicator) float64 { return float64(i.(*SuperTrend).Direction()) }},
+}
+
+// Functions lists the indicator functions of the expression language with
+// their default arguments, e.g. "bb_upper(20,2)".
+func Functions() []string {
+	out := make([]string, 0, len(functions))
+	for name, f := range functions {
+		out = append(out, name+"("+fmtArgs(f.defs)+")")
+	}
+	sort.Strings(out)
+	return out
+}
+
+func fmtArgs(a []float64) string {
+	parts := make([]string, len(a))
+	for i, v := range a {
+		parts[i] = strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return strings.Join(parts, ",")
+}
+
+// === lexer ===
+
+type tokKind int
+
+const (
+	tkEOF tokKind = iota
+	tkNum
+	tkIdent
+	tkOp
+)
+
+type token struct {
+	kind tokKind
+	text string
+	num  float64
+}
+
+func lex(src string) ([]token, error) {
+	var out []token
+	for i := 0; i < len(src); {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case unicode.IsDigit(c) || c == '.':
+			j := i
+			for j < len(src) && (unicode.IsDigit(rune(src[j])) || src[j] == '.') {
+				j++
+			}
+			v, err := strconv.ParseFloat(src[i:j], 64)
+			if err != nil {
+				return nil, fmt.Errorf("bad number %q", src[i:j])
+			}
+			out = append(out, token{kind: tkNum, text: src[i:j], num: v})
+			i = j
+		case unicode.IsLetter(c) || c == '_':
+			j := i
+			for j < len(src) && (unicode.IsLetter(rune(src[j])) || unicode.IsDigit(rune(src[j])) || src[j] == '_') {
+				j++
+			}
+			out = append(out, token{kind: tkIdent, text: strings.ToLower(src[i:j])})
+			i = j
+		default:
+			op := src[i : i+1]
+			if i+1 < len(src) {
+				switch two := src[i : i+2]; two {
+				case "<=", ">=", "==", "!=", "&&", "||":
+					op = two
+				}
+			}
+			if len(op) == 1 && !strings.Contains("()+-*/<>!,", op) {
+				return nil, fmt.Errorf("unexpected %q", op)
+			}
+			out = append(out, token{kind: tkOp, text: op})
+			i += len(op)
+		}
+	}
+	return append(out, token{kind: tkEOF}), nil
+}
+
+// === parser ===
+
+type parser struct {
+	env  *Env
+	toks []token
+	pos  int
+}
+
+type evalFn = func() float64
+
+func (p *parser) peek() token { return p.toks[p.pos] }
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tkEOF {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes the next token if it is one of ops (operators or keywords).
+func (p *parser) accept(ops ...string) (string, bool) {
+	t := p.peek()
+	if t.kind != tkOp && t.kind != tkIdent {
+		return "", false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			p.pos++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *parser) expect(op string) error {
+	if _, ok := p.accept(op); !ok {
+		return fmt.Errorf("expected %q, got %q", op, p.peek().text)
+	}
+	return nil
+}
+
+func truth(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func (p *parser) or() (evalFn, error) {
+	l, err := p.and()
+	for err == nil {
+		if _, ok := p.accept("or", "||"); !ok {
+			break
+		}
+		var r evalFn
+		if r, err = p.and(); err == nil {
+			a, b := l, r
+			l = func() float64 { return truth(a() != 0 || b() != 0) }
+		}
+	}
+	return l, err
+}
+
+func (p *parser) and() (evalFn, error) {
+	l, err := p.not()
+	for err == nil {
+		if _, ok := p.accept("and", "&&"); !ok {
+			break
+		}
+		var r evalFn
+		if r, err = p.not(); err == nil {
+			a, b := l, r
+			l = func() float64 { return truth(a() != 0 && b() != 0) }
+		}
+	}
+	return l, err
+}
+
+func (p *parser) not() (evalFn, error) {
+	if _, ok := p.accept("not", "!"); ok {
+		x, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return func() float64 { return truth(x() == 0) }, nil
+	}
+	return p.compare()
+}
+
+func (p *parser) compare() (evalFn, error) {
+	l, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	op, ok := p.accept("<", ">", "<=", ">=", "==", "!=")
+	if !ok {
+		return l, nil
+	}
+	r, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	cmp := map[string]func(a, b float64) bool{
+		"<": func(a, b float64) bool { return a < b }, ">": func(a, b float64) bool { return a > b },
+		"<=": func(a, b float64) bool { return a <= b }, ">=": func(a, b float64) bool { return a >= b },
+		"==": func(a, b float64) bool { return a == b }, "!=": func(a, b float64) bool { return a != b },
+	}[op]
+	return func() float64 { return truth(cmp(l(), r())) }, nil
+}
+
+func (p *parser) sum() (evalFn, error) {
+	l, err := p.product()
+	for err == nil {
+		op, ok := p.accept("+", "-")
+		if !ok {
+			break
+		}
+		var r evalFn
+		if r, err = p.product(); err == nil {
+			a, b := l, r
+			if op == "+" {
+				l = func() float64 { return a() + b() }
+			} else {
+				l = func() float64 { return a() - b() }
+			}
+		}
+	}
+	return l, err
+}
+
+func (p *parser) product() (evalFn, error) {
+	l, err := p.unary()
+	for err == nil {
+		op, ok := p.accept("*", "/")
+		if !ok {
+			break
+		}
+		var r evalFn
+		if r, err = p.unary(); err == nil {
+			a, b := l, r
+			if op == "*" {
+				l = func() float64 { return a() * b() }
+			} else {
+				l = func() float64 {
+					if d := b(); d != 0 {
+						return a() / d
+					}
+					return 0
+				}
+			}
+		}
+	}
+	return l, err
+}
+
+func (p *parser) unary() (evalFn, error) {
+	if _, ok := p.accept("-"); ok {
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return func() float64 { return -x() }, nil
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (evalFn, error) {
+	t := p.next()
+	switch t.kind {
+	case tkNum:
+		v := t.num
+		return func() float64 { return v }, nil
+	case tkOp:
+		if t.text != "(" {
+			return nil, fmt.Errorf("unexpected %q", t.text)
+		}
+		x, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		return x, p.expect(")")
+	case tkIdent:
+		return p.ident(t.text)
+	}
+	return nil, fmt.Errorf("unexpected end")
+}
+
+func (p *parser) ident(name string) (evalFn, error) {
+	e := p.env
+	switch name {
+	case "open":
+		return func() float64 { return e.kl.Open }, nil
+	case "high":
+		return func() float64 { return e.kl.High }, nil
+	case "low":
+		return func() float64 { return e.kl.Low }, nil
+	case "close":
+		return func() float64 { return e.kl.Close }, nil
+	case "volume", "vol":
+		return func() float64 { return e.kl.Vol }, nil
+	case "true":
+		return func() float64 { return 1 }, nil
+	case "false":
+		return func() float64 { return 0 }, nil
+	}
+	if err := p.expect("("); err != nil {
+		return nil, fmt.Errorf("unknown name %q", name)
+	}
+	if f, ok := functions[name]; ok {
+		return p.indicator(name, f)
+	}
+	args, err := p.exprArgs()
+	if err != nil {
+		return nil, err
+	}
+	switch name {
+	case "cross_up", "cross_down":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("%s takes 2 arguments", name)
+		}
+		a, b, c, dir := args[0], args[1], &Cross{}, 1.0
+		if name == "cross_down" {
+			dir = -1
+		}
+		var v float64
+		e.steps = append(e.steps, func() { v = truth(float64(c.Update(a(), b())) == dir) })
+		e.lag = max(e.lag, 1)
+		return func() float64 { return v }, nil
+	case "prev":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("prev takes 1 argument")
+		}
+		x := args[0]
+		var cur, last float64
+		e.steps = append(e.steps, func() { last, cur = cur, x() })
+		e.lag = max(e.lag, 1)
+		return func() float64 { return last }, nil
+	case "abs":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("abs takes 1 argument")
+		}
+		x := args[0]
+		return func() float64 { return math.Abs(x()) }, nil
+	case "min", "max":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("%s takes 2 arguments", name)
+		}
+		a, b, pick := args[0], args[1], math.Min
+		if name == "max" {
+			pick = math.Max
+		}
+		return func() float64 { return pick(a(), b()) }, nil
+	}
+	return nil, fmt.Errorf("unknown function %q", name)
+}
+
+// exprArgs parses call arguments after "(" up to and including ")".
+func (p *parser) exprArgs() ([]evalFn, error) {
+	var out []evalFn
+	if _, ok := p.accept(")"); ok {
+		return out, nil
+	}
+	for {
+		x, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, x)
+		if _, ok := p.accept(","); !ok {
+			return out, p.expect(")")
+		}
+	}
+}
+
+// indicator parses literal arguments after "(" and binds the shared instance.
+func (p *parser) indicator(name string, f function) (evalFn, error) {
+	args := append([]float64(nil), f.defs...)
+	i := 0
+	for _, ok := p.accept(")"); !ok; _, ok = p.accept(")") {
+		if i > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		t := p.next()
+		if t.kind != tkNum {
+			return nil, fmt.Errorf("%s: arguments must be numbers", name)
+		}
+		if i >= len(args) {
+			return nil, fmt.Errorf("%s takes at most %d arguments", name, len(f.defs))
+		}
+		if t.num <= 0 {
+			return nil, fmt.Errorf("%s: arguments must be positive", name)
+		}
+		args[i] = t.num
+		i++
+	}
+	key := f.base + "(" + fmtArgs(args) + ")"
+	ind, ok := p.env.inds[key]
+	if !ok {
+		ind = f.build(args)
+		p.env.inds[key] = ind
+		e := p.env
+		p.env.steps = append(p.env.steps, func() { ind.Update(e.kl) })
+	}
+	read := f.read
+	return func() float64 { return read(ind) }, nil
+}
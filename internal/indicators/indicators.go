@@ -0,0 +1,100 @@
+// Package indicators provides streaming technical indicators. Each one
+// consumes closed candles one at a time in O(1) and keeps only the window it
+// needs, so a strategy's cost per candle stays flat however long it runs.
+//
+// Indicators seed from their first input and report Ready once they have
+// seen Warmup() bars and their value is meaningful; Value before that is
+// the partial value, or 0 where none exists yet. Multi-line indicators
+// (bands, MACD, ...) return their main line from Update and Value and expose
+// the others through methods.
+package indicators
+
+import "tradebot/internal/core"
+
+// Indicator is a streaming indicator over candles.
+type Indicator interface {
+	Update(kl core.Kline) float64
+	Value() float64
+	Ready() bool
+	Warmup() int
+}
+
+// Ring is a fixed-size window of the most recent values.
+type Ring struct {
+	buf  []float64
+	next int
+	n    int
+}
+
+func NewRing(size int) *Ring {
+	if size < 1 {
+		size = 1
+	}
+	return &Ring{buf: make([]float64, size)}
+}
+
+// Push appends x and returns the value it evicted, if the window was full.
+func (r *Ring) Push(x float64) (old float64, evicted bool) {
+	if r.n == len(r.buf) {
+		old, evicted = r.buf[r.next], true
+	} else {
+		r.n++
+	}
+	r.buf[r.next] = x
+	r.next = (r.next + 1) % len(r.buf)
+	return old, evicted
+}
+
+// At returns the value i pushes ago; At(0) is the latest.
+func (r *Ring) At(i int) float64 {
+	if i < 0 || i >= r.n {
+		return 0
+	}
+	return r.buf[(r.next-1-i+2*len(r.buf))%len(r.buf)]
+}
+
+func (r *Ring) Len() int   { return r.n }
+func (r *Ring) Cap() int   { return len(r.buf) }
+func (r *Ring) Full() bool { return r.n == len(r.buf) }
+
+// Cross follows two series and reports when the first crosses the second.
+type Cross struct {
+	a, b float64
+	seen bool
+}
+
+// Update returns +1 when a crosses above b on this bar, -1 when it crosses
+// below, and 0 otherwise. Touching and then leaving counts as a cross.
+func (c *Cross) Update(a, b float64) int {
+	pa, pb, seen := c.a, c.b, c.seen
+	c.a, c.b, c.seen = a, b, true
+	switch {
+	case !seen:
+		return 0
+	case pa <= pb && a > b:
+		return 1
+	case pa >= pb && a < b:
+		return -1
+	}
+	return 0
+}
+
+var (
+	_ Indicator = (*SMA)(nil)
+	_ Indicator = (*WMA)(nil)
+	_ Indicator = (*EMA)(nil)
+	_ Indicator = (*TrueRange)(nil)
+	_ Indicator = (*ATR)(nil)
+	_ Indicator = (*RSI)(nil)
+	_ Indicator = (*StdDev)(nil)
+	_ Indicator = (*ZScore)(nil)
+	_ Indicator = (*Bollinger)(nil)
+	_ Indicator = (*Keltner)(nil)
+	_ Indicator = (*Donchian)(nil)
+	_ Indicator = (*MACD)(nil)
+	_ Indicator = (*Stochastic)(nil)
+	_ Indicator = (*ADX)(nil)
+	_ Indicator = (*SuperTrend)(nil)
+	_ Indicator = (*VWAP)(nil)
+	_ Indicator = (*OBV)(nil)
+)
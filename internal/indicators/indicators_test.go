@@ -0,0 +1,274 @@
+package indicators
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"tradebot/internal/core"
+)
+
+var t0 = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// closes is one hourly candle per close, with no range.
+func closes(xs ...float64) []core.Kline {
+	out := make([]core.Kline, len(xs))
+	for i, x := range xs {
+		out[i] = core.Kline{Ts: t0.Add(time.Duration(i) * time.Hour), Open: x, High: x, Low: x, Close: x, Vol: 1}
+	}
+	return out
+}
+
+// ohlc is one hourly candle per {open, high, low, close}.
+func ohlc(rows ...[4]float64) []core.Kline {
+	out := make([]core.Kline, len(rows))
+	for i, r := range rows {
+		out[i] = core.Kline{Ts: t0.Add(time.Duration(i) * time.Hour), Open: r[0], High: r[1], Low: r[2], Close: r[3], Vol: 1}
+	}
+	return out
+}
+
+var skip = math.NaN() // no expectation for this bar
+
+func TestIndicatorsReference(t *testing.T) {
+	atrBars := ohlc(
+		[4]float64{10, 12, 9, 11},  // TR 3 (high-low)
+		[4]float64{11, 13, 10, 12}, // TR 3
+		[4]float64{12, 12, 8, 9},   // TR 4
+		[4]float64{9, 15, 9, 14},   // TR 6 (high-prev close)
+	)
+	// the closes of StockCharts' Wilder RSI(14) example; its table rounds the
+	// averages to cents and reads up to 0.07 higher than these exact values
+	rsiCloses := closes(44.34, 44.09, 44.15, 43.61, 44.33, 44.83, 45.10, 45.42, 45.84, 46.08, 45.89, 46.03, 45.61, 46.28,
+		46.28, 46.00, 46.03, 46.41, 46.22, 45.64)
+	rsiWant := []float64{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 70.46, 66.25, 66.48, 69.35, 66.29, 57.92}
+
+	tests := []struct {
+		name  string
+		ind   Indicator
+		read  func(Indicator) float64
+		bars  []core.Kline
+		want  []float64
+		tol   float64
+		ready int // bars until Ready
+	}{
+		{"sma(3)", NewSMA(3), value, closes(1, 2, 3, 4, 5), []float64{1, 1.5, 2, 3, 4}, 1e-9, 3},
+		{"ema(3)", NewEMA(3), value, closes(1, 2, 3, 4, 5), []float64{1, 1.5, 2.25, 3.125, 4.0625}, 1e-9, 3},
+		{"wma(3)", NewWMA(3), value, closes(1, 2, 3, 4, 5), []float64{1, 5.0 / 3, 14.0 / 6, 20.0 / 6, 26.0 / 6}, 1e-9, 3},
+		{"stdev(5)", NewStdDev(5), value, closes(1, 2, 3, 4, 5, 6), []float64{0, 0.5, skip, skip, math.Sqrt2, math.Sqrt2}, 1e-9, 5},
+		{"zscore(5)", NewZScore(5), value, closes(3, 3, 3, 3, 3, 8), []float64{0, 0, 0, 0, 0, 2}, 1e-9, 5},
+		{"rsi(14)", NewRSI(14), value, rsiCloses, rsiWant, 0.005, 15},
+		{"rsi flat", NewRSI(2), value, closes(5, 5, 5, 5), []float64{0, 0, 50, 50}, 1e-9, 3},
+		{"rsi only gains", NewRSI(2), value, closes(1, 2, 3, 4), []float64{0, 0, 100, 100}, 1e-9, 3},
+		{"tr", &TrueRange{}, value, atrBars, []float64{3, 3, 4, 6}, 1e-9, 1},
+		{"atr(3)", NewATR(3), value, atrBars, []float64{3, 3, 3.5, 4.75}, 1e-9, 3},
+		{"bb_mid(5,2)", NewBollinger(5, 2), value, closes(1, 2, 3, 4, 5), []float64{skip, skip, skip, skip, 3}, 1e-9, 5},
+		{"bb_upper(5,2)", NewBollinger(5, 2), func(i Indicator) float64 { return i.(*Bollinger).Upper() }, closes(1, 2, 3, 4, 5), []float64{skip, skip, skip, skip, 3 + 2*math.Sqrt2}, 1e-9, 5},
+		{"bb_lower(5,2)", NewBollinger(5, 2), func(i Indicator) float64 { return i.(*Bollinger).Lower() }, closes(1, 2, 3, 4, 5), []float64{skip, skip, skip, skip, 3 - 2*math.Sqrt2}, 1e-9, 5},
+		{"bb_width(5,2)", NewBollinger(5, 2), func(i Indicator) float64 { return i.(*Bollinger).Width() }, closes(1, 2, 3, 4, 5), []float64{skip, skip, skip, skip, 4 * math.Sqrt2 / 3}, 1e-9, 5},
+		{"donchian_upper(3)", NewDonchian(3), func(i Indicator) float64 { return i.(*Donchian).Upper() }, atrBars, []float64{12, 13, 13, 15}, 1e-9, 3},
+		{"donchian_lower(3)", NewDonchian(3), func(i Indicator) float64 { return i.(*Donchian).Lower() }, atrBars, []float64{9, 9, 8, 8}, 1e-9, 3},
+		{"donchian_mid(3)", NewDonchian(3), value, atrBars, []float64{10.5, 11, 10.5, 11.5}, 1e-9, 3},
+		{"macd(2,3,2)", NewMACD(2, 3, 2), value, closes(1, 2, 3, 4), []float64{0, 1.0 / 6, 11.0 / 36, 85.0 / 216}, 1e-9, 4},
+		{"stoch_k(3,1,1)", NewStochastic(3, 1, 1), value, atrBars, []float64{200.0 / 3, 75, 20, 600.0 / 7}, 1e-9, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i, kl := range tt.bars {
+				tt.ind.Update(kl)
+				if want := tt.want[i]; !math.IsNaN(want) {
+					if got := tt.read(tt.ind); math.Abs(got-want) > tt.tol {
+						t.Errorf("bar %d: %s = %.6f, want %.6f", i+1, tt.name, got, want)
+					}
+				}
+				if ready := i+1 >= tt.ready; tt.ind.Ready() != ready {
+					t.Errorf("bar %d: Ready = %v, want %v", i+1, tt.ind.Ready(), ready)
+				}
+			}
+			if tt.ind.Warmup() != tt.ready {
+				t.Errorf("Warmup = %d, want %d", tt.ind.Warmup(), tt.ready)
+			}
+		})
+	}
+}
+
+// TestIndicatorsMatchNaive checks the streaming indicators against direct
+// computations over the window on a random walk.
+func TestIndicatorsMatchNaive(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	var bars []core.Kline
+	px := 100.0
+	for i := 0; i < 500; i++ {
+		o := px
+		px *= 1 + rng.NormFloat64()*0.01
+		bars = append(bars, core.Kline{Ts: t0.Add(time.Duration(i) * time.Hour), Open: o, High: math.Max(o, px) + rng.Float64(), Low: math.Min(o, px) - rng.Float64(), Close: px, Vol: 1})
+	}
+	const n = 14
+	win := func(i int) []core.Kline { return bars[max(0, i-n+1) : i+1] }
+	naive := map[string]func(i int) float64{
+		"sma": func(i int) float64 {
+			s := 0.0
+			for _, b := range win(i) {
+				s += b.Close
+			}
+			return s / float64(len(win(i)))
+		},
+		"wma": func(i int) float64 {
+			s, w := 0.0, 0.0
+			for j, b := range win(i) {
+				s += float64(j+1) * b.Close
+				w += float64(j + 1)
+			}
+			return s / w
+		},
+		"stdev": func(i int) float64 {
+			m, v := 0.0, 0.0
+			for _, b := range win(i) {
+				m += b.Close
+			}
+			m /= float64(len(win(i)))
+			for _, b := range win(i) {
+				v += (b.Close - m) * (b.Close - m)
+			}
+			return math.Sqrt(v / float64(len(win(i))))
+		},
+		"donchian_upper": func(i int) float64 {
+			hi := math.Inf(-1)
+			for _, b := range win(i) {
+				hi = math.Max(hi, b.High)
+			}
+			return hi
+		},
+		"donchian_lower": func(i int) float64 {
+			lo := math.Inf(1)
+			for _, b := range win(i) {
+				lo = math.Min(lo, b.Low)
+			}
+			return lo
+		},
+	}
+	for name, want := range naive {
+		env := NewEnv()
+		x, err := env.Compile(name + "(14)")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, kl := range bars {
+			env.Update(kl)
+			if got := x.Eval(); math.Abs(got-want(i)) > 1e-6 {
+				t.Fatalf("%s bar %d = %.9f, want %.9f", name, i+1, got, want(i))
+			}
+		}
+	}
+}
+
+func TestExpr(t *testing.T) {
+	bars := closes(1, 2, 3, 4, 5)
+	tests := []struct {
+		src  string
+		want float64 // after the last bar
+	}{
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"10 / 4 - 1", 1.5},
+		{"-close", -5},
+		{"close > open", 0},
+		{"close >= 5 and volume == 1", 1},
+		{"not 0 and 1", 1},
+		{"0 or 1 < 2", 1},
+		{"true and not false", 1},
+		{"abs(1 - close)", 4},
+		{"max(close, 10) - min(1, 2)", 9},
+		{"sma(3)", 4},
+		{"sma()", 3}, // default length 20 over 5 bars
+		{"prev(close)", 4},
+		{"prev(sma(2))", 3.5},
+		{"cross_up(close, 4.5)", 1},
+		{"cross_up(close, 3.5)", 0}, // crossed a bar earlier
+		{"cross_down(close, 4.5)", 0},
+		{"bb_upper(5,2) - bb_mid(5,2)", 2 * math.Sqrt2},
+		{"rsi(2)", 100},
+	}
+	for _, tt := range tests {
+		env := NewEnv()
+		x, err := env.Compile(tt.src)
+		if err != nil {
+			t.Errorf("%s: %v", tt.src, err)
+			continue
+		}
+		for _, kl := range bars {
+			env.Update(kl)
+		}
+		if got := x.Eval(); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s = %v, want %v", tt.src, got, tt.want)
+		}
+		if x.True() != (tt.want != 0) {
+			t.Errorf("%s: True = %v", tt.src, x.True())
+		}
+	}
+}
+
+func TestExprCrossDown(t *testing.T) {
+	env := NewEnv()
+	x, err := env.Compile("cross_down(close, 2.5)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []bool
+	for _, kl := range closes(3, 2, 3, 1) {
+		env.Update(kl)
+		got = append(got, x.True())
+	}
+	if want := []bool{false, true, false, true}; !equalBools(got, want) {
+		t.Fatalf("cross_down per bar = %v, want %v", got, want)
+	}
+}
+
+func equalBools(a, b []bool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestExprSharesInstancesAndWarmup(t *testing.T) {
+	env := NewEnv()
+	for _, src := range []string{"bb_upper(20,2) > close", "bb_lower(20, 2) < close", "cross_up(ema(9), ema(21))"} {
+		if _, err := env.Compile(src); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(env.inds) != 3 {
+		t.Errorf("%d indicator instances, want 3 (one Bollinger, two EMAs): %v", len(env.inds), env.inds)
+	}
+	if w := env.Warmup(); w != 22 {
+		t.Errorf("Warmup = %d, want 22 (ema(21) and a bar for the cross)", w)
+	}
+	bars := closes(make([]float64, 22)...)
+	for i, kl := range bars {
+		if env.Ready() {
+			t.Fatalf("ready after %d bars", i)
+		}
+		env.Update(kl)
+	}
+	if !env.Ready() {
+		t.Fatal("not ready after Warmup bars")
+	}
+}
+
+func TestExprErrors(t *testing.T) {
+	for _, src := range []string{
+		"", "sma(", "close >", "1 2", "foo(3)", "bar", "sma(close)", "sma(0)", "sma(1,2)",
+		"cross_up(close)", "prev()", "abs(1, 2)", "max(1)", "(1 + 2", "1 $ 2",
+	} {
+		if _, err := NewEnv().Compile(src); err == nil {
+			t.Errorf("%q compiled, want an error", src)
+		}
+	}
+}
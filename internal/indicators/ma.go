@@ -0,0 +1,113 @@
+package indicators
+
+import "tradebot/internal/core"
+
+// SMA is the simple moving average of the last n closes.
+type SMA struct {
+	n   int
+	win *Ring
+	sum float64
+}
+
+func NewSMA(n int) *SMA {
+	if n < 1 {
+		n = 1
+	}
+	return &SMA{n: n, win: NewRing(n)}
+}
+
+func (s *SMA) Update(kl core.Kline) float64 { return s.Add(kl.Close) }
+
+// Add feeds a raw value.
+func (s *SMA) Add(x float64) float64 {
+	if old, ok := s.win.Push(x); ok {
+		s.sum -= old
+	}
+	s.sum += x
+	return s.Value()
+}
+
+func (s *SMA) Value() float64 {
+	if s.win.Len() == 0 {
+		return 0
+	}
+	return s.sum / float64(s.win.Len())
+}
+
+func (s *SMA) Ready() bool { return s.win.Full() }
+func (s *SMA) Warmup() int { return s.n }
+
+// WMA is the linearly weighted moving average of the last n closes, the
+// newest weighted n.
+type WMA struct {
+	n        int
+	win      *Ring
+	sum, num float64 // plain and weighted sums of the window
+}
+
+func NewWMA(n int) *WMA {
+	if n < 1 {
+		n = 1
+	}
+	return &WMA{n: n, win: NewRing(n)}
+}
+
+func (w *WMA) Update(kl core.Kline) float64 { return w.Add(kl.Close) }
+
+// Add feeds a raw value.
+func (w *WMA) Add(x float64) float64 {
+	m := float64(w.win.Len())
+	if old, ok := w.win.Push(x); ok {
+		// every weight drops by one, the oldest to zero
+		w.num += m*x - w.sum
+		w.sum += x - old
+	} else {
+		w.num += (m + 1) * x
+		w.sum += x
+	}
+	return w.Value()
+}
+
+func (w *WMA) Value() float64 {
+	m := float64(w.win.Len())
+	if m == 0 {
+		return 0
+	}
+	return w.num / (m * (m + 1) / 2)
+}
+
+func (w *WMA) Ready() bool { return w.win.Full() }
+func (w *WMA) Warmup() int { return w.n }
+
+// EMA is the exponential moving average of closes with alpha 2/(n+1),
+// seeded with the first value.
+type EMA struct {
+	n     int
+	k     float64
+	v     float64
+	count int
+}
+
+func NewEMA(n int) *EMA {
+	if n < 1 {
+		n = 1
+	}
+	return &EMA{n: n, k: 2.0 / (float64(n) + 1)}
+}
+
+func (e *EMA) Update(kl core.Kline) float64 { return e.Add(kl.Close) }
+
+// Add feeds a raw value.
+func (e *EMA) Add(x float64) float64 {
+	if e.count == 0 {
+		e.v = x
+	} else {
+		e.v = x*e.k + e.v*(1-e.k)
+	}
+	e.count++
+	return e.v
+}
+
+func (e *EMA) Value() float64 { return e.v }
+func (e *EMA) Ready() bool    { return e.count >= e.n }
+func (e *EMA) Warmup() int    { return e.n }
@@ -0,0 +1,56 @@
+package indicators
+
+import "tradebot/internal/core"
+
+// MACD is the difference of a fast and a slow EMA of closes, with an EMA of
+// that line as its signal.
+type MACD struct {
+	fast, slow, signal *EMA
+	line               float64
+}
+
+func NewMACD(fast, slow, signal int) *MACD {
+	return &MACD{fast: NewEMA(fast), slow: NewEMA(slow), signal: NewEMA(signal)}
+}
+
+func (m *MACD) Update(kl core.Kline) float64 {
+	m.line = m.fast.Update(kl) - m.slow.Update(kl)
+	m.signal.Add(m.line)
+	return m.line
+}
+
+func (m *MACD) Value() float64     { return m.line }
+func (m *MACD) Signal() float64    { return m.signal.Value() }
+func (m *MACD) Histogram() float64 { return m.line - m.signal.Value() }
+func (m *MACD) Ready() bool        { return m.signal.count >= m.Warmup() }
+func (m *MACD) Warmup() int {
+	return max(m.fast.Warmup(), m.slow.Warmup()) + m.signal.Warmup() - 1
+}
+
+// Stochastic oscillator: %K places the close within the range of the last n
+// bars (0..100), smoothed by an SMA of smooth bars; %D is an SMA of %K over
+// d bars.
+type Stochastic struct {
+	hi, lo *extreme
+	k, d   *SMA
+}
+
+func NewStochastic(n, smooth, d int) *Stochastic {
+	return &Stochastic{hi: newExtreme(n, true), lo: newExtreme(n, false), k: NewSMA(smooth), d: NewSMA(d)}
+}
+
+func (s *Stochastic) Update(kl core.Kline) float64 {
+	hi, lo := s.hi.add(kl.High), s.lo.add(kl.Low)
+	raw := 50.0
+	if hi > lo {
+		raw = 100 * (kl.Close - lo) / (hi - lo)
+	}
+	s.d.Add(s.k.Add(raw))
+	return s.k.Value()
+}
+
+func (s *Stochastic) Value() float64 { return s.k.Value() }
+func (s *Stochastic) K() float64     { return s.k.Value() }
+func (s *Stochastic) D() float64     { return s.d.Value() }
+func (s *Stochastic) Ready() bool    { return s.hi.i >= s.Warmup() }
+func (s *Stochastic) Warmup() int    { return s.hi.n + s.k.Warmup() + s.d.Warmup() - 2 }
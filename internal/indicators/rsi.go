@@ -0,0 +1,64 @@
+package indicators
+
+import "tradebot/internal/core"
+
+// RSI is Wilder's relative strength index of closes: average gain and loss
+// are seeded with the simple mean of the first n changes and smoothed with
+// alpha 1/n after that. It is ready after n+1 closes.
+type RSI struct {
+	n          int
+	prev       float64
+	count      int // closes seen
+	gain, loss float64
+	v          float64
+}
+
+func NewRSI(n int) *RSI {
+	if n < 2 {
+		n = 2
+	}
+	return &RSI{n: n}
+}
+
+func (r *RSI) Update(kl core.Kline) float64 { return r.Add(kl.Close) }
+
+// Add feeds a raw value.
+func (r *RSI) Add(x float64) float64 {
+	r.count++
+	if r.count == 1 {
+		r.prev = x
+		return 0
+	}
+	g, l := 0.0, 0.0
+	if d := x - r.prev; d >= 0 {
+		g = d
+	} else {
+		l = -d
+	}
+	r.prev = x
+	n := float64(r.n)
+	switch {
+	case r.count <= r.n+1:
+		r.gain += g / n
+		r.loss += l / n
+		if r.count < r.n+1 {
+			return 0
+		}
+	default:
+		r.gain = (r.gain*(n-1) + g) / n
+		r.loss = (r.loss*(n-1) + l) / n
+	}
+	switch {
+	case r.loss == 0 && r.gain == 0:
+		r.v = 50
+	case r.loss == 0:
+		r.v = 100
+	default:
+		r.v = 100 - 100/(1+r.gain/r.loss)
+	}
+	return r.v
+}
+
+func (r *RSI) Value() float64 { return r.v }
+func (r *RSI) Ready() bool    { return r.count > r.n }
+func (r *RSI) Warmup() int    { return r.n + 1 }
@@ -0,0 +1,124 @@
+package indicators
+
+import (
+	"math"
+
+	"tradebot/internal/core"
+)
+
+// StdDev is the population standard deviation of the last n closes.
+type StdDev struct {
+	n          int
+	win        *Ring
+	sum, sumSq float64
+}
+
+func NewStdDev(n int) *StdDev {
+	if n < 1 {
+		n = 1
+	}
+	return &StdDev{n: n, win: NewRing(n)}
+}
+
+func (s *StdDev) Update(kl core.Kline) float64 { return s.Add(kl.Close) }
+
+// Add feeds a raw value.
+func (s *StdDev) Add(x float64) float64 {
+	if old, ok := s.win.Push(x); ok {
+		s.sum -= old
+		s.sumSq -= old * old
+	}
+	s.sum += x
+	s.sumSq += x * x
+	return s.Value()
+}
+
+// Mean is the mean of the window.
+func (s *StdDev) Mean() float64 {
+	if s.win.Len() == 0 {
+		return 0
+	}
+	return s.sum / float64(s.win.Len())
+}
+
+func (s *StdDev) Value() float64 {
+	if s.win.Len() == 0 {
+		return 0
+	}
+	m := s.Mean()
+	return math.Sqrt(math.Max(s.sumSq/float64(s.win.Len())-m*m, 0)) // rounding can go below 0
+}
+
+func (s *StdDev) Ready() bool { return s.win.Full() }
+func (s *StdDev) Warmup() int { return s.n }
+
+// ZScore is how many standard deviations the latest close is from the mean
+// of the last n; 0 when they are all equal.
+type ZScore struct {
+	sd *StdDev
+	v  float64
+}
+
+func NewZScore(n int) *ZScore { return &ZScore{sd: NewStdDev(n)} }
+
+func (z *ZScore) Update(kl core.Kline) float64 { return z.Add(kl.Close) }
+
+// Add feeds a raw value.
+func (z *ZScore) Add(x float64) float64 {
+	z.v = 0
+	if sd := z.sd.Add(x); sd > 0 {
+		z.v = (x - z.sd.Mean()) / sd
+	}
+	return z.v
+}
+
+func (z *ZScore) Value() float64 { return z.v }
+func (z *ZScore) Ready() bool    { return z.sd.Ready() }
+func (z *ZScore) Warmup() int    { return z.sd.Warmup() }
+
+// extreme tracks the maximum (or minimum) of the last n values with a
+// monotonic queue: amortized O(1) per value, at most n values held (append
+// reallocates from the live part once the popped front used up capacity).
+type extreme struct {
+	n   int
+	max bool
+	i   int // values seen
+	q   []extremePoint
+}
+
+type extremePoint struct {
+	i int
+	v float64
+}
+
+func newExtreme(n int, max bool) *extreme {
+	if n < 1 {
+		n = 1
+	}
+	return &extreme{n: n, max: max, q: make([]extremePoint, 0, n)}
+}
+
+func (e *extreme) add(v float64) float64 {
+	for len(e.q) > 0 {
+		last := e.q[len(e.q)-1].v
+		if e.max && last > v || !e.max && last < v {
+			break
+		}
+		e.q = e.q[:len(e.q)-1]
+	}
+	e.q = append(e.q, extremePoint{e.i, v})
+	if e.q[0].i <= e.i-e.n {
+		e.q = e.q[1:]
+	}
+	e.i++
+	return e.q[0].v
+}
+
+func (e *extreme) value() float64 {
+	if len(e.q) == 0 {
+		return 0
+	}
+	return e.q[0].v
+}
+
+func (e *extreme) full() bool { return e.i >= e.n }
@@ -0,0 +1,133 @@
+package indicators
+
+import (
+	"math"
+
+	"tradebot/internal/core"
+)
+
+// ADX is Wilder's average directional index with its +DI and -DI lines.
+// Directional movement and true range are summed over the first n changes
+// and Wilder-smoothed after; ADX averages the first n DX values the same way,
+// so it is ready after 2n bars.
+type ADX struct {
+	n                 int
+	bars              int
+	prev              core.Kline
+	tr, plusDM, minDM float64 // Wilder sums
+	plusDI, minusDI   float64
+	dxSum, adx        float64
+}
+
+func NewADX(n int) *ADX {
+	if n < 1 {
+		n = 1
+	}
+	return &ADX{n: n}
+}
+
+func (a *ADX) Update(kl core.Kline) float64 {
+	a.bars++
+	prev := a.prev
+	a.prev = kl
+	if a.bars == 1 {
+		return 0
+	}
+	up, down := kl.High-prev.High, prev.Low-kl.Low
+	pdm, mdm := 0.0, 0.0
+	if up > down && up > 0 {
+		pdm = up
+	}
+	if down > up && down > 0 {
+		mdm = down
+	}
+	tr := math.Max(kl.High-kl.Low, math.Max(math.Abs(kl.High-prev.Close), math.Abs(kl.Low-prev.Close)))
+	n := float64(a.n)
+	if a.bars <= a.n+1 {
+		a.tr += tr
+		a.plusDM += pdm
+		a.minDM += mdm
+	} else {
+		a.tr += tr - a.tr/n
+		a.plusDM += pdm - a.plusDM/n
+		a.minDM += mdm - a.minDM/n
+	}
+	if a.bars < a.n+1 {
+		return 0
+	}
+	if a.tr > 0 {
+		a.plusDI, a.minusDI = 100*a.plusDM/a.tr, 100*a.minDM/a.tr
+	}
+	dx := 0.0
+	if s := a.plusDI + a.minusDI; s > 0 {
+		dx = 100 * math.Abs(a.plusDI-a.minusDI) / s
+	}
+	switch k := a.bars - a.n; {
+	case k < a.n:
+		a.dxSum += dx
+		a.adx = a.dxSum / float64(k)
+	case k == a.n:
+		a.dxSum += dx
+		a.adx = a.dxSum / n
+	default:
+		a.adx = (a.adx*(n-1) + dx) / n
+	}
+	return a.adx
+}
+
+func (a *ADX) Value() float64   { return a.adx }
+func (a *ADX) PlusDI() float64  { return a.plusDI }
+func (a *ADX) MinusDI() float64 { return a.minusDI }
+func (a *ADX) Ready() bool      { return a.bars >= a.Warmup() }
+func (a *ADX) Warmup() int      { return 2 * a.n }
+
+// SuperTrend trails the price mult ATRs (this package's ATR) from the bar's
+// midpoint and flips when the close crosses it. The line sits below the price
+// in an uptrend and above it in a downtrend.
+type SuperTrend struct {
+	mult         float64
+	atr          *ATR
+	upper, lower float64 // final bands
+	prevClose    float64
+	dir          int
+	bars         int
+}
+
+func NewSuperTrend(n int, mult float64) *SuperTrend {
+	return &SuperTrend{mult: mult, atr: NewATR(n), dir: 1}
+}
+
+func (s *SuperTrend) Update(kl core.Kline) float64 {
+	atr := s.atr.Update(kl)
+	mid := (kl.High + kl.Low) / 2
+	up, lo := mid+s.mult*atr, mid-s.mult*atr
+	if s.bars > 0 {
+		if up > s.upper && s.prevClose <= s.upper {
+			up = s.upper
+		}
+		if lo < s.lower && s.prevClose >= s.lower {
+			lo = s.lower
+		}
+		switch { // against the previous bar's bands
+		case s.dir > 0 && kl.Close < s.lower:
+			s.dir = -1
+		case s.dir < 0 && kl.Close > s.upper:
+			s.dir = 1
+		}
+	}
+	s.upper, s.lower, s.prevClose = up, lo, kl.Close
+	s.bars++
+	return s.Value()
+}
+
+func (s *SuperTrend) Value() float64 {
+	if s.dir > 0 {
+		return s.lower
+	}
+	return s.upper
+}
+
+// Direction is +1 in an uptrend and -1 in a downtrend.
+func (s *SuperTrend) Direction() int { return s.dir }
+func (s *SuperTrend) Ready() bool    { return s.atr.Ready() }
+func (s *SuperTrend) Warmup() int    { return s.atr.Warmup() }
@@ -0,0 +1,74 @@
+package indicators
+
+import (
+	"time"
+
+	"tradebot/internal/core"
+)
+
+// VWAP is the volume-weighted average of the typical price (H+L+C)/3,
+// anchored at the start of each UTC session (a day by default) and reset
+// there. It is ready once a session was followed from its first bar.
+type VWAP struct {
+	session time.Duration
+	anchor  time.Time
+	pv, vol float64
+	v       float64
+	whole   bool
+}
+
+// NewVWAP anchors sessions every session; 0 means a day.
+func NewVWAP(session time.Duration) *VWAP {
+	if session <= 0 {
+		session = 24 * time.Hour
+	}
+	return &VWAP{session: session}
+}
+
+func (w *VWAP) Update(kl core.Kline) float64 {
+	anchor := kl.Ts.UTC().Truncate(w.session)
+	if !anchor.Equal(w.anchor) {
+		w.anchor, w.pv, w.vol = anchor, 0, 0
+		w.whole = w.whole || kl.Ts.Equal(anchor)
+	}
+	tp := (kl.High + kl.Low + kl.Close) / 3
+	w.pv += tp * kl.Vol
+	w.vol += kl.Vol
+	w.v = tp
+	if w.vol > 0 {
+		w.v = w.pv / w.vol
+	}
+	return w.v
+}
+
+func (w *VWAP) Value() float64 { return w.v }
+func (w *VWAP) Ready() bool    { return w.whole }
+
+// Warmup is 1: how many bars complete a session depends on the timeframe.
+func (w *VWAP) Warmup() int { return 1 }
+
+// OBV is on-balance volume: the running sum of volume, added on up closes
+// and subtracted on down closes.
+type OBV struct {
+	prev float64
+	v    float64
+	bars int
+}
+
+func (o *OBV) Update(kl core.Kline) float64 {
+	if o.bars > 0 {
+		switch {
+		case kl.Close > o.prev:
+			o.v += kl.Vol
+		case kl.Close < o.prev:
+			o.v -= kl.Vol
+		}
+	}
+	o.prev = kl.Close
+	o.bars++
+	return o.v
+}
+
+func (o *OBV) Value() float64 { return o.v }
+func (o *OBV) Ready() bool    { return o.bars >= 1 }
+func (o *OBV) Warmup() int    { return 1 }
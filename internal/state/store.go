@@ -3,15 +3,66 @@ package state
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"sync"
+
+	"tradebot/internal/core"
+	"tradebot/internal/strategies"
 )
 
+// Version is the current state file format. Version 1 (files without a
+// version field) held only strategy and feed; version 2 adds the account;
+// version 3 stores strategy parameters by name instead of positional I/F.
+const Version = 3
+
+// StrategyState is a strategy kind and its parameters, validated against the
+// kind's core.Schema when applied. A kind built from a spec (a composite) is
+// stored as its Spec instead (see strategies.Description).
 type StrategyState struct {
-	Type string    `json:"type"`
-	I    []int     `json:"i,omitempty"`
-	F    []float64 `json:"f,omitempty"`
+	Type   string             `json:"type"`
+	Params map[string]float64 `json:"params,omitempty"`
+	Spec   string             `json:"spec,omitempty"`
+
+	// Positional parameters of version <= 2 files, migrated on Load.
+	I []int     `json:"i,omitempty"`
+	F []float64 `json:"f,omitempty"`
+}
+
+// Capture describes strat for persistence; a strategy built outside the
+// registry keeps only its name.
+func Capture(strat core.Strategy) StrategyState {
+	if strat == nil {
+		return StrategyState{}
+	}
+	if d, ok := strategies.Describe(strat); ok {
+		return StrategyState{Type: d.Schema.Kind, Params: d.Params, Spec: d.Spec}
+	}
+	return StrategyState{Type: strat.Name()}
+}
+
+// migrate moves v2 positional parameters into Params.
+func (s *StrategyState) migrate() {
+	if len(s.Params) > 0 || len(s.I)+len(s.F) == 0 {
+		return
+	}
+	names := map[string][2][]string{
+		"ema": {{"fast", "slow", "atr"}, {"R"}},
+		"rsi": {{"len"}, {"overbought", "oversold", "R"}},
+	}[s.Type]
+	s.Params = map[string]float64{}
+	for i, v := range s.I {
+		if i < len(names[0]) {
+			s.Params[names[0][i]] = float64(v)
+		}
+	}
+	for i, v := range s.F {
+		if i < len(names[1]) {
+			s.Params[names[1][i]] = v
+		}
+	}
+	s.I, s.F = nil, nil
 }
 
 type FeedState struct {
@@ -21,13 +72,17 @@ type FeedState struct {
 }
 
 type State struct {
-	Strategy StrategyState `json:"strategy"`
-	Feed     FeedState     `json:"feed"`
+	Version  int                   `json:"version"`
+	Strategy StrategyState         `json:"strategy"`
+	Feed     FeedState             `json:"feed"`
+	Account  *core.AccountSnapshot `json:"account,omitempty"`
+	Trading  string                `json:"trading,omitempty"` // core.TradingState name
 }
 
 func Default() State {
 	return State{
-		Strategy: StrategyState{Type: "ema", I: []int{9, 21, 14}, F: []float64{1.5}},
+		Version:  Version,
+		Strategy: StrategyState{Type: "ema_atr", Params: map[string]float64{"fast": 9, "slow": 21, "atr": 14, "R": 1.5}},
 		Feed:     FeedState{Type: "random"},
 	}
 }
@@ -61,6 +116,13 @@ func (s *Store) Load() (State, error) {
 	if err := json.Unmarshal(data, &st); err != nil {
 		return State{}, err
 	}
+	switch {
+	case st.Version > Version:
+		return State{}, fmt.Errorf("state version %d is newer than supported %d", st.Version, Version)
+	case st.Version <= 1:
+		st.Version = Version // v1 has no account: the engine starts fresh
+	}
+	st.Strategy.migrate()
 	return st, nil
 }
 
@@ -73,6 +135,7 @@ func (s *Store) Save(st State) error {
 	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
 		return err
 	}
+	st.Version = Version
 	data, err := json.MarshalIndent(st, "", "  ")
 	if err != nil {
 		return err
@@ -0,0 +1,39 @@
+package state
+
+import (
+	"testing"
+
+	"tradebot/internal/strategies"
+)
+
+func TestCaptureReflectsTheRunningStrategy(t *testing.T) {
+	s, err := strategies.Build("rsi", strategies.Args(map[string]float64{"len": 7}, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := Capture(s)
+	if got.Type != "rsi" || got.Params["len"] != 7 {
+		t.Fatalf("Capture = %+v, want rsi with len 7", got)
+	}
+	if got := Capture(nil); got.Type != "" {
+		t.Fatalf("Capture(nil) = %+v, want empty", got)
+	}
+}
+
+func TestCaptureStoresACompositeAsItsSpec(t *testing.T) {
+	s, err := strategies.Build("composite", map[string]any{"spec": "filter ema_atr rsi:len=7"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := Capture(s)
+	if got.Type != "composite" || got.Spec == "" || len(got.Params) != 0 {
+		t.Fatalf("Capture = %+v, want composite with its spec", got)
+	}
+	again, err := strategies.Build(got.Type, strategies.Args(got.Params, got.Spec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if re := Capture(again); re.Type != got.Type || re.Spec != got.Spec {
+		t.Errorf("restored %+v, want %+v", re, got)
+	}
+}
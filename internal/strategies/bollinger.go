@@ -0,0 +1,84 @@
+package strategies
+
+import (
+	"fmt"
+
+	"tradebot/internal/core"
+	"tradebot/internal/indicators"
+)
+
+var bollingerSchema = core.Schema{
+	Kind:        "bollinger",
+	Aliases:     []string{"bb"},
+	Description: "Bollinger mean reversion: fade a close through a band, exit at the middle band",
+	Params: []core.Param{
+		{Name: "len", Type: core.ParamInt, Default: 20, Min: 2, Max: 500, Description: "band SMA length"},
+		{Name: "k", Type: core.ParamFloat, Default: 2, Min: 0.5, Max: 5, Description: "band width in standard deviations"},
+		{Name: "atr", Type: core.ParamInt, Default: 14, Min: 1, Max: 500, Description: "ATR length"},
+		{Name: "stop", Type: core.ParamFloat, Default: 2, Min: 0.1, Max: 20, Description: "stop distance in ATRs"},
+	},
+}
+
+func init() {
+	Register(Registration{Schema: bollingerSchema, New: func(p core.Params) (core.Strategy, error) {
+		return NewBollinger(p.Int("len"), p.Float("k"), p.Int("atr"), p.Float("stop")), nil
+	}})
+}
+
+// Bollinger goes long when the close pierces the lower band and short when it
+// pierces the upper one, and closes the position once the close is back at
+// the middle band.
+type Bollinger struct {
+	Len    int
+	K      float64
+	AtrLen int
+	Stop   float64
+
+	bb        *indicators.Bollinger
+	atr       *indicators.ATR
+	prevClose float64
+	prevLo    float64
+	prevHi    float64
+	bars      int
+	name      string
+}
+
+func NewBollinger(length int, k float64, atr int, stop float64) *Bollinger {
+	return &Bollinger{
+		Len: length, K: k, AtrLen: atr, Stop: stop, name: "BOLLINGER",
+		bb: indicators.NewBollinger(length, k), atr: indicators.NewATR(atr),
+	}
+}
+
+func (s *Bollinger) Warmup() int  { return max(s.Len, s.AtrLen) + 1 }
+func (s *Bollinger) Name() string { return s.name }
+
+func (s *Bollinger) Schema() core.Schema { return bollingerSchema }
+func (s *Bollinger) Params() core.Params {
+	return core.Params{"len": float64(s.Len), "k": s.K, "atr": float64(s.AtrLen), "stop": s.Stop}
+}
+
+func (s *Bollinger) OnCandle(sym, tf string, kl core.Kline, acct core.AccountState) (core.Signal, error) {
+	s.bars++
+	s.bb.Update(kl)
+	atrv := s.atr.Update(kl)
+	prevClose, prevLo, prevHi := s.prevClose, s.prevLo, s.prevHi
+	s.prevClose, s.prevLo, s.prevHi = kl.Close, s.bb.Lower(), s.bb.Upper()
+	if s.bars < s.Warmup() {
+		return core.Signal{Action: core.None}, nil
+	}
+
+	last := kl.Close
+	pos := acct.PositionOf(sym)
+	switch {
+	case last < s.bb.Lower() && prevClose >= prevLo && pos.Side != core.Buy:
+		sl := last - s.Stop*atrv
+		return core.Signal{Action: core.Buy, SizePct: 0.02, SL: &sl, Comment: fmt.Sprintf("bb pierce lo %.2f", s.bb.Lower())}, nil
+	case last > s.bb.Upper() && prevClose <= prevHi && pos.Side != core.Sell:
+		sl := last + s.Stop*atrv
+		return core.Signal{Action: core.Sell, SizePct: 0.02, SL: &sl, Comment: fmt.Sprintf("bb pierce hi %.2f", s.bb.Upper())}, nil
+	case pos.Side == core.Buy && last >= s.bb.Mid(), pos.Side == core.Sell && last <= s.bb.Mid():
+		return core.Signal{Action: core.Close, Comment: "bb mid"}, nil
+	}
+	return core.Signal{}, nil
+}
@@ -0,0 +1,599 @@
+package strategies
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+
+	"tradebot/internal/core"
+)
+
+// CompositeKind is the registered kind of Composite. It has no numeric
+// params: Build reads CompositeArgs, the command line and state files its
+// Spec.
+const CompositeKind = "composite"
+
+var compositeSchema = core.Schema{
+	Kind: CompositeKind,
+	Description: "combines registered strategies: [mode] kind[:values,...] kind ...; modes all, any, majority, " +
+		"weighted (kind:weight=w, threshold=t), filter (the last one triggers, the others must agree)",
+	Spec: "mode=all ema_atr rsi",
+}
+
+func init() {
+	Register(Registration{
+		Schema: compositeSchema,
+		Build: func(args map[string]any) (core.Strategy, error) {
+			cfg, err := CompositeArgs(args)
+			if err != nil {
+				return nil, err
+			}
+			return NewComposite(cfg)
+		},
+		Spec: func(s core.Strategy) (string, bool) {
+			c, ok := s.(*Composite)
+			if !ok {
+				return "", false
+			}
+			return c.Spec(), true
+		},
+		ParseArgs: func(args []string) (map[string]any, error) {
+			return map[string]any{"spec": strings.Join(args, " ")}, nil
+		},
+	})
+}
+
+// Composite combination modes.
+const (
+	ModeAll      = "all"      // every child holds the side
+	ModeAny      = "any"      // some child holds the side, none the opposite
+	ModeMajority = "majority" // more than half of the children hold the side
+	ModeWeighted = "weighted" // the weighted vote reaches Threshold
+	ModeFilter   = "filter"   // the last child triggers, the others must agree
+)
+
+var compositeModes = []string{ModeAll, ModeAny, ModeMajority, ModeWeighted, ModeFilter}
+
+// orderDriven kinds trade through resting orders, which a composite does not
+// vote on.
+var orderDriven = map[string]bool{"grid": true, "dca": true}
+
+// CompositeConfig describes a composite: registered child strategies and how
+// their votes combine.
+type CompositeConfig struct {
+	Mode      string
+	Threshold float64 // weighted: share of the total weight needed; 0 = 0.5
+	Children  []ChildConfig
+}
+
+// ChildConfig is one child: a registered kind with Build arguments.
+type ChildConfig struct {
+	Kind   string
+	Args   map[string]any
+	Weight float64 // weighted mode; 0 = 1
+}
+
+// ParseComposite reads the text form used by Telegram, DSL documents and
+// state files: space-separated options and children, e.g.
+//
+//	filter ema_atr rsi:len=14,oversold=25
+//	mode=weighted threshold=0.6 ema_atr:weight=2 rsi bollinger:len=30
+//
+// A child is kind[:args], args being the kind's values positionally or as
+// name=value, comma-separated; weight=w sets its vote weight. A bare mode
+// name stands for mode=name.
+func ParseComposite(spec string) (CompositeConfig, error) {
+	cfg := CompositeConfig{Mode: ModeAll}
+	for _, tok := range strings.Fields(spec) {
+		kind, rest, hasArgs := strings.Cut(tok, ":")
+		if name, val, ok := strings.Cut(tok, "="); ok && !hasArgs {
+			switch strings.ToLower(name) {
+			case "mode":
+				cfg.Mode = strings.ToLower(val)
+			case "threshold":
+				f, err := parseThreshold(val)
+				if err != nil {
+					return cfg, err
+				}
+				cfg.Threshold = f
+			default:
+				return cfg, fmt.Errorf("composite: unknown option %q (want mode, threshold)", name)
+			}
+			continue
+		}
+		if isCompositeMode(tok) {
+			cfg.Mode = strings.ToLower(tok)
+			continue
+		}
+		sc, ok := SchemaOf(kind)
+		if !ok {
+			return cfg, fmt.Errorf("composite: unknown strategy %q", kind)
+		}
+		ch := ChildConfig{Kind: sc.Kind, Args: map[string]any{}, Weight: 1}
+		var vals []string
+		if hasArgs && rest != "" {
+			vals = strings.Split(rest, ",")
+		}
+		for i := 0; i < len(vals); i++ {
+			if name, val, ok := strings.Cut(vals[i], "="); ok && strings.EqualFold(name, "weight") {
+				w, err := parseWeight(sc.Kind, val)
+				if err != nil {
+					return cfg, err
+				}
+				ch.Weight = w
+				vals = append(vals[:i], vals[i+1:]...)
+				i--
+			}
+		}
+		args, err := sc.ParseArgs(vals)
+		if err != nil {
+			return cfg, fmt.Errorf("composite: %w", err)
+		}
+		ch.Args = args
+		cfg.Children = append(cfg.Children, ch)
+	}
+	return cfg, nil
+}
+
+// CompositeArgs reads Build arguments of a composite: "spec" in
+// ParseComposite form, or "mode", "threshold" and "children", a list of
+// objects with "kind", "params" and "weight" (JSON backtest requests).
+func CompositeArgs(args map[string]any) (CompositeConfig, error) {
+	if spec, ok := args["spec"]; ok {
+		return ParseComposite(fmt.Sprint(spec))
+	}
+	var cfg CompositeConfig
+	for k, v := range args {
+		switch strings.ToLower(k) {
+		case "mode":
+			cfg.Mode = fmt.Sprint(v)
+		case "threshold":
+			f, err := parseThreshold(fmt.Sprint(v))
+			if err != nil {
+				return cfg, err
+			}
+			cfg.Threshold = f
+		case "children":
+			list, ok := v.([]any)
+			if !ok {
+				return cfg, fmt.Errorf("composite: children must be a list")
+			}
+			for _, item := range list {
+				m, ok := item.(map[string]any)
+				if !ok {
+					return cfg, fmt.Errorf("composite: a child must be an object with kind, params, weight")
+				}
+				ch := ChildConfig{Kind: fmt.Sprint(m["kind"]), Args: map[string]any{}, Weight: 1}
+				if p, ok := m["params"].(map[string]any); ok {
+					ch.Args = p
+				}
+				if w, ok := m["weight"]; ok {
+					f, err := parseWeight(ch.Kind, fmt.Sprint(w))
+					if err != nil {
+						return cfg, err
+					}
+					ch.Weight = f
+				}
+				cfg.Children = append(cfg.Children, ch)
+			}
+		case "id":
+		default:
+			return cfg, fmt.Errorf("composite: unknown key %q (want spec, or mode, threshold, children)", k)
+		}
+	}
+	return cfg, nil
+}
+
+// parseThreshold reads a threshold given explicitly, where 0 is not taken
+// for the default.
+func parseThreshold(s string) (float64, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("composite: threshold %q is not a number", s)
+	}
+	if f <= 0 || f > 1 {
+		return 0, fmt.Errorf("composite: threshold %g must be in (0, 1]", f)
+	}
+	return f, nil
+}
+
+// parseWeight reads a child weight given explicitly, where 0 is not taken
+// for the default.
+func parseWeight(kind, s string) (float64, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("composite: %s weight %q is not a number", kind, s)
+	}
+	if f <= 0 {
+		return 0, fmt.Errorf("composite: %s weight %g must be positive", kind, f)
+	}
+	return f, nil
+}
+
+func isCompositeMode(s string) bool {
+	for _, m := range compositeModes {
+		if strings.EqualFold(s, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// Composite runs child strategies side by side on every candle and trades
+// their combined vote. A child's stance is the side of its latest entry
+// signal, cleared by its Close: the composite enters when the stances come to
+// agree under Mode, reverses when they agree on the other side, and closes
+// its position when they stop agreeing. In filter mode only a fresh entry of
+// the trigger (the last child) opens a position, and only when every filter
+// holds the same side; the trigger's Close exits, and so does a filter turning
+// against the position.
+//
+// The children see the same account and get the lifecycle hooks they
+// implement, except Timer. An entry takes the size of the agreeing children
+// (their weighted mean), the tightest of their stops and the nearest of their
+// targets; in filter mode the trigger's. Resting orders of children are not
+// voted on, so grid and dca cannot be children.
+type Composite struct {
+	cfg    CompositeConfig
+	kids   []core.Strategy
+	stance []core.Action
+	last   []core.Signal // latest entry signal of each child
+	at     []float64     // close it came on
+	name   string
+
+	mu      sync.Mutex // Streams is called from the feed's StreamMux
+	traded  core.Stream
+	streams []map[core.Stream]bool // extra streams each child reads
+}
+
+func NewComposite(cfg CompositeConfig) (*Composite, error) {
+	cfg.Mode = strings.ToLower(cfg.Mode)
+	if cfg.Mode == "" {
+		cfg.Mode = ModeAll
+	}
+	if !isCompositeMode(cfg.Mode) {
+		return nil, fmt.Errorf("composite: unknown mode %q (want %s)", cfg.Mode, strings.Join(compositeModes, ", "))
+	}
+	if cfg.Threshold == 0 {
+		cfg.Threshold = 0.5
+	}
+	if cfg.Threshold < 0 || cfg.Threshold > 1 {
+		return nil, fmt.Errorf("composite: threshold %g must be in (0, 1]", cfg.Threshold)
+	}
+	if len(cfg.Children) < 2 || len(cfg.Children) > 8 {
+		return nil, fmt.Errorf("composite: need 2 to 8 strategies, got %d", len(cfg.Children))
+	}
+	cfg.Children = append([]ChildConfig(nil), cfg.Children...)
+	c := &Composite{cfg: cfg, name: "COMPOSITE"}
+	for i, ch := range cfg.Children {
+		r, ok := Lookup(ch.Kind)
+		if !ok {
+			return nil, fmt.Errorf("composite: unknown strategy %q", ch.Kind)
+		}
+		sc := r.Schema
+		if r.Build != nil {
+			return nil, fmt.Errorf("composite: %s cannot be a child", sc.Kind)
+		}
+		if orderDriven[sc.Kind] {
+			return nil, fmt.Errorf("composite: %s trades resting orders and cannot be combined", sc.Kind)
+		}
+		if ch.Weight == 0 {
+			c.cfg.Children[i].Weight = 1
+		}
+		if ch.Weight < 0 {
+			return nil, fmt.Errorf("composite: %s weight must not be negative", sc.Kind)
+		}
+		c.cfg.Children[i].Kind = sc.Kind
+		s, err := Build(sc.Kind, ch.Args)
+		if err != nil {
+			return nil, fmt.Errorf("composite: %w", err)
+		}
+		c.kids = append(c.kids, s)
+	}
+	c.stance = make([]core.Action, len(c.kids))
+	c.last = make([]core.Signal, len(c.kids))
+	c.at = make([]float64, len(c.kids))
+	c.streams = make([]map[core.Stream]bool, len(c.kids))
+	return c, nil
+}
+
+// Warmup is the longest warmup among the children.
+func (c *Composite) Warmup() int {
+	n := 0
+	for _, s := range c.kids {
+		n = max(n, s.Warmup())
+	}
+	return n
+}
+
+func (c *Composite) Name() string { return c.name }
+
+// Config returns the configuration with child kinds and weights resolved.
+func (c *Composite) Config() CompositeConfig { return c.cfg }
+
+// Spec renders the composite in ParseComposite form, children with their
+// current params.
+func (c *Composite) Spec() string {
+	parts := []string{"mode=" + c.cfg.Mode}
+	if c.cfg.Mode == ModeWeighted {
+		parts = append(parts, "threshold="+strconv.FormatFloat(c.cfg.Threshold, 'f', -1, 64))
+	}
+	for i, s := range c.kids {
+		d, _ := Describe(s)
+		vals := strings.Fields(d.String())
+		if w := c.cfg.Children[i].Weight; w != 1 {
+			vals = append(vals, "weight="+strconv.FormatFloat(w, 'f', -1, 64))
+		}
+		parts = append(parts, d.Schema.Kind+":"+strings.Join(vals, ","))
+	}
+	return strings.Join(parts, " ")
+}
+
+// Streams is the union of the children's extra streams; each child then only
+// gets the bars of the streams it asked for, besides the traded one.
+func (c *Composite) Streams(sym, tf string) []core.Stream {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.traded = core.Stream{Symbol: sym, TF: tf}
+	var out []core.Stream
+	for i, s := range c.kids {
+		mt, ok := s.(core.MultiTimeframe)
+		if !ok {
+			continue
+		}
+		c.streams[i] = map[core.Stream]bool{}
+		for _, st := range mt.Streams(sym, tf) {
+			if st.Symbol == "" {
+				st.Symbol = sym
+			}
+			c.streams[i][st] = true
+			out = append(out, st)
+		}
+	}
+	return out
+}
+
+func (c *Composite) OnCandle(sym, tf string, kl core.Kline, acct core.AccountState) (core.Signal, error) {
+	if readers, extra := c.readers(core.Stream{Symbol: sym, TF: tf}); extra {
+		// an extra stream: only informs the children that read it
+		for i, s := range c.kids {
+			if readers[i] {
+				if _, err := s.OnCandle(sym, tf, kl, acct); err != nil {
+					return core.Signal{}, fmt.Errorf("%s: %w", s.Name(), err)
+				}
+			}
+		}
+		return core.Signal{Action: core.None}, nil
+	}
+	before := c.combined()
+	var fired, closed bool // by the trigger, the last child
+	for i, s := range c.kids {
+		sig, err := s.OnCandle(sym, tf, kl, acct)
+		if err != nil {
+			return core.Signal{}, fmt.Errorf("%s: %w", s.Name(), err)
+		}
+		switch sig.Action {
+		case core.Buy, core.Sell:
+			c.stance[i], c.last[i], c.at[i] = sig.Action, sig, kl.Close
+			fired = i == len(c.kids)-1
+		case core.Close:
+			c.stance[i] = core.None
+			closed = i == len(c.kids)-1
+		}
+	}
+	pos := acct.PositionOf(sym).Side
+	if c.cfg.Mode == ModeFilter {
+		return c.filter(pos, fired, closed), nil
+	}
+	switch now := c.combined(); {
+	case now != core.None && now != before && now != pos:
+		return c.entry(now, kl.Close), nil
+	case now == core.None && before != core.None && pos != core.None:
+		return core.Signal{Action: core.Close, Comment: "composite " + c.cfg.Mode + ": no agreement"}, nil
+	}
+	return core.Signal{Action: core.None}, nil
+}
+
+// readers reports whether st is an extra stream, and which children read it.
+func (c *Composite) readers(st core.Stream) ([]bool, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.traded.TF == "" || st == c.traded {
+		return nil, false
+	}
+	out := make([]bool, len(c.kids))
+	for i := range c.kids {
+		out[i] = c.streams[i][st]
+	}
+	return out, true
+}
+
+// filter trades the trigger's fresh entries that every filter agrees with.
+func (c *Composite) filter(pos core.Action, fired, closed bool) core.Signal {
+	n := len(c.kids) - 1
+	agree := func(side core.Action) bool {
+		for i := 0; i < n; i++ {
+			if c.stance[i] != side {
+				return false
+			}
+		}
+		return true
+	}
+	trigger := c.stance[n]
+	if fired && trigger != pos && agree(trigger) {
+		sig := c.last[n]
+		sig.Comment = "composite filter: " + sig.Comment
+		return sig
+	}
+	if pos != core.None && (closed || fired && trigger != pos || !agree(pos)) {
+		return core.Signal{Action: core.Close, Comment: "composite filter: exit"}
+	}
+	return core.Signal{Action: core.None}
+}
+
+// combined applies Mode to the stances.
+func (c *Composite) combined() core.Action {
+	var buy, sell, wBuy, wSell, wAll float64
+	for i, a := range c.stance {
+		w := c.cfg.Children[i].Weight
+		wAll += w
+		switch a {
+		case core.Buy:
+			buy++
+			wBuy += w
+		case core.Sell:
+			sell++
+			wSell += w
+		}
+	}
+	n := float64(len(c.stance))
+	switch c.cfg.Mode {
+	case ModeAll:
+		return pick(buy == n, sell == n)
+	case ModeAny:
+		return pick(buy > 0 && sell == 0, sell > 0 && buy == 0)
+	case ModeMajority:
+		return pick(2*buy > n, 2*sell > n)
+	case ModeWeighted:
+		if wAll <= 0 {
+			return core.None
+		}
+		score := (wBuy - wSell) / wAll
+		return pick(score >= c.cfg.Threshold, -score >= c.cfg.Threshold)
+	}
+	return core.None
+}
+
+func pick(buy, sell bool) core.Action {
+	switch {
+	case buy && !sell:
+		return core.Buy
+	case sell && !buy:
+		return core.Sell
+	}
+	return core.None
+}
+
+// entry merges the latest entry signals of the children holding side. The
+// levels of a signal from an earlier candle keep their distance from px.
+func (c *Composite) entry(side core.Action, px float64) core.Signal {
+	sig := core.Signal{Action: side}
+	var size, wsize, wsum float64
+	var names []string
+	for i, a := range c.stance {
+		if a != side {
+			continue
+		}
+		last := c.last[i]
+		w := c.cfg.Children[i].Weight
+		size += last.SizePct
+		wsize += w * last.SizePct
+		wsum += w
+		names = append(names, c.kids[i].Name())
+		shift := px - c.at[i]
+		sig.SL = nearer(side, sig.SL, moved(last.SL, shift), true)
+		sig.TP = nearer(side, sig.TP, moved(last.TP, shift), false)
+	}
+	sig.SizePct = size / float64(len(names))
+	if wsum > 0 {
+		sig.SizePct = wsize / wsum
+	}
+	sig.Comment = fmt.Sprintf("composite %s: %s", c.cfg.Mode, strings.Join(names, "+"))
+	return sig
+}
+
+func moved(l *float64, d float64) *float64 {
+	if l == nil {
+		return nil
+	}
+	v := *l + d
+	return &v
+}
+
+// nearer returns the level of a and b closer to the entry: for a long the
+// higher stop or the lower target, for a short the reverse.
+func nearer(side core.Action, a, b *float64, stop bool) *float64 {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	v := math.Min(*a, *b)
+	if (side == core.Buy) == stop {
+		v = math.Max(*a, *b)
+	}
+	return &v
+}
+
+func (c *Composite) OnStart(acct core.AccountState) error {
+	var errs []error
+	for _, s := range c.kids {
+		if st, ok := s.(core.Starter); ok {
+			if err := st.OnStart(acct); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", s.Name(), err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c *Composite) OnStop(acct core.AccountState) {
+	for _, s := range c.kids {
+		if st, ok := s.(core.Stopper); ok {
+			st.OnStop(acct)
+		}
+	}
+}
+
+func (c *Composite) OnFill(ev core.TradeEvent, acct core.AccountState) {
+	for _, s := range c.kids {
+		if f, ok := s.(core.FillObserver); ok {
+			f.OnFill(ev, acct)
+		}
+	}
+}
+
+func (c *Composite) OnRisk(d core.RiskDecision) {
+	for _, s := range c.kids {
+		if o, ok := s.(core.RiskObserver); ok {
+			o.OnRisk(d)
+		}
+	}
+}
+
+// CompositeChild is one child in CompositeStatus.
+type CompositeChild struct {
+	Name   string  `json:"name"`
+	Kind   string  `json:"kind"`
+	Weight float64 `json:"weight"`
+	Stance string  `json:"stance"` // "long" | "short" | "flat"
+	Status any     `json:"status,omitempty"`
+}
+
+// CompositeStatus is the composite's core.StatusReporter snapshot.
+type CompositeStatus struct {
+	Kind     string           `json:"kind"`
+	Mode     string           `json:"mode"`
+	Combined string           `json:"combined"`
+	Children []CompositeChild `json:"children"`
+}
+
+func (c *Composite) Status() any {
+	stance := map[core.Action]string{core.Buy: "long", core.Sell: "short", core.None: "flat"}
+	st := CompositeStatus{Kind: "composite", Mode: c.cfg.Mode, Combined: stance[c.combined()]}
+	if c.cfg.Mode == ModeFilter {
+		st.Combined = ""
+	}
+	for i, s := range c.kids {
+		ch := CompositeChild{Name: s.Name(), Kind: c.cfg.Children[i].Kind, Weight: c.cfg.Children[i].Weight, Stance: stance[c.stance[i]]}
+		if r, ok := s.(core.StatusReporter); ok {
+			ch.Status = r.Status()
+		}
+		st.Children = append(st.Children, ch)
+	}
+	return st
+}
@@ -0,0 +1,169 @@
+package strategies
+
+import (
+	"strings"
+	"testing"
+
+	"tradebot/internal/core"
+)
+
+// upBar buys a candle that rises by at least Min and closes on any other.
+type upBar struct{ Min float64 }
+
+var upBarSchema = core.Schema{Kind: "test_up", Params: []core.Param{
+	{Name: "min", Type: core.ParamFloat, Default: 1, Min: 0, Max: 100},
+}}
+
+func init() {
+	Register(Registration{Schema: upBarSchema, New: func(p core.Params) (core.Strategy, error) {
+		return &upBar{Min: p.Float("min")}, nil
+	}})
+}
+
+func (s *upBar) OnCandle(sym, tf string, kl core.Kline, acct core.AccountState) (core.Signal, error) {
+	if kl.Close-kl.Open >= s.Min {
+		return core.Signal{Action: core.Buy, SizePct: 0.1, Comment: "up"}, nil
+	}
+	return core.Signal{Action: core.Close}, nil
+}
+func (s *upBar) Warmup() int         { return 0 }
+func (s *upBar) Name() string        { return "UP" }
+func (s *upBar) Schema() core.Schema { return upBarSchema }
+func (s *upBar) Params() core.Params { return core.Params{"min": s.Min} }
+
+func buildComposite(t *testing.T, spec string) *Composite {
+	t.Helper()
+	s, err := Build("composite", map[string]any{"spec": spec})
+	if err != nil {
+		t.Fatalf("Build(%q): %v", spec, err)
+	}
+	return s.(*Composite)
+}
+
+func TestCompositeVotes(t *testing.T) {
+	// a +3 bar: the min=1 child buys, the min=5 child closes
+	for _, tc := range []struct {
+		spec string
+		want core.Action
+	}{
+		{"all test_up:1 test_up:5", core.None},
+		{"any test_up:1 test_up:5", core.Buy},
+		{"majority test_up:1 test_up:5", core.None},
+		{"majority test_up:1 test_up:2 test_up:5", core.Buy},
+		{"weighted test_up:1,weight=2 test_up:5", core.Buy},
+		{"weighted threshold=0.7 test_up:1,weight=2 test_up:5", core.None},
+	} {
+		c := buildComposite(t, tc.spec)
+		sig, err := c.OnCandle("X", "1h", bar(0, 100, 103, 100, 103), core.AccountState{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sig.Action != tc.want {
+			t.Errorf("%s: action %v, want %v", tc.spec, sig.Action, tc.want)
+		}
+	}
+}
+
+func TestCompositeFilterNeedsEveryFilter(t *testing.T) {
+	c := buildComposite(t, "filter test_up:5 test_up:1")
+	if sig, _ := c.OnCandle("X", "1h", bar(0, 100, 103, 100, 103), core.AccountState{}); sig.Action != core.None {
+		t.Errorf("trigger against the filter: action %v, want none", sig.Action)
+	}
+	sig, _ := c.OnCandle("X", "1h", bar(1, 100, 106, 100, 106), core.AccountState{})
+	if sig.Action != core.Buy || !strings.HasPrefix(sig.Comment, "composite filter") {
+		t.Errorf("trigger with the filter: %+v, want the trigger's buy", sig)
+	}
+}
+
+func TestCompositeThreshold(t *testing.T) {
+	if c := buildComposite(t, "weighted test_up test_up"); c.Config().Threshold != 0.5 {
+		t.Errorf("default threshold = %v, want 0.5", c.Config().Threshold)
+	}
+	for _, args := range []map[string]any{
+		{"spec": "weighted threshold=0 test_up test_up"},
+		{"spec": "weighted threshold=1.5 test_up test_up"},
+		{"mode": "weighted", "threshold": 0.0, "children": []any{
+			map[string]any{"kind": "test_up"}, map[string]any{"kind": "test_up"},
+		}},
+	} {
+		if _, err := Build("composite", args); err == nil || !strings.Contains(err.Error(), "threshold") {
+			t.Errorf("Build(%v) error = %v, want a threshold error", args, err)
+		}
+	}
+}
+
+func TestCompositeWeight(t *testing.T) {
+	for _, args := range []map[string]any{
+		{"spec": "weighted test_up test_up"},
+		{"mode": "weighted", "children": []any{
+			map[string]any{"kind": "test_up"}, map[string]any{"kind": "test_up"},
+		}},
+	} {
+		c, err := Build("composite", args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, ch := range c.(*Composite).Config().Children {
+			if ch.Weight != 1 {
+				t.Errorf("Build(%v): default weight = %v, want 1", args, ch.Weight)
+			}
+		}
+	}
+	for _, args := range []map[string]any{
+		{"spec": "weighted test_up:weight=0 test_up"},
+		{"spec": "weighted test_up:1,weight=-2 test_up"},
+		{"mode": "weighted", "children": []any{
+			map[string]any{"kind": "test_up", "weight": 0.0}, map[string]any{"kind": "test_up"},
+		}},
+	} {
+		if _, err := Build("composite", args); err == nil || !strings.Contains(err.Error(), "weight") {
+			t.Errorf("Build(%v) error = %v, want a weight error", args, err)
+		}
+	}
+}
+
+func TestCompositeRejectsChildren(t *testing.T) {
+	for _, spec := range []string{"test_up", "test_up grid", "test_up composite", "test_up nope"} {
+		if _, err := Build("composite", map[string]any{"spec": spec}); err == nil {
+			t.Errorf("Build(%q) succeeded, want an error", spec)
+		}
+	}
+}
+
+func TestCompositeIsRegistered(t *testing.T) {
+	r, ok := Lookup("Composite")
+	if !ok || r.Schema.Spec == "" {
+		t.Fatalf("composite registration = %+v, want one with a default spec", r)
+	}
+	found := false
+	for _, sc := range Schemas() {
+		found = found || sc.Kind == CompositeKind
+	}
+	if !found {
+		t.Error("Schemas() lacks composite")
+	}
+	if _, err := Build(CompositeKind, map[string]any{"spec": r.Schema.Spec}); err != nil {
+		t.Errorf("default spec %q: %v", r.Schema.Spec, err)
+	}
+
+	args, err := r.Args(strings.Fields("weighted threshold=0.6 test_up:2,weight=3 test_up"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := Build(CompositeKind, args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, ok := Describe(s)
+	want := "mode=weighted threshold=0.6 test_up:min=2,weight=3 test_up:min=1"
+	if !ok || d.Schema.Kind != CompositeKind || d.Spec != want || d.String() != want {
+		t.Fatalf("Describe = %+v, want composite %q", d, want)
+	}
+	again, err := Build(d.Schema.Kind, d.Args())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := again.(*Composite).Spec(); got != want {
+		t.Errorf("rebuilt spec = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,227 @@
+package strategies
+
+import (
+	"fmt"
+	"math"
+
+	"tradebot/internal/core"
+	"tradebot/internal/indicators"
+)
+
+var dcaSchema = core.Schema{
+	Kind:        "dca",
+	Description: "DCA bot: a base buy, safety buys further down to average the entry, take-profit from the average",
+	Params: []core.Param{
+		{Name: "base", Type: core.ParamFloat, Default: 0.01, Min: 0.001, Max: 1, Description: "base order, share of equity"},
+		{Name: "safety", Type: core.ParamInt, Default: 5, Min: 0, Max: 20, Description: "safety orders per deal"},
+		{Name: "safety_size", Type: core.ParamFloat, Default: 0.01, Min: 0.001, Max: 1, Description: "first safety order, share of equity"},
+		{Name: "deviation", Type: core.ParamFloat, Default: 0.01, Min: 0.001, Max: 0.5, Description: "drop from the base price to the first safety order"},
+		{Name: "step_scale", Type: core.ParamFloat, Default: 1, Min: 0.5, Max: 3, Description: "each next gap between safety orders is this many times the previous"},
+		{Name: "volume_scale", Type: core.ParamFloat, Default: 1.5, Min: 0.5, Max: 3, Description: "each next safety order is this many times the previous"},
+		{Name: "tp", Type: core.ParamFloat, Default: 0.015, Min: 0.001, Max: 1, Description: "take-profit above the averaged entry"},
+		{Name: "trailing", Type: core.ParamFloat, Default: 0, Min: 0, Max: 0.2, Description: "trail the take-profit: exit on this pullback from the high once it is reached; 0 = off"},
+		{Name: "trigger_rsi", Type: core.ParamFloat, Default: 0, Min: 0, Max: 100, Description: "start a deal when RSI(14) is at or below; 0 = at once"},
+	},
+}
+
+func init() {
+	Register(Registration{Schema: dcaSchema, New: func(p core.Params) (core.Strategy, error) {
+		if n, dev, ss := p.Int("safety"), p.Float("deviation"), p.Float("step_scale"); dcaDrop(dev, ss, n) >= 1 {
+			return nil, fmt.Errorf("dca: %d safety orders at deviation %g, step_scale %g reach below zero", n, dev, ss)
+		}
+		return NewDCA(p.Float("base"), p.Int("safety"), p.Float("safety_size"), p.Float("deviation"), p.Float("step_scale"),
+			p.Float("volume_scale"), p.Float("tp"), p.Float("trailing"), p.Float("trigger_rsi")), nil
+	}})
+}
+
+// DCA runs long deals. A deal opens with a market base order once the trigger
+// fires; then safety orders rest below the base price, each deeper and larger
+// than the last, and every one that fills lowers the averaged entry. The deal
+// closes at TP above the averaged entry: a resting limit sell, or with
+// Trailing a market exit once the price pulls back Trailing from its high
+// after reaching the target.
+//
+// Safety orders are core.Order AddOnly, so the engine drops them as soon as
+// the deal closes; the engine reports the whole cycle as one core.Deal. The
+// deal's orders are re-laid from the position as soon as the base or a safety
+// order fills (core.Relayer), so the take-profit always covers the whole
+// position. The strategy owns the symbol's orders.
+type DCA struct {
+	Base        float64
+	Safety      int
+	SafetySize  float64
+	Deviation   float64
+	StepScale   float64
+	VolumeScale float64
+	TP          float64
+	Trailing    float64
+	TriggerRSI  float64
+
+	rsi    *indicators.RSI
+	bars   int
+	active bool    // a deal is open
+	basePx float64 // fill price of the base order
+	filled int     // safety orders filled this deal
+	peak   float64 // trailing: high since the target was reached; 0 = not armed
+	deals  int
+	profit float64
+	avg    float64
+	last   float64
+	name   string
+}
+
+func NewDCA(base float64, safety int, safetySize, deviation, stepScale, volumeScale, tp, trailing, triggerRSI float64) *DCA {
+	return &DCA{
+		Base: base, Safety: safety, SafetySize: safetySize, Deviation: deviation, StepScale: stepScale,
+		VolumeScale: volumeScale, TP: tp, Trailing: trailing, TriggerRSI: triggerRSI,
+		rsi: indicators.NewRSI(14), name: "DCA",
+	}
+}
+
+func (s *DCA) Warmup() int {
+	if s.TriggerRSI > 0 {
+		return s.rsi.Warmup()
+	}
+	return 1
+}
+func (s *DCA) Name() string { return s.name }
+
+func (s *DCA) Schema() core.Schema { return dcaSchema }
+func (s *DCA) Params() core.Params {
+	return core.Params{
+		"base": s.Base, "safety": float64(s.Safety), "safety_size": s.SafetySize, "deviation": s.Deviation,
+		"step_scale": s.StepScale, "volume_scale": s.VolumeScale, "tp": s.TP, "trailing": s.Trailing, "trigger_rsi": s.TriggerRSI,
+	}
+}
+
+func (s *DCA) OnCandle(sym, tf string, kl core.Kline, acct core.AccountState) (core.Signal, error) {
+	s.bars++
+	s.last = kl.Close
+	r := s.rsi.Update(kl)
+	pos := acct.PositionOf(sym)
+	if pos.Side != core.Buy {
+		// flat: whatever is left of the last deal goes
+		sig := core.Signal{Action: core.None, Cancel: s.active}
+		s.active, s.basePx, s.filled, s.peak, s.avg = false, 0, 0, 0, 0
+		if pos.Side == core.None && s.bars >= s.Warmup() && (s.TriggerRSI <= 0 || r <= s.TriggerRSI) {
+			sig.Action, sig.SizePct, sig.Comment = core.Buy, s.Base, "dca base"
+		}
+		return sig, nil
+	}
+	if !s.active { // a position the bot did not open: adopt it
+		return s.Relay(sym, acct), nil
+	}
+	if s.Trailing > 0 {
+		target := pos.Entry * (1 + s.TP)
+		if s.peak == 0 && kl.High >= target {
+			s.peak = kl.High
+		}
+		if s.peak > 0 {
+			s.peak = math.Max(s.peak, kl.High)
+			if kl.Close <= s.peak*(1-s.Trailing) {
+				return core.Signal{Action: core.Close, Cancel: true, Comment: "dca trailing tp"}, nil
+			}
+		}
+	}
+	return core.Signal{Action: core.None}, nil
+}
+
+// Relay re-lays the deal's orders after a fill: the take-profit for the whole
+// position at its new average, and the safety orders not filled yet. Once the
+// deal is closed it cancels what is left.
+func (s *DCA) Relay(sym string, acct core.AccountState) core.Signal {
+	pos := acct.PositionOf(sym)
+	if pos.Side != core.Buy {
+		sig := core.Signal{Cancel: s.active}
+		s.active, s.basePx, s.filled, s.peak, s.avg = false, 0, 0, 0, 0
+		return sig
+	}
+	if !s.active {
+		s.active, s.basePx = true, pos.Entry
+	}
+	s.avg = pos.Entry
+	var orders []core.Order
+	if s.Trailing <= 0 {
+		// first, so it matches before a safety order on a candle that spans both
+		orders = append(orders, core.Order{
+			Side: core.Sell, Leg: core.LegLong, Type: core.Limit, Qty: pos.Qty, Price: pos.Entry * (1 + s.TP),
+			Tag: "dca/tp", Comment: "dca tp",
+		})
+	}
+	for k := s.filled + 1; k <= s.Safety; k++ {
+		orders = append(orders, core.Order{
+			Side: core.Buy, Type: core.Limit, SizePct: s.safetySize(k), Price: s.safetyPrice(k), AddOnly: true,
+			Tag: fmt.Sprintf("dca/so/%d", k), Comment: fmt.Sprintf("dca so %d", k),
+		})
+	}
+	return core.Signal{Action: core.None, Cancel: true, Orders: orders, Comment: "dca"}
+}
+
+// OnFill tracks the deal: its base price, safety orders and result.
+func (s *DCA) OnFill(ev core.TradeEvent, acct core.AccountState) {
+	if core.IsExitEvent(ev.Event) {
+		s.profit += ev.PnL
+		if acct.PositionOf(ev.Symbol).Side == core.None {
+			s.deals++
+		}
+		return
+	}
+	if ev.Side != core.Buy {
+		return
+	}
+	var k int
+	if _, err := fmt.Sscanf(ev.OrderTag, "dca/so/%d", &k); err == nil {
+		s.filled = max(s.filled, k)
+		return
+	}
+	if ev.Event == "OPEN" {
+		s.active, s.basePx, s.filled, s.peak = true, ev.Price, 0, 0
+	}
+}
+
+// safetyPrice is where safety order k (from 1) rests.
+func (s *DCA) safetyPrice(k int) float64 {
+	return s.basePx * (1 - dcaDrop(s.Deviation, s.StepScale, k))
+}
+
+func (s *DCA) safetySize(k int) float64 {
+	return s.SafetySize * math.Pow(s.VolumeScale, float64(k-1))
+}
+
+// dcaDrop is the drop from the base price to safety order k: the first gap
+// is dev, each next one stepScale times the previous.
+func dcaDrop(dev, stepScale float64, k int) float64 {
+	d, gap := 0.0, dev
+	for i := 0; i < k; i++ {
+		d += gap
+		gap *= stepScale
+	}
+	return d
+}
+
+// DCAStatus is the DCA bot's core.StatusReporter snapshot.
+type DCAStatus struct {
+	Kind       string  `json:"kind"`
+	Active     bool    `json:"active"`
+	BasePrice  float64 `json:"basePrice,omitempty"`
+	AvgEntry   float64 `json:"avgEntry,omitempty"`
+	Target     float64 `json:"target,omitempty"`
+	Filled     int     `json:"safetyFilled"`
+	Safety     int     `json:"safety"`
+	NextSafety float64 `json:"nextSafety,omitempty"` // price of the next safety order
+	Trailing   float64 `json:"trailingPeak,omitempty"`
+	Deals      int     `json:"deals"`
+	Profit     float64 `json:"profit"` // realized by the bot's exits, fees excluded
+	Last       float64 `json:"last"`
+}
+
+func (s *DCA) Status() any {
+	st := DCAStatus{Kind: "dca", Active: s.active, Filled: s.filled, Safety: s.Safety, Trailing: s.peak, Deals: s.deals, Profit: s.profit, Last: s.last}
+	if s.active {
+		st.BasePrice, st.AvgEntry, st.Target = s.basePx, s.avg, s.avg*(1+s.TP)
+		if s.filled < s.Safety {
+			st.NextSafety = s.safetyPrice(s.filled + 1)
+		}
+	}
+	return st
+}
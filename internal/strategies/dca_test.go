@@ -0,0 +1,115 @@
+package strategies
+
+import (
+	"testing"
+	"time"
+
+	"tradebot/internal/core"
+)
+
+// a DCA deal: base 100 USD at 100, safety orders at 90 and 80, TP 5%
+func newDCATest(t *testing.T) (*DCA, *core.Engine, *[]core.TradeEvent) {
+	t.Helper()
+	return newDCAOn(t, core.OneWay)
+}
+
+func newDCAOn(t *testing.T, mode core.AccountMode) (*DCA, *core.Engine, *[]core.TradeEvent) {
+	t.Helper()
+	s := NewDCA(0.01, 2, 0.01, 0.1, 1, 1, 0.05, 0, 0)
+	e, fills := newEngine(t, core.EngineOpts{AccountMode: mode}, s)
+	return s, e, fills
+}
+
+func tpOrder(t *testing.T, e *core.Engine) core.Order {
+	t.Helper()
+	for _, o := range e.Orders("X") {
+		if o.Tag == "dca/tp" {
+			return o
+		}
+	}
+	t.Fatalf("no take-profit among %+v", e.Orders("X"))
+	return core.Order{}
+}
+
+func TestDCALaysOrdersOnTheBaseFill(t *testing.T) {
+	_, e, _ := newDCATest(t)
+	feed(t, e, bar(0, 100, 100, 100, 100))
+	pos := e.Snapshot().Position
+	if pos.Side != core.Buy {
+		t.Fatalf("position = %+v, want the base buy", pos)
+	}
+	if tp := tpOrder(t, e); !near(tp.Qty, pos.Qty) || !near(tp.Price, 105) {
+		t.Errorf("tp = %v @ %v, want %v @ 105", tp.Qty, tp.Price, pos.Qty)
+	}
+	if n := len(e.Orders("X")); n != 3 {
+		t.Errorf("%d orders rest, want the TP and 2 safety orders", n)
+	}
+}
+
+func TestDCATakeProfitFollowsSafetyFills(t *testing.T) {
+	s, e, fills := newDCATest(t)
+	feed(t, e,
+		bar(0, 100, 100, 100, 100),
+		bar(1, 95, 95, 89, 92), // safety order 1 fills at 90
+	)
+	pos := e.Snapshot().Position
+	if s.filled != 1 || !near(pos.Entry, (100+100)/(1+100.0/90)) {
+		t.Fatalf("filled %d, position %+v, want safety 1 averaged in", s.filled, pos)
+	}
+	if tp := tpOrder(t, e); !near(tp.Qty, pos.Qty) || !near(tp.Price, pos.Entry*1.05) {
+		t.Errorf("tp = %v @ %v, want the whole %v @ %v", tp.Qty, tp.Price, pos.Qty, pos.Entry*1.05)
+	}
+
+	feed(t, e, bar(2, 92, 100, 92, 99)) // reaches the new target, below the base's 105
+	exit := (*fills)[len(*fills)-2]
+	if !core.IsExitEvent(exit.Event) || !near(exit.Qty, pos.Qty) || !near(exit.Price, pos.Entry*1.05) {
+		t.Fatalf("fills = %+v, want the whole position sold at the target", *fills)
+	}
+	if st := s.Status().(DCAStatus); st.Deals != 1 || st.Filled != 0 {
+		t.Errorf("status = %+v, want one deal done and a fresh one", st)
+	}
+}
+
+func TestDCACancelsSafetyOrdersOnceFlat(t *testing.T) {
+	_, e, _ := newDCATest(t)
+	feed(t, e, bar(0, 100, 100, 100, 100))
+	pos := e.Snapshot().Position
+	if _, err := e.MarketOrder(core.OrderRequest{Symbol: "X", Side: core.Sell, Qty: pos.Qty, ReduceOnly: true}); err != nil {
+		t.Fatal(err)
+	}
+	feed(t, e, bar(1, 120, 120, 80, 100)) // crosses the old TP and a safety order
+	if ps := e.Snapshot().Positions; len(ps) != 1 || ps[0].Side != core.Buy || !near(ps[0].Qty, pos.Qty) {
+		t.Fatalf("positions = %+v, want just the next deal's base", ps)
+	}
+	for _, o := range e.Orders("X") {
+		if o.Created.Before(t0.Add(2 * time.Hour)) {
+			t.Errorf("order %+v of the closed deal still rests", o)
+		}
+	}
+}
+
+func TestDCATakeProfitClosesTheLongLegInHedgeMode(t *testing.T) {
+	s, e, fills := newDCAOn(t, core.Hedge)
+	feed(t, e,
+		bar(0, 100, 100, 100, 100),
+		bar(1, 95, 95, 89, 92),  // safety order 1 fills at 90
+		bar(2, 92, 100, 92, 99), // take-profit
+	)
+	var tp core.TradeEvent
+	for _, f := range *fills {
+		if f.OrderTag == "dca/tp" {
+			tp = f
+		}
+	}
+	if tp.Leg != core.LegLong || !core.IsExitEvent(tp.Event) {
+		t.Fatalf("fills = %+v, want the take-profit to close the long leg", *fills)
+	}
+	for _, p := range e.Snapshot().Positions {
+		if p.Side == core.Sell {
+			t.Errorf("short %+v opened, want none", p)
+		}
+	}
+	if st := s.Status().(DCAStatus); st.Deals != 1 {
+		t.Errorf("deals = %d, want the long deal closed", st.Deals)
+	}
+}
@@ -0,0 +1,126 @@
+package strategies
+
+import (
+	"fmt"
+
+	"tradebot/internal/core"
+	"tradebot/internal/indicators"
+)
+
+var donchianSchema = core.Schema{
+	Kind:        "donchian",
+	Aliases:     []string{"turtle"},
+	Description: "Donchian breakout (turtle): N-bar channel entries, ATR stops, pyramiding",
+	Params: []core.Param{
+		{Name: "entry", Type: core.ParamInt, Default: 20, Min: 2, Max: 500, Description: "entry channel length"},
+		{Name: "exit", Type: core.ParamInt, Default: 10, Min: 2, Max: 500, Description: "exit channel length"},
+		{Name: "atr", Type: core.ParamInt, Default: 20, Min: 1, Max: 500, Description: "ATR (N) length"},
+		{Name: "stop", Type: core.ParamFloat, Default: 2, Min: 0.1, Max: 20, Description: "stop distance in N from the last unit"},
+		{Name: "units", Type: core.ParamInt, Default: 4, Min: 1, Max: 10, Description: "most units held, the first included"},
+		{Name: "step", Type: core.ParamFloat, Default: 0.5, Min: 0.1, Max: 10, Description: "add a unit every step N in favour"},
+	},
+}
+
+func init() {
+	Register(Registration{Schema: donchianSchema, New: func(p core.Params) (core.Strategy, error) {
+		return NewDonchian(p.Int("entry"), p.Int("exit"), p.Int("atr"), p.Float("stop"), p.Int("units"), p.Float("step")), nil
+	}})
+}
+
+// Donchian enters when the close breaks the previous entry-bar channel and
+// exits when it breaks the previous exit-bar channel the other way. While
+// the trade runs it adds a unit every step N (ATR) in its favour, up to
+// units, and moves the stop of the whole position to stop N from the
+// newest unit.
+type Donchian struct {
+	Entry, Exit int
+	AtrLen      int
+	Stop        float64
+	Units       int
+	Step        float64
+
+	entryCh, exitCh *indicators.Donchian
+	atr             *indicators.ATR
+	bars            int
+	held            int     // units in the position
+	lastFill        float64 // price of the newest unit
+	name            string
+}
+
+func NewDonchian(entry, exit, atr int, stop float64, units int, step float64) *Donchian {
+	return &Donchian{
+		Entry: entry, Exit: exit, AtrLen: atr, Stop: stop, Units: units, Step: step, name: "DONCHIAN",
+		entryCh: indicators.NewDonchian(entry), exitCh: indicators.NewDonchian(exit), atr: indicators.NewATR(atr),
+	}
+}
+
+func (s *Donchian) Warmup() int  { return max(s.Entry, s.Exit, s.AtrLen) + 1 }
+func (s *Donchian) Name() string { return s.name }
+
+func (s *Donchian) Schema() core.Schema { return donchianSchema }
+func (s *Donchian) Params() core.Params {
+	return core.Params{
+		"entry": float64(s.Entry), "exit": float64(s.Exit), "atr": float64(s.AtrLen),
+		"stop": s.Stop, "units": float64(s.Units), "step": s.Step,
+	}
+}
+
+// OnFill counts the units the engine actually executed.
+func (s *Donchian) OnFill(ev core.TradeEvent, acct core.AccountState) {
+	switch ev.Event {
+	case "OPEN":
+		s.held, s.lastFill = 1, ev.Price
+	case "ADD":
+		s.held++
+		s.lastFill = ev.Price
+	}
+}
+
+func (s *Donchian) OnCandle(sym, tf string, kl core.Kline, acct core.AccountState) (core.Signal, error) {
+	s.bars++
+	// channels of the bars before this one
+	hi, lo := s.entryCh.Upper(), s.entryCh.Lower()
+	exitHi, exitLo := s.exitCh.Upper(), s.exitCh.Lower()
+	s.entryCh.Update(kl)
+	s.exitCh.Update(kl)
+	n := s.atr.Update(kl)
+	if s.bars < s.Warmup() {
+		return core.Signal{Action: core.None}, nil
+	}
+
+	last := kl.Close
+	pos := acct.PositionOf(sym)
+	if pos.Side == core.None {
+		s.held = 0
+	} else if s.held == 0 { // inherited, e.g. restored from state
+		s.held, s.lastFill = 1, pos.Entry
+	}
+	switch pos.Side {
+	case core.Buy:
+		if last < exitLo {
+			return core.Signal{Action: core.Close, Comment: fmt.Sprintf("donchian exit lo %.2f", exitLo)}, nil
+		}
+		if s.held < s.Units && last >= s.lastFill+s.Step*n {
+			sl := last - s.Stop*n
+			return core.Signal{Action: core.Buy, SizePct: 0.02, SL: &sl, Comment: fmt.Sprintf("donchian unit %d", s.held+1)}, nil
+		}
+	case core.Sell:
+		if last > exitHi {
+			return core.Signal{Action: core.Close, Comment: fmt.Sprintf("donchian exit hi %.2f", exitHi)}, nil
+		}
+		if s.held < s.Units && last <= s.lastFill-s.Step*n {
+			sl := last + s.Stop*n
+			return core.Signal{Action: core.Sell, SizePct: 0.02, SL: &sl, Comment: fmt.Sprintf("donchian unit %d", s.held+1)}, nil
+		}
+	default:
+		if last > hi {
+			sl := last - s.Stop*n
+			return core.Signal{Action: core.Buy, SizePct: 0.02, SL: &sl, Comment: fmt.Sprintf("donchian break hi %.2f", hi)}, nil
+		}
+		if last < lo {
+			sl := last + s.Stop*n
+			return core.Signal{Action: core.Sell, SizePct: 0.02, SL: &sl, Comment: fmt.Sprintf("donchian break lo %.2f", lo)}, nil
+		}
+	}
+	return core.Signal{}, nil
+}
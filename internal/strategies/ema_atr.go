@@ -1,127 +1,78 @@
 package strategies
 
 import (
-	"math"
+	"fmt"
 
 	"tradebot/internal/core"
+	"tradebot/internal/indicators"
 )
 
+var emaAtrSchema = core.Schema{
+	Kind:        "ema_atr",
+	Aliases:     []string{"ema"},
+	Description: "EMA crossover with ATR stop and R-multiple target",
+	Params: []core.Param{
+		{Name: "fast", Type: core.ParamInt, Default: 9, Min: 1, Max: 500, Description: "fast EMA length"},
+		{Name: "slow", Type: core.ParamInt, Default: 21, Min: 2, Max: 1000, Description: "slow EMA length, above fast"},
+		{Name: "atr", Type: core.ParamInt, Default: 14, Min: 1, Max: 500, Description: "ATR length"},
+		{Name: "R", Type: core.ParamFloat, Default: 1.5, Min: 0.1, Max: 20, Description: "take-profit distance in stop distances"},
+	},
+}
+
+func init() {
+	Register(Registration{Schema: emaAtrSchema, New: func(p core.Params) (core.Strategy, error) {
+		if p.Int("fast") >= p.Int("slow") {
+			return nil, fmt.Errorf("ema_atr: fast must be below slow")
+		}
+		return NewEmaAtr(p.Int("fast"), p.Int("slow"), p.Int("atr"), p.Float("R")), nil
+	}})
+}
+
 type EmaAtr struct {
 	Fast, Slow int
 	AtrLen     int
 	RiskR      float64
 
-	buf  []core.Kline
-	name string
+	fast, slow *indicators.EMA
+	atr        *indicators.ATR
+	cross      indicators.Cross
+	bars       int
+	name       string
 }
 
 func NewEmaAtr(fast, slow, atr int, r float64) *EmaAtr {
-	return &EmaAtr{Fast: fast, Slow: slow, AtrLen: atr, RiskR: r, name: "EMA_ATR"}
+	return &EmaAtr{
+		Fast: fast, Slow: slow, AtrLen: atr, RiskR: r, name: "EMA_ATR",
+		fast: indicators.NewEMA(fast), slow: indicators.NewEMA(slow), atr: indicators.NewATR(atr),
+	}
 }
 
-func (s *EmaAtr) Warmup() int  { return max3(s.Slow, s.AtrLen, s.Fast) + 2 }
+func (s *EmaAtr) Warmup() int  { return max(s.Slow, s.AtrLen, s.Fast) + 2 }
 func (s *EmaAtr) Name() string { return s.name }
 
+func (s *EmaAtr) Schema() core.Schema { return emaAtrSchema }
+func (s *EmaAtr) Params() core.Params {
+	return core.Params{"fast": float64(s.Fast), "slow": float64(s.Slow), "atr": float64(s.AtrLen), "R": s.RiskR}
+}
+
 func (s *EmaAtr) OnCandle(sym, tf string, kl core.Kline, acct core.AccountState) (core.Signal, error) {
-	s.buf = append(s.buf, kl)
-	if len(s.buf) < s.Warmup() {
+	s.bars++
+	cross := s.cross.Update(s.fast.Update(kl), s.slow.Update(kl))
+	atrv := s.atr.Update(kl)
+	if s.bars < s.Warmup() {
 		return core.Signal{Action: core.None}, nil
 	}
 
-	cl := closes(s.buf)
-	fast := EMA(cl, s.Fast)
-	slow := EMA(cl, s.Slow)
-	atr := ATR(highs(s.buf), lows(s.buf), cl, s.AtrLen)
-
-	last := cl[len(cl)-1]
-	atrv := atr[len(atr)-1]
+	last := kl.Close
 	var sig core.Signal
-	if crossUp(fast, slow) {
+	if cross > 0 {
 		sl := last - 1.5*atrv
 		tp := last + s.RiskR*(last-sl)
 		sig = core.Signal{Action: core.Buy, SizePct: 0.02, SL: &sl, TP: &tp, Comment: "ema up"}
-	} else if crossDown(fast, slow) {
+	} else if cross < 0 {
 		sl := last + 1.5*atrv
 		tp := last - s.RiskR*(sl-last)
 		sig = core.Signal{Action: core.Sell, SizePct: 0.02, SL: &sl, TP: &tp, Comment: "ema dn"}
 	}
 	return sig, nil
 }
-
-// === utils ===
-func EMA(x []float64, n int) []float64 {
-	res := make([]float64, len(x))
-	k := 2.0 / (float64(n) + 1)
-	res[0] = x[0]
-	for i := 1; i < len(x); i++ {
-		res[i] = x[i]*k + res[i-1]*(1-k)
-	}
-	return res
-}
-
-func ATR(h, l, c []float64, n int) []float64 {
-	tr := make([]float64, len(c))
-	tr[0] = h[0] - l[0]
-	for i := 1; i < len(c); i++ {
-		m1 := h[i] - l[i]
-		m2 := math.Abs(h[i] - c[i-1])
-		m3 := math.Abs(l[i] - c[i-1])
-		tr[i] = max3f(m1, m2, m3)
-	}
-	return EMA(tr, n)
-}
-
-func crossUp(a, b []float64) bool {
-	if len(a) < 2 || len(b) < 2 {
-		return false
-	}
-	return a[len(a)-2] <= b[len(b)-2] && a[len(a)-1] > b[len(b)-1]
-}
-
-func crossDown(a, b []float64) bool {
-	if len(a) < 2 || len(b) < 2 {
-		return false
-	}
-	return a[len(a)-2] >= b[len(b)-2] && a[len(a)-1] < b[len(b)-1]
-}
-
-func closes(k []core.Kline) []float64 {
-	r := make([]float64, len(k))
-	for i := range k {
-		r[i] = k[i].Close
-	}
-	return r
-}
-func highs(k []core.Kline) []float64 {
-	r := make([]float64, len(k))
-	for i := range k {
-		r[i] = k[i].High
-	}
-	return r
-}
-func lows(k []core.Kline) []float64 {
-	r := make([]float64, len(k))
-	for i := range k {
-		r[i] = k[i].Low
-	}
-	return r
-}
-
-func max3(a, b, c int) int {
-	if a < b {
-		a = b
-	}
-	if a < c {
-		a = c
-	}
-	return a
-}
-func max3f(a, b, c float64) float64 {
-	if a < b {
-		a = b
-	}
-	if a < c {
-		a = c
-	}
-	return a
-}
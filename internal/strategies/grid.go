@@ -0,0 +1,261 @@
+package strategies
+
+import (
+	"fmt"
+	"math"
+
+	"tradebot/internal/core"
+)
+
+var gridSchema = core.Schema{
+	Kind:        "grid",
+	Description: "grid trading for ranges: limit buys below the price, each sold one level up",
+	Params: []core.Param{
+		{Name: "lower", Type: core.ParamFloat, Default: 0, Min: 0, Max: 1e9, Description: "lowest level; 0 = span below the first price"},
+		{Name: "upper", Type: core.ParamFloat, Default: 0, Min: 0, Max: 1e9, Description: "highest level; 0 = span above the first price"},
+		{Name: "span", Type: core.ParamFloat, Default: 0.1, Min: 0.005, Max: 0.9, Description: "bounds left at 0: this share of the first price either side"},
+		{Name: "levels", Type: core.ParamInt, Default: 10, Min: 3, Max: 200, Description: "grid levels, bounds included"},
+		{Name: "geometric", Type: core.ParamInt, Default: 0, Min: 0, Max: 1, Description: "1 = equal ratios between levels, 0 = equal steps"},
+		{Name: "invest", Type: core.ParamFloat, Default: 0.5, Min: 0.01, Max: 1, Description: "share of equity spread over the grid"},
+		{Name: "seed", Type: core.ParamInt, Default: 0, Min: 0, Max: 1, Description: "1 = buy inventory at the start for the levels above the price"},
+	},
+}
+
+func init() {
+	Register(Registration{Schema: gridSchema, New: func(p core.Params) (core.Strategy, error) {
+		if lo, hi := p.Float("lower"), p.Float("upper"); lo > 0 && hi > 0 && lo >= hi {
+			return nil, fmt.Errorf("grid: lower must be below upper")
+		}
+		return NewGrid(p.Float("lower"), p.Float("upper"), p.Float("span"), p.Int("levels"), p.Int("geometric") == 1, p.Float("invest"), p.Int("seed") == 1), nil
+	}})
+}
+
+// Grid lays levels between Lower and Upper on its first candle. Every gap
+// between two levels is a slot: a limit buy rests at its lower level while the
+// price is above it, and once that fills the bought quantity is offered by a
+// limit sell at its upper level. A completed buy-sell round trip books grid
+// profit net of both fees; what is still held is inventory, marked at the
+// last close. Sells take the long leg, so a hedge-mode account never opens a
+// short with them.
+//
+// An order of the grid that is rejected, canceled or expires is placed again
+// on the next candle.
+//
+// The grid owns the symbol's orders: laying it cancels the pending ones. It
+// keeps its state in memory and starts over, re-laid around the price, when
+// the position is flattened outside the grid (stop, flatten, restart).
+type Grid struct {
+	Lower, Upper float64
+	Span         float64
+	Levels       int
+	Geometric    bool
+	Invest       float64
+	Seed         bool
+
+	prices  []float64  // laid levels, ascending; nil until laid
+	slots   []gridSlot // slot i trades between prices[i] and prices[i+1]
+	pending []core.Order
+	profit  float64
+	cycles  int
+	last    float64
+	name    string
+}
+
+type gridSlot struct {
+	buying    bool    // buy resting at the lower level
+	selling   bool    // sell of the inventory resting at the upper level
+	qty, cost float64 // inventory held
+	fee       float64 // paid buying the inventory held
+}
+
+func NewGrid(lower, upper, span float64, levels int, geometric bool, invest float64, seed bool) *Grid {
+	return &Grid{Lower: lower, Upper: upper, Span: span, Levels: levels, Geometric: geometric, Invest: invest, Seed: seed, name: "GRID"}
+}
+
+func (s *Grid) Warmup() int  { return 1 }
+func (s *Grid) Name() string { return s.name }
+
+func (s *Grid) Schema() core.Schema { return gridSchema }
+func (s *Grid) Params() core.Params {
+	return core.Params{
+		"lower": s.Lower, "upper": s.Upper, "span": s.Span, "levels": float64(s.Levels),
+		"geometric": b2f(s.Geometric), "invest": s.Invest, "seed": b2f(s.Seed),
+	}
+}
+
+func (s *Grid) OnCandle(sym, tf string, kl core.Kline, acct core.AccountState) (core.Signal, error) {
+	s.last = kl.Close
+	relay := s.prices == nil
+	if !relay && s.inventory() > 0 && acct.PositionOf(sym).Side == core.None {
+		relay = true
+	}
+	if relay {
+		s.lay(kl.Close)
+	}
+	for i := range s.slots {
+		sl := &s.slots[i]
+		switch {
+		case sl.qty > 0:
+			if !sl.selling {
+				s.sell(i)
+			}
+		case sl.buying:
+		case s.prices[i+1] <= kl.Close || relay && s.Seed && s.prices[i] >= kl.Close:
+			s.buy(i)
+		}
+	}
+	sig := core.Signal{Cancel: relay, Orders: s.pending, Comment: "grid"}
+	s.pending = nil
+	return sig, nil
+}
+
+// OnFill moves a slot along when one of its orders fills.
+func (s *Grid) OnFill(ev core.TradeEvent, acct core.AccountState) {
+	var i int
+	var side string
+	if _, err := fmt.Sscanf(ev.OrderTag, "grid/%d/%s", &i, &side); err != nil || i < 0 || i >= len(s.slots) {
+		return
+	}
+	sl := &s.slots[i]
+	switch side {
+	case "buy":
+		sl.buying = false
+		sl.cost = (sl.cost*sl.qty + ev.Price*ev.Qty) / (sl.qty + ev.Qty)
+		sl.qty += ev.Qty
+		sl.fee += ev.Fee
+		s.sell(i)
+	case "sell":
+		buyFee := sl.fee * math.Min(ev.Qty/sl.qty, 1)
+		s.profit += ev.Qty*(ev.Price-sl.cost) - ev.Fee - buyFee
+		sl.fee -= buyFee
+		if sl.qty -= ev.Qty; sl.qty <= 1e-12 {
+			sl.qty, sl.cost, sl.fee, sl.selling = 0, 0, 0, false
+			s.cycles++
+		}
+	}
+}
+
+// OnOrderClosed re-arms the slot of a grid order that left the book unfilled,
+// e.g. canceled by the user; the next candle places it again.
+func (s *Grid) OnOrderClosed(o core.Order) { s.rearm(o.Tag) }
+
+// OnRisk re-arms the slot of a grid order rejected when placed, e.g. while
+// trading is paused.
+func (s *Grid) OnRisk(d core.RiskDecision) {
+	if d.Rejected {
+		s.rearm(d.Tag)
+	}
+}
+
+func (s *Grid) rearm(tag string) {
+	var i int
+	var side string
+	if _, err := fmt.Sscanf(tag, "grid/%d/%s", &i, &side); err != nil || i < 0 || i >= len(s.slots) {
+		return
+	}
+	if side == "buy" {
+		s.slots[i].buying = false
+	} else {
+		s.slots[i].selling = false
+	}
+}
+
+// lay spaces the levels around px and forgets the previous grid.
+func (s *Grid) lay(px float64) {
+	lo, hi := s.Lower, s.Upper
+	if lo <= 0 {
+		lo = px * (1 - s.Span)
+	}
+	if hi <= lo {
+		hi = px * (1 + s.Span)
+	}
+	n := max(s.Levels, 2)
+	s.prices = make([]float64, n)
+	for i := range s.prices {
+		f := float64(i) / float64(n-1)
+		if s.Geometric {
+			s.prices[i] = lo * math.Pow(hi/lo, f)
+		} else {
+			s.prices[i] = lo + (hi-lo)*f
+		}
+	}
+	s.slots = make([]gridSlot, n-1)
+	s.pending = nil
+}
+
+func (s *Grid) buy(i int) {
+	s.slots[i].buying = true
+	s.pending = append(s.pending, core.Order{
+		Side: core.Buy, Type: core.Limit, SizePct: s.Invest / float64(len(s.slots)), Price: s.prices[i],
+		Tag: fmt.Sprintf("grid/%d/buy", i), Comment: fmt.Sprintf("grid buy L%d", i),
+	})
+}
+
+// sell offers slot i's inventory at its upper level.
+func (s *Grid) sell(i int) {
+	s.slots[i].selling = true
+	s.pending = append(s.pending, core.Order{
+		Side: core.Sell, Leg: core.LegLong, Type: core.Limit, Qty: s.slots[i].qty, Price: s.prices[i+1],
+		Tag: fmt.Sprintf("grid/%d/sell", i), Comment: fmt.Sprintf("grid sell L%d", i+1),
+	})
+}
+
+func (s *Grid) inventory() float64 {
+	q := 0.0
+	for _, sl := range s.slots {
+		q += sl.qty
+	}
+	return q
+}
+
+// GridLevel is one level of GridStatus with the order resting there.
+type GridLevel struct {
+	Price float64 `json:"price"`
+	Order string  `json:"order,omitempty"` // "buy" | "sell"
+	Qty   float64 `json:"qty,omitempty"`   // inventory offered by the sell
+}
+
+// GridStatus is the grid's core.StatusReporter snapshot. GridProfit is booked
+// by completed round trips; InventoryPnL marks what is held at Last. Both are
+// net of the fees paid so far.
+type GridStatus struct {
+	Kind         string      `json:"kind"`
+	Levels       []GridLevel `json:"levels"`
+	GridProfit   float64     `json:"gridProfit"`
+	Cycles       int         `json:"cycles"`
+	Inventory    float64     `json:"inventory"`
+	AvgCost      float64     `json:"avgCost"`
+	InventoryPnL float64     `json:"inventoryPnl"`
+	Last         float64     `json:"last"`
+}
+
+func (s *Grid) Status() any {
+	st := GridStatus{Kind: "grid", Levels: make([]GridLevel, len(s.prices)), GridProfit: s.profit, Cycles: s.cycles, Last: s.last}
+	for i, px := range s.prices {
+		st.Levels[i].Price = px
+	}
+	cost, fees := 0.0, 0.0
+	for i, sl := range s.slots {
+		if sl.buying {
+			st.Levels[i].Order = "buy"
+		}
+		if sl.selling {
+			st.Levels[i+1].Order, st.Levels[i+1].Qty = "sell", sl.qty
+			st.Inventory += sl.qty
+			cost += sl.qty * sl.cost
+			fees += sl.fee
+		}
+	}
+	if st.Inventory > 0 {
+		st.AvgCost = cost / st.Inventory
+		st.InventoryPnL = st.Inventory*s.last - cost - fees
+	}
+	return st
+}
+
+func b2f(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
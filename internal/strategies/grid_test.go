@@ -0,0 +1,89 @@
+package strategies
+
+import (
+	"testing"
+
+	"tradebot/internal/core"
+)
+
+// a 90/100/110 grid: one slot buys at 90 and sells at 100
+func gridRoundTrip(t *testing.T, mode core.AccountMode) (*Grid, *core.Engine, []core.TradeEvent) {
+	t.Helper()
+	g := NewGrid(90, 110, 0, 3, false, 0.5, false)
+	e, fills := newEngine(t, core.EngineOpts{AccountMode: mode, Fees: core.FeeConfig{Maker: 0.001, Taker: 0.002}}, g)
+	feed(t, e,
+		bar(0, 105, 105, 105, 105), // lays the grid, buy rests at 90
+		bar(1, 105, 105, 89, 95),   // buy fills
+		bar(2, 95, 96, 94, 95),     // sell rests at 100
+		bar(3, 95, 101, 95, 100),   // sell fills
+	)
+	return g, e, *fills
+}
+
+func TestGridProfitIsNetOfFees(t *testing.T) {
+	g, _, fills := gridRoundTrip(t, core.OneWay)
+	if len(fills) != 2 {
+		t.Fatalf("fills = %+v, want a buy and a sell", fills)
+	}
+	buy, sell := fills[0], fills[1]
+	if buy.Price != 90 || sell.Price != 100 || buy.Fee <= 0 || sell.Fee <= 0 {
+		t.Fatalf("buy %+v sell %+v, want maker fills at 90 and 100 with fees", buy, sell)
+	}
+	st := g.Status().(GridStatus)
+	if want := buy.Qty*10 - buy.Fee - sell.Fee; !near(st.GridProfit, want) {
+		t.Errorf("grid profit = %v, want %v", st.GridProfit, want)
+	}
+	if st.Cycles != 1 || st.Inventory != 0 {
+		t.Errorf("cycles %d inventory %v, want one cycle and none held", st.Cycles, st.Inventory)
+	}
+}
+
+func TestGridSellsReduceTheLongLegInHedgeMode(t *testing.T) {
+	_, e, fills := gridRoundTrip(t, core.Hedge)
+	if len(fills) != 2 || fills[1].Leg != core.LegLong || !core.IsExitEvent(fills[1].Event) {
+		t.Fatalf("fills = %+v, want the sell to close the long leg", fills)
+	}
+	if ps := e.Snapshot().Positions; len(ps) != 0 {
+		t.Errorf("positions left = %+v, want none (no short opened)", ps)
+	}
+}
+
+func TestGridReplacesRejectedBuys(t *testing.T) {
+	g := NewGrid(90, 110, 0, 3, false, 0.5, false)
+	e, _ := newEngine(t, core.EngineOpts{Trading: core.TradingPaused}, g)
+	feed(t, e, bar(0, 105, 105, 105, 105)) // lays the grid; the buy is rejected
+	if n := len(e.Orders("X")); n != 0 {
+		t.Fatalf("%d orders rest while paused, want none", n)
+	}
+	if err := e.SetTradingState(core.TradingRunning); err != nil {
+		t.Fatal(err)
+	}
+	feed(t, e, bar(1, 105, 105, 105, 105))
+	if orders := e.Orders("X"); len(orders) != 1 || orders[0].Tag != "grid/0/buy" {
+		t.Fatalf("orders = %+v, want the buy at 90 placed again", orders)
+	}
+}
+
+func TestGridReplacesCanceledOrders(t *testing.T) {
+	g := NewGrid(90, 110, 0, 3, false, 0.5, false)
+	e, _ := newEngine(t, core.EngineOpts{}, g)
+	feed(t, e,
+		bar(0, 105, 105, 105, 105), // buy rests at 90
+		bar(1, 105, 105, 89, 95),   // buy fills, sell rests at 100
+	)
+	orders := e.Orders("X")
+	if len(orders) != 1 || orders[0].Tag != "grid/0/sell" {
+		t.Fatalf("orders = %+v, want the sell", orders)
+	}
+	if err := e.CancelOrder(orders[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	feed(t, e, bar(2, 95, 96, 94, 95))
+	if orders := e.Orders("X"); len(orders) != 1 || orders[0].Tag != "grid/0/sell" {
+		t.Fatalf("orders = %+v, want the sell placed again", orders)
+	}
+	feed(t, e, bar(3, 95, 101, 95, 100))
+	if st := g.Status().(GridStatus); st.Cycles != 1 {
+		t.Errorf("cycles = %d, want the re-placed sell to complete the round trip", st.Cycles)
+	}
+}
@@ -0,0 +1,52 @@
+package strategies
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"tradebot/internal/core"
+)
+
+// passRisk approves every signal unchanged.
+type passRisk struct{}
+
+func (passRisk) Validate(sig core.Signal, _ core.AccountState, _ float64) (core.Signal, error) {
+	return sig, nil
+}
+
+// newEngine runs s on a paper engine with equity 10000 on candle time and
+// returns the fills it books.
+func newEngine(t *testing.T, opts core.EngineOpts, s core.Strategy) (*core.Engine, *[]core.TradeEvent) {
+	t.Helper()
+	fills := &[]core.TradeEvent{}
+	opts.Bus = core.NewBus()
+	opts.Bus.Subscribe("test", core.SubOpts{Policy: core.Sync}, func(ev core.Event) {
+		if pc, ok := ev.(core.PositionChanged); ok {
+			*fills = append(*fills, pc.TradeEvent)
+		}
+	})
+	t.Cleanup(opts.Bus.Close)
+	opts.Mode, opts.EqUSD, opts.Risk, opts.Clock = "paper", 10000, passRisk{}, core.NewCandleClock()
+	e := core.NewEngine(opts)
+	e.AttachStrategy(s)
+	return e, fills
+}
+
+var t0 = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// bar is hourly candle i of X.
+func bar(i int, o, h, l, c float64) core.Kline {
+	return core.Kline{Symbol: "X", TF: "1h", Ts: t0.Add(time.Duration(i) * time.Hour), Open: o, High: h, Low: l, Close: c, Vol: 1}
+}
+
+func feed(t *testing.T, e *core.Engine, bars ...core.Kline) {
+	t.Helper()
+	for _, kl := range bars {
+		if err := e.OnCandle(kl.Symbol, kl.TF, kl); err != nil {
+			t.Fatalf("OnCandle %s: %v", kl.Ts, err)
+		}
+	}
+}
+
+func near(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
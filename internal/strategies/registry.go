@@ -0,0 +1,171 @@
+package strategies
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"tradebot/internal/core"
+)
+
+// Registration makes a strategy kind available to the bot, the backtester,
+// Telegram, the web API and state persistence.
+type Registration struct {
+	Schema core.Schema
+	// New builds the strategy from params already validated against Schema.
+	New func(p core.Params) (core.Strategy, error)
+	// Params serializes a running strategy of this kind, reporting false for
+	// strategies it does not own. Nil uses core.Parameterized when the
+	// strategy's schema kind matches.
+	Params func(s core.Strategy) (core.Params, bool)
+
+	// Kinds configured by text rather than numbers (composite) set Build
+	// instead of New and Spec instead of Params. Build reads the raw Build
+	// arguments itself; Spec renders a running strategy it owns as the
+	// "spec" argument that builds it again.
+	Build func(args map[string]any) (core.Strategy, error)
+	Spec  func(s core.Strategy) (string, bool)
+	// ParseArgs reads command-line values into Build arguments; nil uses
+	// Schema.ParseArgs.
+	ParseArgs func(args []string) (map[string]any, error)
+}
+
+// Args reads command-line values of r's kind into Build arguments.
+func (r Registration) Args(args []string) (map[string]any, error) {
+	if r.ParseArgs != nil {
+		return r.ParseArgs(args)
+	}
+	return r.Schema.ParseArgs(args)
+}
+
+var (
+	regMu    sync.RWMutex
+	registry = map[string]Registration{} // kind and aliases, lower case
+	kinds    []string
+)
+
+// Register adds a strategy kind. It panics on an incomplete registration or
+// a kind or alias already taken, so mistakes surface at init.
+func Register(r Registration) {
+	if r.Schema.Kind == "" || (r.New == nil) == (r.Build == nil) {
+		panic("strategies: Register needs Schema.Kind and one of New, Build")
+	}
+	regMu.Lock()
+	defer regMu.Unlock()
+	names := append([]string{r.Schema.Kind}, r.Schema.Aliases...)
+	for _, n := range names {
+		if _, dup := registry[strings.ToLower(n)]; dup {
+			panic("strategies: duplicate registration of " + n)
+		}
+	}
+	for _, n := range names {
+		registry[strings.ToLower(n)] = r
+	}
+	kinds = append(kinds, r.Schema.Kind)
+	sort.Strings(kinds)
+}
+
+// Lookup finds a registration by kind or alias, ignoring case.
+func Lookup(kind string) (Registration, bool) {
+	regMu.RLock()
+	defer regMu.RUnlock()
+	r, ok := registry[strings.ToLower(kind)]
+	return r, ok
+}
+
+// Schemas lists the registered schemas sorted by kind.
+func Schemas() []core.Schema {
+	regMu.RLock()
+	defer regMu.RUnlock()
+	out := make([]core.Schema, 0, len(kinds))
+	for _, k := range kinds {
+		out = append(out, registry[k].Schema)
+	}
+	return out
+}
+
+// SchemaOf finds a schema by kind or alias, ignoring case.
+func SchemaOf(kind string) (core.Schema, bool) {
+	r, ok := Lookup(kind)
+	return r.Schema, ok
+}
+
+// Build validates args against kind's schema and constructs the strategy,
+// or hands them to the kind's own Build.
+func Build(kind string, args map[string]any) (core.Strategy, error) {
+	r, ok := Lookup(kind)
+	if !ok {
+		return nil, fmt.Errorf("unknown strategy %q", kind)
+	}
+	if r.Build != nil {
+		return r.Build(args)
+	}
+	p, err := r.Schema.Validate(args)
+	if err != nil {
+		return nil, err
+	}
+	return r.New(p)
+}
+
+// Description is a running strategy as its registered kind sees it: Params,
+// or Spec for a kind built from a spec.
+type Description struct {
+	Schema core.Schema
+	Params core.Params
+	Spec   string
+}
+
+// Args converts the description back to Build arguments.
+func (d Description) Args() map[string]any { return Args(d.Params, d.Spec) }
+
+// String renders the params as name=value pairs, or the spec.
+func (d Description) String() string {
+	if d.Spec != "" {
+		return d.Spec
+	}
+	return d.Schema.Format(d.Params)
+}
+
+// Describe reports the registered kind and current configuration of s.
+func Describe(s core.Strategy) (Description, bool) {
+	if s == nil {
+		return Description{}, false
+	}
+	regMu.RLock()
+	regs := make([]Registration, 0, len(kinds))
+	for _, k := range kinds {
+		regs = append(regs, registry[k])
+	}
+	regMu.RUnlock() // a Spec describes its children through Describe
+	for _, r := range regs {
+		switch {
+		case r.Spec != nil:
+			if spec, ok := r.Spec(s); ok {
+				return Description{Schema: r.Schema, Spec: spec}, true
+			}
+		case r.Params != nil:
+			if p, ok := r.Params(s); ok {
+				return Description{Schema: r.Schema, Params: p}, true
+			}
+		default:
+			if ps, ok := s.(core.Parameterized); ok && ps.Schema().Kind == r.Schema.Kind {
+				return Description{Schema: r.Schema, Params: ps.Params()}, true
+			}
+		}
+	}
+	return Description{}, false
+}
+
+// Args converts stored params back to raw Build arguments; spec is the
+// stored text form of a kind built from a spec, "" for other kinds.
+func Args(p map[string]float64, spec string) map[string]any {
+	out := make(map[string]any, len(p)+1)
+	for k, v := range p {
+		out[k] = v
+	}
+	if spec != "" {
+		out["spec"] = spec
+	}
+	return out
+}
@@ -1,6 +1,31 @@
 package strategies
 
-import "tradebot/internal/core"
+import (
+	"fmt"
+
+	"tradebot/internal/core"
+	"tradebot/internal/indicators"
+)
+
+var rsiSchema = core.Schema{
+	Kind:        "rsi",
+	Description: "RSI mean reversion: long when oversold, short when overbought",
+	Params: []core.Param{
+		{Name: "len", Type: core.ParamInt, Default: 14, Min: 2, Max: 500, Description: "RSI length"},
+		{Name: "overbought", Type: core.ParamFloat, Default: 70, Min: 50, Max: 100, Description: "short at or above"},
+		{Name: "oversold", Type: core.ParamFloat, Default: 30, Min: 0, Max: 50, Description: "long at or below"},
+		{Name: "R", Type: core.ParamFloat, Default: 1.5, Min: 0.1, Max: 20, Description: "take-profit distance in stop distances"},
+	},
+}
+
+func init() {
+	Register(Registration{Schema: rsiSchema, New: func(p core.Params) (core.Strategy, error) {
+		if p.Float("oversold") >= p.Float("overbought") {
+			return nil, fmt.Errorf("rsi: oversold must be below overbought")
+		}
+		return NewRSI(p.Int("len"), p.Float("overbought"), p.Float("oversold"), p.Float("R")), nil
+	}})
+}
 
 type RSI struct {
 	Len        int
@@ -8,7 +33,8 @@ type RSI struct {
 	Oversold   float64
 	RiskR      float64
 
-	buf  []core.Kline
+	rsi  *indicators.RSI
+	bars int
 	name string
 }
 
@@ -16,23 +42,25 @@ func NewRSI(length int, over, under float64, r float64) *RSI {
 	if length < 2 {
 		length = 2
 	}
-	return &RSI{Len: length, Overbought: over, Oversold: under, RiskR: r, name: "RSI"}
+	return &RSI{Len: length, Overbought: over, Oversold: under, RiskR: r, rsi: indicators.NewRSI(length), name: "RSI"}
 }
 
 func (s *RSI) Warmup() int  { return s.Len + 2 }
 func (s *RSI) Name() string { return s.name }
 
+func (s *RSI) Schema() core.Schema { return rsiSchema }
+func (s *RSI) Params() core.Params {
+	return core.Params{"len": float64(s.Len), "overbought": s.Overbought, "oversold": s.Oversold, "R": s.RiskR}
+}
+
 func (s *RSI) OnCandle(sym, tf string, kl core.Kline, acct core.AccountState) (core.Signal, error) {
-	s.buf = append(s.buf, kl)
-	if len(s.buf) < s.Warmup() {
+	s.bars++
+	cur := s.rsi.Update(kl)
+	if s.bars < s.Warmup() {
 		return core.Signal{Action: core.None}, nil
 	}
 
-	cl := closes(s.buf)
-	r := rsi(cl, s.Len)
-	cur := r[len(r)-1]
-	last := cl[len(cl)-1]
-
+	last := kl.Close
 	var sig core.Signal
 	if cur <= s.Oversold {
 		sl := last * 0.99
@@ -45,56 +73,3 @@ func (s *RSI) OnCandle(sym, tf string, kl core.Kline, acct core.AccountState) (c
 	}
 	return sig, nil
 }
-
-func rsi(cl []float64, n int) []float64 {
-	res := make([]float64, len(cl))
-	if len(cl) == 0 {
-		return res
-	}
-	if n < 2 {
-		n = 2
-	}
-
-	gain := 0.0
-	loss := 0.0
-	for i := 1; i <= n && i < len(cl); i++ {
-		d := cl[i] - cl[i-1]
-		if d >= 0 {
-			gain += d
-		} else {
-			loss -= d
-		}
-	}
-
-	avgG := gain / float64(n)
-	avgL := loss / float64(n)
-	rs := 0.0
-	if avgL != 0 {
-		rs = avgG / avgL
-	}
-	res[n] = 100 - 100/(1+rs)
-
-	for i := n + 1; i < len(cl); i++ {
-		d := cl[i] - cl[i-1]
-		g := 0.0
-		l := 0.0
-		if d >= 0 {
-			g = d
-		} else {
-			l = -d
-		}
-		avgG = (avgG*float64(n-1) + g) / float64(n)
-		avgL = (avgL*float64(n-1) + l) / float64(n)
-		rs = 0
-		if avgL != 0 {
-			rs = avgG / avgL
-		}
-		res[i] = 100 - 100/(1+rs)
-	}
-
-	last := res[n]
-	for i := 0; i < n && i < len(res); i++ {
-		res[i] = last
-	}
-	return res
-}
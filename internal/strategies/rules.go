@@ -0,0 +1,92 @@
+package strategies
+
+import (
+	"fmt"
+
+	"tradebot/internal/core"
+	"tradebot/internal/indicators"
+)
+
+// RulesConfig describes a strategy as indicator expressions (see
+// indicators.Env for the language). Empty rules never fire.
+type RulesConfig struct {
+	Long  string  // open or reverse to long when true
+	Short string  // open or reverse to short when true
+	Exit  string  // close the open position when true
+	SL    string  // stop distance from the close, in price
+	TP    string  // target distance from the close, in price
+	Size  float64 // SizePct of entries (default 0.02)
+}
+
+// Rules is a strategy assembled from RulesConfig, e.g. from a backtest DSL
+// document with strategy: rules.
+type Rules struct {
+	cfg                       RulesConfig
+	env                       *indicators.Env
+	long, short, exit, sl, tp *indicators.Expr
+}
+
+func NewRules(cfg RulesConfig) (*Rules, error) {
+	if cfg.Long == "" && cfg.Short == "" {
+		return nil, fmt.Errorf("rules: need a long or a short rule")
+	}
+	if cfg.Size <= 0 {
+		cfg.Size = 0.02
+	}
+	s := &Rules{cfg: cfg, env: indicators.NewEnv()}
+	for _, r := range []struct {
+		src string
+		dst **indicators.Expr
+	}{{cfg.Long, &s.long}, {cfg.Short, &s.short}, {cfg.Exit, &s.exit}, {cfg.SL, &s.sl}, {cfg.TP, &s.tp}} {
+		if r.src == "" {
+			continue
+		}
+		x, err := s.env.Compile(r.src)
+		if err != nil {
+			return nil, fmt.Errorf("rules: %w", err)
+		}
+		*r.dst = x
+	}
+	return s, nil
+}
+
+func (s *Rules) Warmup() int  { return s.env.Warmup() }
+func (s *Rules) Name() string { return "RULES" }
+
+func (s *Rules) OnCandle(sym, tf string, kl core.Kline, acct core.AccountState) (core.Signal, error) {
+	s.env.Update(kl)
+	if !s.env.Ready() {
+		return core.Signal{Action: core.None}, nil
+	}
+	pos := acct.PositionOf(sym)
+	switch {
+	case s.long != nil && s.long.True() && pos.Side != core.Buy:
+		return s.entry(core.Buy, kl.Close, "rules long"), nil
+	case s.short != nil && s.short.True() && pos.Side != core.Sell:
+		return s.entry(core.Sell, kl.Close, "rules short"), nil
+	case s.exit != nil && pos.Side != core.None && s.exit.True():
+		return core.Signal{Action: core.Close, Comment: "rules exit"}, nil
+	}
+	return core.Signal{}, nil
+}
+
+func (s *Rules) entry(side core.Action, px float64, comment string) core.Signal {
+	dir := 1.0
+	if side == core.Sell {
+		dir = -1
+	}
+	sig := core.Signal{Action: side, SizePct: s.cfg.Size, Comment: comment}
+	if s.sl != nil {
+		if d := s.sl.Eval(); d > 0 {
+			sl := px - dir*d
+			sig.SL = &sl
+		}
+	}
+	if s.tp != nil {
+		if d := s.tp.Eval(); d > 0 {
+			tp := px + dir*d
+			sig.TP = &tp
+		}
+	}
+	return sig
+}
@@ -20,6 +20,7 @@ import (
 
 type Bot struct {
 	token      string
+	apiURL     string // Bot API base, replaced in tests
 	eng        *core.Engine
 	tl         core.TradeLogger
 	store      *state.Store
@@ -29,12 +30,14 @@ type Bot struct {
 	feedType   string
 	strategy   state.StrategyState
 	switchFeed func(string)
+	notify     map[int64]bool // chats receiving engine events
+	held       string         // reconciliation report no chat has got yet
 
 	mu sync.RWMutex
 }
 
 func NewBot(token string, eng *core.Engine, tl core.TradeLogger, store *state.Store, symbol, tf, feedType string) *Bot {
-	b := &Bot{token: token, eng: eng, tl: tl, store: store, symbol: symbol, tf: tf}
+	b := &Bot{token: token, apiURL: "https://api.telegram.org", eng: eng, tl: tl, store: store, symbol: symbol, tf: tf, notify: map[int64]bool{}}
 	b.SetFeedType(feedType)
 	b.captureStrategy(eng.Strategy())
 	return b
@@ -67,97 +70,129 @@ func (b *Bot) Run(ctx context.Context, onSwitchFeed func(newFeed string)) error
 				if up.Message == nil {
 					continue
 				}
-				chatID := up.Message.Chat.ID
-				text := strings.TrimSpace(up.Message.Text)
-				switch {
-				case strings.HasPrefix(text, "/start"), strings.HasPrefix(text, "/help"):
-					// Кнопка Web App
-					btn := map[string]any{"text": "📊 Открыть терминал", "web_app": map[string]string{"url": "http://localhost:8080/"}}
-					kb := map[string]any{"inline_keyboard": [][]any{{btn}}}
-					rm, _ := json.Marshal(kb)
-					v := url.Values{}
-					v.Set("chat_id", strconv.FormatInt(chatID, 10))
-					v.Set("text", helpText()+"\n\nОткрой мини-терминал:")
-					v.Set("reply_markup", string(rm))
-					var out tgResp[tgMessage]
-					b.api("sendMessage", v, &out)
-					// также отправим обычный help без кнопки (на всякий)
-					b.send(chatID, helpText())
-				case strings.HasPrefix(text, "/status"):
-					b.send(chatID, b.status())
-				case strings.HasPrefix(text, "/equity"):
-					b.send(chatID, b.equity())
-				case strings.HasPrefix(text, "/which_strategy"):
-					b.send(chatID, b.which())
-				case strings.HasPrefix(text, "/start_trading"):
-					b.send(chatID, "Торговля включена (paper)")
-				case strings.HasPrefix(text, "/stop_trading"):
-					b.send(chatID, "Торговля выключена")
-				case strings.HasPrefix(text, "/set_strategy"):
-					b.handleSetStrategy(chatID, text)
-				case strings.HasPrefix(text, "/history"):
-					n := 10
-					parts := strings.Fields(text)
-					if len(parts) >= 2 {
-						if v, err := atoiMaybe(parts[1]); err == nil && v > 0 {
-							n = v
-						}
-					}
-					if b.tl == nil {
-						b.send(chatID, "Журнал недоступен")
-						continue
-					}
-					rows, err := b.tl.LastN(n)
-					if err != nil {
-						b.send(chatID, "Ошибка чтения журнала")
-					} else {
-						b.send(chatID, formatHistory(rows))
-					}
-				case strings.HasPrefix(text, "/save_state"):
-					if err := b.saveState(); err != nil {
-						b.send(chatID, "Не удалось сохранить state")
-					} else {
-						b.send(chatID, "State сохранён")
-					}
-				case strings.HasPrefix(text, "/load_state"):
-					if err := b.loadState(); err != nil {
-						b.send(chatID, "Не удалось загрузить state")
-					} else {
-						b.send(chatID, "State загружен")
-					}
-				case strings.HasPrefix(text, "/reset_state"):
-					if b.store == nil {
-						b.send(chatID, "State store не настроен")
-						break
-					}
-					if err := b.store.Reset(); err != nil {
-						b.send(chatID, "Не удалось сбросить state")
-					} else {
-						b.send(chatID, "State сброшен")
-					}
-				case strings.HasPrefix(text, "/switch_feed"):
-					parts := strings.Fields(text)
-					if len(parts) < 2 {
-						b.send(chatID, "Формат: /switch_feed rest|random")
-						break
-					}
-					ft := parts[1]
-					if ft != "rest" && ft != "random" {
-						b.send(chatID, "Только rest|random")
-						break
-					}
-					if prev := b.FeedType(); ft != prev {
-						b.SetFeedType(ft)
-						if b.switchFeed != nil {
-							b.switchFeed(ft)
-						}
-					}
-					b.send(chatID, "Фид переключён: "+ft)
-				default:
-					b.send(chatID, "Неизвестная команда. /help")
-				}
+				b.handle(up.Message.Chat.ID, strings.TrimSpace(up.Message.Text))
+			}
+		}
+	}
+}
+
+// handle runs one chat command: the message's first word, without the @bot
+// suffix Telegram adds in groups.
+func (b *Bot) handle(chatID int64, text string) {
+	parts := strings.Fields(text)
+	if len(parts) == 0 {
+		return
+	}
+	cmd, _, _ := strings.Cut(parts[0], "@")
+	switch cmd {
+	case "/start", "/help":
+		// Кнопка Web App
+		btn := map[string]any{"text": "📊 Открыть терминал", "web_app": map[string]string{"url": "http://localhost:8080/"}}
+		kb := map[string]any{"inline_keyboard": [][]any{{btn}}}
+		rm, _ := json.Marshal(kb)
+		v := url.Values{}
+		v.Set("chat_id", strconv.FormatInt(chatID, 10))
+		v.Set("text", helpText()+"\n\nОткрой мини-терминал:")
+		v.Set("reply_markup", string(rm))
+		var out tgResp[tgMessage]
+		b.api("sendMessage", v, &out)
+		// также отправим обычный help без кнопки (на всякий)
+		b.send(chatID, helpText())
+	case "/status":
+		b.send(chatID, b.status())
+	case "/equity":
+		b.send(chatID, b.equity())
+	case "/which_strategy":
+		b.send(chatID, b.which())
+	case "/start_trading":
+		b.setNotify(chatID, true)
+		b.setTrading(chatID, core.TradingRunning, "Торговля включена")
+	case "/stop_trading":
+		b.setTrading(chatID, core.TradingPaused, "Торговля приостановлена: сигналы игнорируются, SL/TP работают")
+	case "/close_only":
+		b.setTrading(chatID, core.TradingCloseOnly, "Режим close-only: только закрытие позиций")
+	case "/flatten":
+		b.setTrading(chatID, core.TradingHalted, "Позиции закрыты, ордера отменены, торговля остановлена")
+	case "/notify":
+		on := !strings.Contains(text, "off")
+		b.setNotify(chatID, on)
+		if on {
+			b.send(chatID, "Уведомления включены")
+		} else {
+			b.send(chatID, "Уведомления выключены")
+		}
+	case "/reconcile":
+		if rep, ok := b.eng.Reconciliation(); ok {
+			b.send(chatID, rep.String())
+		} else {
+			b.send(chatID, "Сверка не проводилась")
+		}
+	case "/ack_reconcile":
+		if err := b.eng.AckReconciliation(); err != nil {
+			b.send(chatID, "Нечего подтверждать")
+		} else {
+			b.send(chatID, "Расхождения подтверждены, торговля разрешена")
+		}
+	case "/set_strategy":
+		b.handleSetStrategy(chatID, text)
+	case "/history":
+		n := 10
+		if len(parts) >= 2 {
+			if v, err := atoiMaybe(parts[1]); err == nil && v > 0 {
+				n = v
+			}
+		}
+		if b.tl == nil {
+			b.send(chatID, "Журнал недоступен")
+			break
+		}
+		rows, err := b.tl.LastN(n)
+		if err != nil {
+			b.send(chatID, "Ошибка чтения журнала")
+		} else {
+			b.send(chatID, formatHistory(rows))
+		}
+	case "/save_state":
+		if err := b.saveState(); err != nil {
+			b.send(chatID, "Не удалось сохранить state")
+		} else {
+			b.send(chatID, "State сохранён")
+		}
+	case "/load_state":
+		if err := b.loadState(); err != nil {
+			b.send(chatID, "Не удалось загрузить state")
+		} else {
+			b.send(chatID, "State загружен")
+		}
+	case "/reset_state":
+		if b.store == nil {
+			b.send(chatID, "State store не настроен")
+			break
+		}
+		if err := b.store.Reset(); err != nil {
+			b.send(chatID, "Не удалось сбросить state")
+		} else {
+			b.send(chatID, "State сброшен")
+		}
+	case "/switch_feed":
+		if len(parts) < 2 {
+			b.send(chatID, "Формат: /switch_feed rest|random")
+			break
+		}
+		ft := parts[1]
+		if ft != "rest" && ft != "random" {
+			b.send(chatID, "Только rest|random")
+			break
+		}
+		if prev := b.FeedType(); ft != prev {
+			b.SetFeedType(ft)
+			if b.switchFeed != nil {
+				b.switchFeed(ft)
 			}
 		}
+		b.send(chatID, "Фид переключён: "+ft)
+	default:
+		b.send(chatID, "Неизвестная команда. /help")
 	}
 }
 
@@ -176,42 +211,103 @@ func (b *Bot) FeedType() string {
 func (b *Bot) handleSetStrategy(chatID int64, text string) {
 	parts := strings.Fields(text)
 	if len(parts) < 2 {
-		b.send(chatID, "Формат: /set_strategy ema|rsi ...")
+		b.send(chatID, "Формат: /set_strategy <kind> [значения по порядку | name=value ...]\n\n"+schemaHelp())
 		return
 	}
-	switch parts[1] {
-	case "ema":
-		if len(parts) < 6 {
-			b.send(chatID, "Формат: /set_strategy ema <fast> <slow> <atr> <R>")
-			return
-		}
-		st := state.StrategyState{
-			Type: "ema",
-			I:    []int{atoi(parts[2]), atoi(parts[3]), atoi(parts[4])},
-			F:    []float64{atof(parts[5])},
-		}
-		if err := b.applyStrategy(st); err != nil {
-			b.send(chatID, "Не удалось применить стратегию")
-			return
-		}
-		b.send(chatID, fmt.Sprintf("Стратегия EMA set: fast=%d slow=%d atr=%d R=%.2f", st.I[0], st.I[1], st.I[2], st.F[0]))
-	case "rsi":
-		if len(parts) < 6 {
-			b.send(chatID, "Формат: /set_strategy rsi <len> <overbought> <oversold> <R>")
-			return
-		}
-		st := state.StrategyState{
-			Type: "rsi",
-			I:    []int{atoi(parts[2])},
-			F:    []float64{atof(parts[3]), atof(parts[4]), atof(parts[5])},
+	r, ok := strategies.Lookup(parts[1])
+	if !ok {
+		b.send(chatID, "Неизвестная стратегия\n\n"+schemaHelp())
+		return
+	}
+	args, err := r.Args(parts[2:])
+	if err == nil {
+		err = b.applyStrategy(r.Schema.Kind, args)
+	}
+	if err != nil {
+		b.send(chatID, "Не удалось применить стратегию: "+err.Error()+"\n\n"+r.Schema.Usage())
+		return
+	}
+	b.send(chatID, b.which())
+}
+
+// schemaHelp lists the strategies /set_strategy accepts with their parameters.
+func schemaHelp() string {
+	var sb strings.Builder
+	for i, sc := range strategies.Schemas() {
+		if i > 0 {
+			sb.WriteString("\n")
 		}
-		if err := b.applyStrategy(st); err != nil {
-			b.send(chatID, "Не удалось применить стратегию")
+		sb.WriteString(sc.Usage())
+	}
+	return sb.String()
+}
+
+// setNotify turns chatID's notifications on or off. The first chat to turn
+// them on gets a startup reconciliation report that found no chat.
+func (b *Bot) setNotify(chatID int64, on bool) {
+	b.mu.Lock()
+	if !on {
+		delete(b.notify, chatID)
+		b.mu.Unlock()
+		return
+	}
+	b.notify[chatID] = true
+	held := b.held
+	b.held = ""
+	b.mu.Unlock()
+	if held != "" {
+		b.send(chatID, held)
+	}
+}
+
+func (b *Bot) setTrading(chatID int64, s core.TradingState, ok string) {
+	if err := b.eng.SetTradingState(s); err != nil {
+		b.send(chatID, "Не удалось сменить режим торговли: "+err.Error())
+		return
+	}
+	b.send(chatID, ok)
+}
+
+// HandleEvent is a core.Bus subscriber that forwards fills, exits, funding,
+// completed deals and rejections to the chats that enabled notifications.
+func (b *Bot) HandleEvent(ev core.Event) {
+	var text string
+	switch e := ev.(type) {
+	case core.PositionChanged:
+		text = fmt.Sprintf("%s %s %s qty=%.4f px=%.2f pnl=%.2f %s", e.Event, e.Symbol, actName(e.Side), e.Qty, e.Price, e.PnL, e.Comment)
+	case core.DealClosed:
+		d := e.Deal
+		text = fmt.Sprintf("Сделка %s %s %s закрыта: входов %d, средняя %.2f, выходов %d, средняя %.2f, pnl=%.2f", d.ID, d.Symbol, actName(d.Side), d.Entries, d.AvgEntry, d.Exits, d.AvgExit, d.PnL)
+	case core.RiskRejected:
+		text = fmt.Sprintf("Отклонено %s %s: %s", e.Symbol, actName(e.Signal.Action), e.Reason)
+	case core.StrategyError:
+		text = fmt.Sprintf("Ошибка стратегии %s: %v", e.Strategy, e.Err)
+	case core.Reconciled:
+		if len(e.Report.Items) == 0 && e.Report.Err == "" {
 			return
 		}
-		b.send(chatID, fmt.Sprintf("Стратегия RSI set: len=%d over=%.1f under=%.1f R=%.2f", st.I[0], st.F[0], st.F[1], st.F[2]))
+		text = e.Report.String()
+	case core.ExecutionFailed:
+		text = fmt.Sprintf("Ордер не исполнен %s %s qty=%.6f: %v", e.Symbol, actName(e.Side), e.Qty, e.Err)
+	case core.TradingStateChanged:
+		text = fmt.Sprintf("Режим торговли: %s → %s", e.From, e.To)
 	default:
-		b.send(chatID, "Неизвестная стратегия. Используй: ema | rsi")
+		return
+	}
+	if b.token == "" {
+		return
+	}
+	b.mu.Lock()
+	chats := make([]int64, 0, len(b.notify))
+	for id := range b.notify {
+		chats = append(chats, id)
+	}
+	if _, ok := ev.(core.Reconciled); ok && len(chats) == 0 {
+		b.held = text
+	}
+	b.mu.Unlock()
+	for _, id := range chats {
+		b.send(id, text)
 	}
 }
 
@@ -220,7 +316,10 @@ func (b *Bot) saveState() error {
 		return errors.New("state store nil")
 	}
 	b.captureStrategy(b.eng.Strategy())
+	acct := b.eng.Export()
 	st := state.State{
+		Account:  &acct,
+		Trading:  b.eng.TradingState().String(),
 		Strategy: b.strategy,
 		Feed: state.FeedState{
 			Type:   b.FeedType(),
@@ -240,7 +339,7 @@ func (b *Bot) loadState() error {
 		return err
 	}
 	if st.Strategy.Type != "" {
-		if err := b.applyStrategy(st.Strategy); err != nil {
+		if err := b.applyStrategy(st.Strategy.Type, strategies.Args(st.Strategy.Params, st.Strategy.Spec)); err != nil {
 			return err
 		}
 	}
@@ -260,41 +359,18 @@ func (b *Bot) loadState() error {
 	return nil
 }
 
-func (b *Bot) applyStrategy(st state.StrategyState) error {
-	switch st.Type {
-	case "ema":
-		if len(st.I) < 3 || len(st.F) < 1 {
-			return errors.New("invalid ema params")
-		}
-		strat := strategies.NewEmaAtr(st.I[0], st.I[1], st.I[2], st.F[0])
-		b.eng.AttachStrategy(strat)
-		b.captureStrategy(strat)
-	case "rsi":
-		if len(st.I) < 1 || len(st.F) < 3 {
-			return errors.New("invalid rsi params")
-		}
-		strat := strategies.NewRSI(st.I[0], st.F[0], st.F[1], st.F[2])
-		b.eng.AttachStrategy(strat)
-		b.captureStrategy(strat)
-	default:
-		return fmt.Errorf("unknown strategy: %s", st.Type)
+func (b *Bot) applyStrategy(kind string, args map[string]any) error {
+	strat, err := strategies.Build(kind, args)
+	if err != nil {
+		return err
 	}
+	b.eng.AttachStrategy(strat)
+	b.captureStrategy(strat)
 	return nil
 }
 
 func (b *Bot) captureStrategy(strat core.Strategy) {
-	if strat == nil {
-		b.strategy = state.StrategyState{}
-		return
-	}
-	switch s := strat.(type) {
-	case *strategies.EmaAtr:
-		b.strategy = state.StrategyState{Type: "ema", I: []int{s.Fast, s.Slow, s.AtrLen}, F: []float64{s.RiskR}}
-	case *strategies.RSI:
-		b.strategy = state.StrategyState{Type: "rsi", I: []int{s.Len}, F: []float64{s.Overbought, s.Oversold, s.RiskR}}
-	default:
-		b.strategy = state.StrategyState{Type: strat.Name()}
-	}
+	b.strategy = state.Capture(strat)
 }
 
 type tgUser struct {
@@ -322,7 +398,7 @@ type tgResp[T any] struct {
 }
 
 func (b *Bot) api(method string, params url.Values, out any) error {
-	u := "https://api.telegram.org/bot" + b.token + "/" + method
+	u := b.apiURL + "/bot" + b.token + "/" + method
 	resp, err := http.PostForm(u, params)
 	if err != nil {
 		return err
@@ -378,12 +454,32 @@ func (b *Bot) send(chatID int64, text string) {
 
 func (b *Bot) status() string {
 	s := b.eng.Snapshot()
-	return fmt.Sprintf("Mode: paper\nFeed: %s\nEquity: %.2f USD\nPos: %s qty=%.4f entry=%.2f unrl=%.2f", b.FeedType(), s.EquityUSD, actName(s.Position.Side), s.Position.Qty, s.Position.Entry, s.Position.Unreal)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Mode: %s\nTrading: %s\nFeed: %s\nEquity: %.2f USD (unrl=%.2f, net=%.2f)", b.eng.Mode(), b.eng.TradingState(), b.FeedType(), s.EquityUSD, s.Unrealized, s.NetEquity)
+	if s.MarginUsed > 0 {
+		fmt.Fprintf(&sb, "\nMargin: used=%.2f maint=%.2f ratio=%.1f%%", s.MarginUsed, s.MaintMargin, s.MarginRatio*100)
+	}
+	if len(s.Positions) == 0 {
+		sb.WriteString("\nPos: FLAT")
+	}
+	for _, p := range s.Positions {
+		fmt.Fprintf(&sb, "\nPos %s: %s qty=%.4f entry=%.2f unrl=%.2f", p.Symbol, actName(p.Side), p.Qty, p.Entry, p.Unreal)
+		if p.Leg != core.LegNet {
+			sb.WriteString(" [hedge]")
+		}
+		if p.LiqPrice > 0 {
+			fmt.Fprintf(&sb, " liq=%.2f", p.LiqPrice)
+		}
+	}
+	if rep, ok := b.eng.Reconciliation(); ok && rep.Pending() {
+		fmt.Fprintf(&sb, "\nТорговля на паузе: %d расхождений со сверки (/reconcile, /ack_reconcile)", len(rep.Items))
+	}
+	return sb.String()
 }
 
 func (b *Bot) equity() string {
 	s := b.eng.Snapshot()
-	return fmt.Sprintf("Equity: %.2f USD | Pos: %s %.4f @ %.2f (unrl=%.2f)", s.EquityUSD, actName(s.Position.Side), s.Position.Qty, s.Position.Entry, s.Position.Unreal)
+	return fmt.Sprintf("Equity: %.2f USD | Net: %.2f USD | Positions: %d (unrl=%.2f)", s.EquityUSD, s.NetEquity, len(s.Positions), s.Unrealized)
 }
 
 func (b *Bot) which() string {
@@ -391,14 +487,10 @@ func (b *Bot) which() string {
 	if strat == nil {
 		return "Стратегия не установлена"
 	}
-	switch s := strat.(type) {
-	case *strategies.EmaAtr:
-		return fmt.Sprintf("Активная стратегия: EMA_ATR fast=%d slow=%d atr=%d R=%.2f", s.Fast, s.Slow, s.AtrLen, s.RiskR)
-	case *strategies.RSI:
-		return fmt.Sprintf("Активная стратегия: RSI len=%d overbought=%.2f oversold=%.2f R=%.2f", s.Len, s.Overbought, s.Oversold, s.RiskR)
-	default:
-		return fmt.Sprintf("Активная стратегия: %s", strat.Name())
+	if d, ok := strategies.Describe(strat); ok {
+		return fmt.Sprintf("Активная стратегия: %s %s", strat.Name(), d)
 	}
+	return fmt.Sprintf("Активная стратегия: %s", strat.Name())
 }
 
 func helpText() string {
@@ -408,13 +500,16 @@ func helpText() string {
 		"/status — режим, equity, позиция\n" +
 		"/equity — кратко equity/позиция\n" +
 		"/which_strategy — показать активную стратегию\n" +
-		"/start_trading — включить уведомления (демо)\n" +
-		"/stop_trading — выключить уведомления (демо)\n" +
-		"/set_strategy ema <fast> <slow> <atr> <R>\n" +
-		"/set_strategy rsi <len> <overbought> <oversold> <R>\n" +
+		"/start_trading — включить торговлю и уведомления\n" +
+		"/stop_trading — пауза: сигналы игнорируются, SL/TP работают\n" +
+		"/close_only — только закрытие позиций\n" +
+		"/flatten — закрыть всё, отменить ордера и остановить торговлю\n" +
+		"/notify on|off — уведомления в этот чат\n" +
+		"/set_strategy <kind> [params] — /set_strategy без аргументов покажет параметры\n" +
 		"/switch_feed rest|random — переключить источник свечей\n" +
 		"/save_state, /load_state, /reset_state — управление состоянием\n" +
-		"/history [N] — последние N записей журнала (по умолчанию 10)"
+		"/history [N] — последние N записей журнала (по умолчанию 10)\n" +
+		"/reconcile — отчёт сверки с биржей, /ack_reconcile — подтвердить расхождения"
 }
 
 func formatHistory(rows []core.TradeLogEntry) string {
@@ -439,18 +534,6 @@ func actName(a core.Action) string {
 	}
 }
 
-func atoi(s string) int {
-	var x int
-	fmt.Sscanf(s, "%d", &x)
-	return x
-}
-
-func atof(s string) float64 {
-	var x float64
-	fmt.Sscanf(s, "%f", &x)
-	return x
-}
-
 func atoiMaybe(s string) (int, error) {
 	var x int
 	_, err := fmt.Sscanf(s, "%d", &x)
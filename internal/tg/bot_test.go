@@ -0,0 +1,102 @@
+package tg
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"tradebot/internal/core"
+)
+
+// fakeAPI is a Bot API server that records the messages sent.
+type fakeAPI struct {
+	mu   sync.Mutex
+	sent []string
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasSuffix(r.URL.Path, "/sendMessage") {
+		f.mu.Lock()
+		f.sent = append(f.sent, r.FormValue("text"))
+		f.mu.Unlock()
+	}
+	w.Write([]byte(`{"ok":true,"result":{}}`))
+}
+
+func (f *fakeAPI) last() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.sent) == 0 {
+		return ""
+	}
+	return f.sent[len(f.sent)-1]
+}
+
+func newTestBot(t *testing.T, trading core.TradingState) (*Bot, *core.Engine, *fakeAPI) {
+	t.Helper()
+	bus := core.NewBus()
+	t.Cleanup(bus.Close)
+	eng := core.NewEngine(core.EngineOpts{Mode: "paper", EqUSD: 10000, Bus: bus, Trading: trading})
+	api := &fakeAPI{}
+	srv := httptest.NewServer(api)
+	t.Cleanup(srv.Close)
+	b := NewBot("TOKEN", eng, nil, nil, "X", "1h", "random")
+	b.apiURL = srv.URL
+	return b, eng, api
+}
+
+func TestTradingCommands(t *testing.T) {
+	for _, tc := range []struct {
+		from core.TradingState
+		cmd  string
+		want core.TradingState
+	}{
+		{core.TradingPaused, "/start_trading", core.TradingRunning},
+		{core.TradingHalted, "/start_trading@tradebot", core.TradingRunning},
+		{core.TradingRunning, "/stop_trading", core.TradingPaused},
+		{core.TradingRunning, "/close_only", core.TradingCloseOnly},
+		{core.TradingRunning, "/flatten", core.TradingHalted},
+	} {
+		b, eng, api := newTestBot(t, tc.from)
+		b.handle(1, tc.cmd)
+		if got := eng.TradingState(); got != tc.want {
+			t.Errorf("%s from %s: trading %s, want %s (reply %q)", tc.cmd, tc.from, got, tc.want, api.last())
+		}
+	}
+}
+
+func TestStartAndHelpDoNotTouchTrading(t *testing.T) {
+	for _, cmd := range []string{"/start", "/help"} {
+		b, eng, api := newTestBot(t, core.TradingPaused)
+		b.handle(1, cmd)
+		if eng.TradingState() != core.TradingPaused || !strings.HasPrefix(api.last(), "Команды:") {
+			t.Errorf("%s: trading %s, reply %q; want help and trading untouched", cmd, eng.TradingState(), api.last())
+		}
+	}
+}
+
+func TestStartupReconciliationReachesTheFirstNotifiedChat(t *testing.T) {
+	b, _, api := newTestBot(t, core.TradingRunning)
+	rep := core.ReconcileReport{Broker: "binance", Policy: "alert", Items: []core.Discrepancy{
+		{Kind: "position", Source: "state", Symbol: "X", Local: 1, Remote: 0, Resolution: "pending"},
+	}}
+	b.HandleEvent(core.Reconciled{Report: rep})
+	if api.last() != "" {
+		t.Fatalf("sent %q with no chat to notify", api.last())
+	}
+	b.handle(7, "/notify")
+	api.mu.Lock()
+	sent := append([]string(nil), api.sent...)
+	api.mu.Unlock()
+	if len(sent) != 2 || sent[0] != rep.String() {
+		t.Fatalf("sent %q, want the report, then the notify reply", sent)
+	}
+	b.handle(8, "/notify")
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	if len(api.sent) != 3 {
+		t.Errorf("sent %q, want the report once", api.sent)
+	}
+}
@@ -24,6 +24,13 @@ type btReq struct {
 	SlippageBps   float64             `json:"slippageBps"`
 	Fees          backtest.FeesConfig `json:"fees"`
 	Exchange      string              `json:"exchange"`
+	Intrabar      string              `json:"intrabar"`
+	Opposite      string              `json:"opposite"`
+	AccountMode   string              `json:"accountMode"`
+	MarginMode    string              `json:"marginMode"`
+	MaintRate     float64             `json:"maintRate"`
+	FundingURL    string              `json:"fundingUrl"`
+	FundingFile   string              `json:"fundingFile"`
 	StrategyKind  string              `json:"strategy"`
 	StrategyArgs  map[string]any      `json:"args"`
 }
@@ -73,7 +80,13 @@ func (s *Server) handleBacktest(w http.ResponseWriter, r *http.Request) {
 	p := backtest.Params{
 		Symbol: req.Symbol, TF: req.TF, From: from, To: to,
 		InitialEquity: req.InitialEquity, Leverage: req.Leverage, SlippageBps: req.SlippageBps,
-		Fees: req.Fees, Exchange: req.Exchange, StrategyKind: req.StrategyKind, StrategyArgs: req.StrategyArgs,
+		Fees: req.Fees, Exchange: req.Exchange, Intrabar: req.Intrabar, Opposite: req.Opposite, AccountMode: req.AccountMode,
+		MarginMode: req.MarginMode, MaintRate: req.MaintRate,
+		FundingURL: req.FundingURL, FundingFile: req.FundingFile, StrategyKind: req.StrategyKind, StrategyArgs: req.StrategyArgs,
+	}
+	if _, err := backtest.NewStrategyFromParams(p); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
 	}
 	res, err := backtest.Run(p)
 	if err != nil {
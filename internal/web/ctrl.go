@@ -163,3 +163,86 @@ func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(s.GetStatus())
 }
+
+// POST /api/ctrl/cancel_order {"id":"o1"}
+func (s *Server) handleCancelOrder(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ID == "" {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+	if s.OnCancelOrder == nil {
+		http.Error(w, "not bound", http.StatusNotImplemented)
+		return
+	}
+	if err := s.OnCancelOrder(req.ID); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"ok": true})
+}
+
+// POST /api/ctrl/ack_reconcile -> accept startup reconciliation discrepancies
+func (s *Server) handleAckReconcile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.OnAckRecon == nil {
+		http.Error(w, "not bound", http.StatusNotImplemented)
+		return
+	}
+	if err := s.OnAckRecon(); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"ok": true})
+}
+
+// POST /api/ctrl/trading {"state":"running"|"paused"|"close_only"|"halted"}
+// "halted" (alias "flatten") closes every position and cancels all orders first.
+func (s *Server) handleSetTrading(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method", http.StatusMethodNotAllowed)
+		return
+	}
+	var body struct {
+		State string `json:"state"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+	if body.State == "" {
+		http.Error(w, "state required", http.StatusBadRequest)
+		return
+	}
+	if s.OnSetTrading == nil {
+		http.Error(w, "not bound", http.StatusNotImplemented)
+		return
+	}
+	if err := s.OnSetTrading(body.State); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"ok": true})
+}
+
+// GET /api/orders -> pending paper orders
+func (s *Server) handleOrders(w http.ResponseWriter, r *http.Request) {
+	if s.GetOrders == nil {
+		http.Error(w, "not bound", http.StatusNotImplemented)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s.GetOrders())
+}
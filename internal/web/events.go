@@ -0,0 +1,99 @@
+package web
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"tradebot/internal/core"
+)
+
+// HandleEvent is a core.Bus subscriber that streams fills, order updates and
+// funding payments to SSE clients.
+func (s *Server) HandleEvent(ev core.Event) {
+	var out Event
+	switch e := ev.(type) {
+	case core.PositionChanged:
+		out.Type = "trade"
+		if e.Event == core.EvFunding {
+			out.Type = "funding"
+		}
+		out.Data = tradeData(e.TradeEvent)
+	case core.OrderPlaced:
+		out = Event{Type: "order", Data: orderData(e.TS, e.TF, core.EvOrderNew, e.Order, 0)}
+	case core.OrderUpdated:
+		data := orderData(e.TS, e.TF, e.Event, e.Order, 0)
+		if e.Reason != "" {
+			data["note"] = e.Reason
+		}
+		out = Event{Type: "order", Data: data}
+	case core.OrderFilled:
+		out = Event{Type: "order", Data: orderData(e.TS, e.TF, core.EvOrderFilled, e.Order, e.Price)}
+	default:
+		return
+	}
+	b, err := json.Marshal(out)
+	if err != nil {
+		log.Printf("publish %s marshal: %v", ev.Kind(), err)
+		return
+	}
+	s.PublishJSON(string(b))
+}
+
+func tradeData(ev core.TradeEvent) map[string]any {
+	return map[string]any{
+		"id":     ev.OrderID,
+		"ts":     ev.TS.Format(time.RFC3339Nano),
+		"event":  ev.Event,
+		"side":   TradeSide(ev.Event, ev.Side),
+		"qty":    ev.Qty,
+		"price":  ev.Price,
+		"pnl":    ev.PnL,
+		"fee":    ev.Fee,
+		"note":   ev.Comment,
+		"symbol": ev.Symbol,
+		"tf":     ev.TF,
+		"leg":    ev.Leg.String(),
+		"deal":   ev.DealID,
+	}
+}
+
+// orderData reports px, or the order's own price when px is 0.
+func orderData(ts time.Time, tf, event string, o core.Order, px float64) map[string]any {
+	if px == 0 {
+		px = o.Price
+		if o.Type == core.Stop {
+			px = o.StopPrice
+		}
+	}
+	return map[string]any{
+		"id":     o.ID,
+		"ts":     ts.Format(time.RFC3339Nano),
+		"event":  event,
+		"side":   TradeSide("", o.Side),
+		"qty":    o.Qty,
+		"price":  px,
+		"pnl":    0.0,
+		"fee":    0.0,
+		"note":   o.Comment,
+		"symbol": o.Symbol,
+		"tf":     tf,
+		"leg":    o.Leg.String(),
+	}
+}
+
+// TradeSide names the side a position is left on after event: exits leave
+// it flat.
+func TradeSide(event string, side core.Action) string {
+	if core.IsExitEvent(event) {
+		return "flat"
+	}
+	switch side {
+	case core.Buy:
+		return "long"
+	case core.Sell:
+		return "short"
+	default:
+		return "flat"
+	}
+}
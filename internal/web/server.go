@@ -28,12 +28,16 @@ type Server struct {
 	selectedDSL string
 
 	// callbacks bound from main.go
-	OnSwitchFeed func(string) error
-	OnSaveState  func() error
-	OnLoadState  func() error
-	OnResetState func() error
-	GetStatus    func() any
-	OnSetSymbol  func(symbol, tf, mode string) error
+	OnSwitchFeed  func(string) error
+	OnSaveState   func() error
+	OnLoadState   func() error
+	OnResetState  func() error
+	GetStatus     func() any
+	OnSetSymbol   func(symbol, tf, mode string) error
+	GetOrders     func() any
+	OnCancelOrder func(id string) error
+	OnAckRecon    func() error
+	OnSetTrading  func(state string) error
 }
 
 func NewServer(botToken, addr string, dev bool) *Server {
@@ -76,6 +80,8 @@ func (s *Server) Serve() error {
 	mux.HandleFunc("/api/strategy/upload", s.handleStrategyUpload)
 	mux.HandleFunc("/api/strategy/list", s.handleStrategyList)
 	mux.HandleFunc("/api/strategy/select", s.handleStrategySelect)
+	mux.HandleFunc("/api/strategy/schema", s.handleStrategySchema)
+	mux.HandleFunc("/api/strategy/functions", s.handleStrategyFunctions)
 	// control
 	mux.HandleFunc("/api/ctrl/switch_feed", s.handleSwitchFeed)
 	mux.HandleFunc("/api/ctrl/save_state", s.handleSaveState)
@@ -83,7 +89,11 @@ func (s *Server) Serve() error {
 	mux.HandleFunc("/api/ctrl/reset_state", s.handleResetState)
 	mux.HandleFunc("/api/ctrl/set_symbol", s.handleSetSymbol)
 	mux.HandleFunc("/api/ctrl/sim_trade", s.handleSimTrade)
+	mux.HandleFunc("/api/ctrl/cancel_order", s.handleCancelOrder)
+	mux.HandleFunc("/api/ctrl/ack_reconcile", s.handleAckReconcile)
+	mux.HandleFunc("/api/ctrl/trading", s.handleSetTrading)
 	mux.HandleFunc("/api/status", s.handleStatus)
+	mux.HandleFunc("/api/orders", s.handleOrders)
 	// SSE
 	mux.HandleFunc("/sse", func(w http.ResponseWriter, r *http.Request) { s.hub.Subscribe(w, r) })
 
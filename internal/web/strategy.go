@@ -7,6 +7,8 @@ import (
 	"strings"
 
 	"tradebot/internal/backtest"
+	"tradebot/internal/indicators"
+	"tradebot/internal/strategies"
 )
 
 type strategyListItem struct {
@@ -89,3 +91,32 @@ func (s *Server) handleStrategySelect(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(map[string]any{"ok": true})
 }
+
+// GET /api/strategy/schema[?kind=rsi] -> parameter schemas of the strategies
+func (s *Server) handleStrategySchema(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method", http.StatusMethodNotAllowed)
+		return
+	}
+	var out any = strategies.Schemas()
+	if kind := r.URL.Query().Get("kind"); kind != "" {
+		sc, ok := strategies.SchemaOf(kind)
+		if !ok {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		out = sc
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+// GET /api/strategy/functions -> indicator functions of rules expressions
+func (s *Server) handleStrategyFunctions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(indicators.Functions())
+}
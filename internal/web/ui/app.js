@@ -93,11 +93,28 @@
     const res = await fetch('/api/backtest', { method:'POST', headers:{'Content-Type':'application/json', ...hdrs()}, body: JSON.stringify(body) });
     if(!res.ok){ alert('Backtest error'); return }
     const j = await res.json();
-    $('#bt-summary').textContent = `PNL=${j.summary.pnl.toFixed(2)} | Trades=${j.summary.trades} | WinRate=${(j.summary.winRate*100).toFixed(1)}% | PF=${j.summary.profitFactor.toFixed(2)} | MaxDD=${j.summary.maxDD.toFixed(2)}%`;
+    $('#bt-summary').textContent = `PNL=${j.summary.pnl.toFixed(2)} | Trades=${j.summary.trades} | WinRate=${(j.summary.winRate*100).toFixed(1)}% | PF=${j.summary.profitFactor.toFixed(2)} | MaxDD=${j.summary.maxDD.toFixed(2)}% | Deals=${j.summary.deals} (${(j.summary.dealWinRate*100).toFixed(1)}%)`;
     const a = $('#bt-zip'); a.href = j.artifacts.zip; a.style.display='inline-block';
     msg('Бэктест готов — ZIP доступен');
   };
 
+  // аргументы по умолчанию — из схемы выбранной стратегии; без схемы пусто
+  async function loadBacktestArgs(){
+    const kind = $('#bt-strat').value;
+    let args = {};
+    try{
+      const r = await fetch('/api/strategy/schema?kind='+encodeURIComponent(kind), {headers:hdrs()});
+      if(r.ok){
+        const sc = await r.json();
+        (sc.params||[]).forEach(p=>{ args[p.name]=p.default });
+        if(sc.spec) args.spec = sc.spec; // композиция задаётся строкой
+      }
+    }catch(err){ console.error(err); }
+    $('#bt-args').value = JSON.stringify(args);
+  }
+  $('#bt-strat').addEventListener('change', loadBacktestArgs);
+  loadBacktestArgs();
+
   // init значения дат: последние 24 часа
   (function initBacktestDates(){
     const to = new Date();